@@ -10,6 +10,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/zmnpl/ding/core"
+	"github.com/zmnpl/ding/termimg"
 )
 
 const (
@@ -18,6 +19,11 @@ const (
 	FOCUS_INBOUND     = 0
 	FOCUS_DIRECTORIES = 1
 	FOCUS_NEWNAME     = 2
+	FOCUS_SEARCH      = 3
+	FOCUS_DUPLICATE   = 4
+	FOCUS_TAGS        = 5
+	FOCUS_DASHBOARD   = 6
+	FOCUS_PASSWORD    = 7
 
 	STATUS_MOVE_OK     = "Ok"
 	STATUS_MOVE_FAILED = "Failed"
@@ -33,7 +39,11 @@ func Run() {
 }
 
 func (m model) Init() tea.Cmd {
-	return m.spinner.Tick
+	cmds := []tea.Cmd{m.spinner.Tick, makeWarmHashIndexCommand(), pollInbound()}
+	if name := m.selectedInboundName(); name != "" {
+		cmds = append(cmds, makePageCountCommand(name))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -49,6 +59,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			break
 		}
 
+		// actions whose tools are missing only explain what to install
+		if feature, ok := gatedKeys[msg.String()]; ok && m.keyIsGated(feature) {
+			m.statusMessage = core.MissingFeatureHint(feature)
+			return m, nil
+		}
+
 		switch msg.String() {
 
 		case "ctrl+c":
@@ -56,27 +72,142 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case "q":
 			switch m.focus {
-			case FOCUS_NEWNAME:
+			case FOCUS_NEWNAME, FOCUS_TAGS, FOCUS_SEARCH, FOCUS_DUPLICATE, FOCUS_PASSWORD:
 				break
 			default:
 				return m, tea.Quit
 			}
 
+		case "f8":
+			if m.focus == FOCUS_DASHBOARD {
+				m = m.focusInbound()
+				return m, nil
+			}
+			if m.focus == FOCUS_INBOUND || m.focus == FOCUS_DIRECTORIES {
+				return m.focusDashboard()
+			}
+
+		case "ctrl+f":
+			if m.focus != FOCUS_SEARCH {
+				m = m.focusSearch()
+				return m, tea.Batch(makeWarmSearchIndexCommand(), makeSearchCommand(m.searchInput.Value()))
+			}
+
+		case "s", "d", "f":
+			// answer to the question what to do with an already archived file
+			if m.focus == FOCUS_DUPLICATE {
+				pending := m.pendingMove
+				m = m.focusInbound()
+				switch msg.String() {
+				case "d":
+					return m, makeDeleteInboundCommand(pending.fileName)
+				case "f":
+					return m, makeMoveCommand(pending.fileName, pending.newName, pending.directoryName, pending.tags, true)
+				}
+				m.statusMessage = fmt.Sprintf("Skipped \"%v\"", pending.fileName)
+				return m, nil
+			}
+
+		case "tab":
+			switch m.focus {
+			case FOCUS_NEWNAME:
+				m = m.focusTags()
+				return m, nil
+			case FOCUS_TAGS:
+				// complete the tag which is typed; without anything to complete go back to the name
+				if completion := m.tagCompletion(); completion != "" {
+					m.tagInput.SetValue(m.tagInput.Value() + completion + ", ")
+					m.tagInput.CursorEnd()
+					return m, nil
+				}
+				m = m.focusNewName()
+				return m, nil
+			case FOCUS_PASSWORD:
+				m = m.togglePasswordInput()
+				return m, nil
+			}
+
+		case "shift+tab":
+			if m.focus == FOCUS_TAGS {
+				m = m.focusNewName()
+				return m, nil
+			}
+			if m.focus == FOCUS_PASSWORD {
+				m = m.togglePasswordInput()
+				return m, nil
+			}
+
+		case "esc":
+			if m.focus == FOCUS_DASHBOARD {
+				m = m.focusInbound()
+				return m, nil
+			}
+			if m.focus == FOCUS_DUPLICATE {
+				m = m.focusInbound()
+				return m, nil
+			}
+			if m.focus == FOCUS_PASSWORD {
+				m.passwordInput.SetValue("")
+				m.rememberInput.SetValue("")
+				m = m.focusInbound()
+				return m, nil
+			}
+			if m.focus == FOCUS_SEARCH {
+				m.searchInput.Blur()
+				m.selectedInbound = nil
+				m = m.focusInbound()
+				m = m.updatePreviewViews()
+				return m, nil
+			}
+
+		case "ctrl+g":
+			if m.focus == FOCUS_SEARCH && m.searchResultList.SelectedItem() != nil {
+				m = m.jumpToDirectory(m.searchResultList.SelectedItem().(searchResult).result.Directory)
+				return m, nil
+			}
+
+		case "up", "down", "pgup", "pgdown":
+			// navigate results while typing the query
+			if m.focus == FOCUS_SEARCH {
+				var cmd tea.Cmd
+				m.searchResultList, cmd = m.searchResultList.Update(msg)
+				m = m.updatePreviewViews()
+				return m, cmd
+			}
+
 		case "enter":
 			switch m.focus {
 			case FOCUS_INBOUND:
 				m = m.focusDirectories()
 			case FOCUS_DIRECTORIES:
 				m = m.focusNewName()
-			case FOCUS_NEWNAME:
+			case FOCUS_SEARCH:
+				return m, nil
+			case FOCUS_PASSWORD:
+				m.statusMessage = "Removing the password ..."
+				return m, makeUnprotectCommand(m.protectedName, m.passwordInput.Value(), strings.TrimSpace(m.rememberInput.Value()))
+			case FOCUS_NEWNAME, FOCUS_TAGS:
 				if m.selectedInbound != nil && m.selectedInbound.(inboundItem).file != nil && m.selectedDirectory != nil && m.selectedDirectory.(directory).dir != nil {
-					cmds = append(cmds, makeMoveCommand(m.selectedInbound.(inboundItem).file.Name(), m.timeStamp+m.newNameInput.Value(), m.selectedDirectory.(directory).dir.Name()))
+					cmds = append(cmds, makeMoveCommand(m.selectedInbound.(inboundItem).file.Name(), m.timeStamp+m.newNameInput.Value(), m.selectedDirectory.(directory).dir.Name(), core.ParseTags(m.tagInput.Value()), false))
 				}
 				m = m.focusInbound()
 				return m, tea.Batch(cmds...)
 			}
 
 		case "f1":
+			if m.focus == FOCUS_SEARCH {
+				if m.searchResultList.SelectedItem() != nil {
+					err := core.OpenDocExternal(m.searchResultList.SelectedItem().(searchResult).result.Path)
+					if err != nil {
+						m.statusMessage = fmt.Sprintf("could not open file in default application: %s", STATUS_ERR)
+					}
+				}
+				return m, nil
+			}
+
+			if m.selectedInbound == nil {
+				break
+			}
 			filename := m.selectedInbound.(inboundItem).file.Name()
 			err := core.OpenDocExternal(filepath.Join(core.Inbound, filename))
 			if err != nil {
@@ -84,6 +215,59 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			}
 
+		case "[", "]":
+			if m.focus == FOCUS_INBOUND {
+				page := m.previewPage + 1
+				if msg.String() == "[" {
+					page = m.previewPage - 1
+				}
+				return m.turnPreviewPage(page, m.previewLayout)
+			}
+
+		case "f4":
+			if m.focus == FOCUS_INBOUND {
+				return m.turnPreviewPage(m.previewPage, !m.previewLayout)
+			}
+
+		case "f6":
+			if m.focus == FOCUS_INBOUND {
+				return m.toggleThumbnail()
+			}
+
+		case "f7":
+			if m.focus == FOCUS_INBOUND && m.selectedInboundName() != "" {
+				protocol := termimg.Protocol()
+				if protocol == termimg.PROTOCOL_NONE {
+					m.statusMessage = "Your terminal does not seem to support kitty or sixel graphics; set DING_GRAPHICS to override"
+					return m, nil
+				}
+				return m, makeGraphicsCommand(m.selectedInboundName(), protocol)
+			}
+
+		case "f9":
+			if m.focus == FOCUS_INBOUND && m.selectedInboundName() != "" {
+				m.statusMessage = "Optimizing " + m.selectedInboundName() + " ..."
+				return m, makeOptimizeCommand(m.selectedInboundName())
+			}
+
+		case "ctrl+s":
+			if m.focus == FOCUS_INBOUND && !m.scanning {
+				m.scanning = true
+				m.statusMessage = "Scanning ..."
+				return m, makeScanCommand()
+			}
+
+		case "f10":
+			if m.focus == FOCUS_INBOUND && m.selectedInbound != nil {
+				itm := m.selectedInbound.(inboundItem)
+				if !itm.protected && !core.IsPasswordProtected(itm.name) {
+					m.statusMessage = fmt.Sprintf("\"%s\" is not password protected", itm.name)
+					return m, nil
+				}
+				m.statusMessage = "Trying the passwords of the keyring ..."
+				return m, makeUnprotectCommand(itm.name, "", "")
+			}
+
 		case "f2":
 			if !m.ocrRunning && len(m.inboundList.Items()) > m.inboundList.Index() {
 				m.ocrRunning = true
@@ -116,11 +300,132 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case ocrMessageSingle:
 		m.statusMessage = msg.message
 		m.ocrRunning = false
+		if msg.renamed {
+			cmd := m.inboundList.SetItems(InboundItemsAsBubblesList())
+			return m, cmd
+		}
+
+	case pagePreviewMsg:
+		if msg.name == m.selectedInboundName() && msg.page == m.previewPage && msg.layout == m.previewLayout {
+			m = m.showDocPreview()
+		}
+		return m, nil
+
+	case thumbnailMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("could not show first page: %s", msg.err)
+		}
+		if msg.name == m.selectedInboundName() && m.previewThumbnail {
+			m = m.showDocPreview()
+		}
+		return m, nil
+
+	case pageCountMsg:
+		if msg.err == nil && msg.name == m.selectedInboundName() {
+			m.previewPageCount = msg.count
+		}
+		return m, nil
+
+	case searchMsg:
+		// drop results of outdated queries
+		if msg.query != m.searchInput.Value() {
+			return m, nil
+		}
+		m.searchResultList.SetItems(SearchResultsAsBubblesList(msg.results))
+		m.searchResultList.Select(0)
+		m.statusMessage = fmt.Sprintf("%v documents found", len(msg.results))
+		m.selectedSearchResult = nil
+		m = m.updatePreviewViews()
+		return m, nil
+
+	case unprotectMsg:
+		return m.handleUnprotect(msg)
+
+	case scanMsg:
+		return m.handleScan(msg)
+
+	case inboundTickMsg:
+		return m.refreshInbound()
+
+	case optimizeMsg:
+		if msg.err != nil {
+			m.statusMessage = msg.err.Error()
+			return m, nil
+		}
+		if msg.after == msg.before {
+			m.statusMessage = fmt.Sprintf("%s is already as small as it gets (%s)", msg.name, core.FormatSize(msg.before))
+			return m, nil
+		}
+		m.statusMessage = fmt.Sprintf("Optimized %s: %s → %s", msg.name, core.FormatSize(msg.before), core.FormatSize(msg.after))
+		for i, item := range m.inboundList.Items() {
+			if itm, ok := item.(inboundItem); ok && itm.name == msg.name {
+				itm.size = msg.after
+				cmd := m.inboundList.SetItem(i, itm)
+				m.selectedInbound = nil
+				m = m.updatePreviewViews()
+				return m, cmd
+			}
+		}
+		return m, nil
+
+	case statsMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("could not collect statistics: %s", msg.err)
+			return m, nil
+		}
+		m.stats = &msg.stats
+		if m.focus == FOCUS_DASHBOARD {
+			m.statusMessage = "f8/esc: back"
+		}
+		return m, nil
+
+	case searchIndexMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("could not index documents: %s", msg.err)
+			return m, nil
+		}
+		// rerun query on the complete index
+		if m.focus == FOCUS_SEARCH {
+			m.statusMessage = "Search index is up to date"
+			return m, makeSearchCommand(m.searchInput.Value())
+		}
+		return m, nil
+
+	case hashIndexMsg:
+		if msg.err != nil {
+			m.statusMessage = fmt.Sprintf("could not check archive for duplicates: %s", msg.err)
+			return m, nil
+		}
+		m = m.markArchivedInboundItems()
+		return m, nil
+
+	case deleteMsg:
+		m.statusMessage = msg.messageText
+		if msg.err == nil {
+			m = m.removeInboundItem(msg.fileName)
+			m.newNameInput.SetValue("")
+			m.tagInput.SetValue("")
+		}
+		return m, nil
 
 	case moveMsg:
 		m.statusMessage = msg.messageText
-		m.inboundList.RemoveItem(m.inboundList.Index())
+		if _, ok := msg.err.(*core.DuplicateError); ok {
+			m.pendingMove = msg
+			m.focus = FOCUS_DUPLICATE
+			return m, nil
+		}
+		if msg.err != nil {
+			// a step before the move may have converted the file
+			if msg.renamed {
+				cmd := m.inboundList.SetItems(InboundItemsAsBubblesList())
+				return m, cmd
+			}
+			return m, nil
+		}
+		m = m.removeInboundItem(msg.fileName)
 		m.newNameInput.SetValue("")
+		m.tagInput.SetValue("")
 		return m, nil
 
 	default:
@@ -142,10 +447,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.directoryList, cmd = m.directoryList.Update(msg)
 	case FOCUS_NEWNAME:
 		m.newNameInput, cmd = m.newNameInput.Update(msg)
+	case FOCUS_TAGS:
+		m.tagInput, cmd = m.tagInput.Update(msg)
+	case FOCUS_PASSWORD:
+		if m.rememberInput.Focused() {
+			m.rememberInput, cmd = m.rememberInput.Update(msg)
+		} else {
+			m.passwordInput, cmd = m.passwordInput.Update(msg)
+		}
+	case FOCUS_SEARCH:
+		query := m.searchInput.Value()
+		m.searchInput, cmd = m.searchInput.Update(msg)
+		if m.searchInput.Value() != query {
+			cmd = tea.Batch(cmd, makeSearchCommand(m.searchInput.Value()))
+		}
 	}
 
 	// function which upates the previews
+	selectedBefore := m.selectedInboundName()
 	m = m.updatePreviewViews()
+	if name := m.selectedInboundName(); name != "" && name != selectedBefore {
+		cmd = tea.Batch(cmd, makePageCountCommand(name))
+		if m.previewThumbnail {
+			cmd = tea.Batch(cmd, makeThumbnailCommand(name))
+		}
+	}
 
 	return m, cmd
 }
@@ -158,14 +484,34 @@ func (m model) View() string {
 	var foo strings.Builder
 
 	m.help.ShowAll = false
-	foo.WriteString(myStyle.docStyle.Render(
-		lipgloss.JoinVertical(lipgloss.Left,
-			m.mainSection(),
-			m.selectedFile(),
-			m.newNameSection(),
-			m.statusBar(),
-			m.helpView(),
-		)))
+
+	if m.focus == FOCUS_DASHBOARD {
+		foo.WriteString(myStyle.docStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				m.dashboardSection(),
+				m.statusBar(),
+				m.helpView(),
+			)))
+		return foo.String()
+	}
+
+	if m.focus == FOCUS_SEARCH {
+		foo.WriteString(myStyle.docStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				m.mainSection(),
+				m.searchSection(),
+				m.statusBar(),
+				m.helpView(),
+			)))
+		return foo.String()
+	}
+
+	sections := []string{m.mainSection(), m.selectedFile(), m.newNameSection(), m.tagSection()}
+	if m.focus == FOCUS_PASSWORD {
+		sections = append(sections, m.passwordSection())
+	}
+	sections = append(sections, m.statusBar(), m.helpView())
+	foo.WriteString(myStyle.docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, sections...)))
 
 	return foo.String()
 }
@@ -1,6 +1,8 @@
 package bubl
 
 import (
+	"strings"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -34,8 +36,31 @@ type model struct {
 	newNameHeaderStyle lipgloss.Style
 	timeStamp          string
 
+	tagInput       textinput.Model
+	tagHeaderStyle lipgloss.Style
+
+	// password for the protected inbound pdf with the given name
+	passwordInput textinput.Model
+	protectedName string
+	// sender the typed password is remembered for in the keyring; empty does not remember it
+	rememberInput textinput.Model
+
+	searchInput          textinput.Model
+	searchResultList     list.Model
+	selectedSearchResult list.Item
+
 	ocrIndex   int
 	ocrRunning bool
+	scanning   bool
+
+	// changes when files come into or leave inbound
+	inboundFingerprint string
+
+	// statistics for the dashboard; nil until they are collected
+	stats *core.Stats
+
+	// move which waits for the users decision, because the file is already archived
+	pendingMove moveMsg
 
 	statusMessage string
 
@@ -43,14 +68,23 @@ type model struct {
 	focus int
 
 	previewWidth int
+
+	// multi-page preview of the selected inbound file
+	previewPage      int
+	previewPageCount int
+	previewLayout    bool
+	previewThumbnail bool
 }
 
 func initialModel() model {
+	gateKeys()
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = myStyle.highlightStyle
 
 	inbounds := InboundItemsAsBubblesList()
+	inboundFingerprint, _ := core.InboundFingerprint()
 	inboundList := list.New(inbounds, itemDelegate{}, 0, 0)
 	inboundList.Title = "Files"
 	inboundList.SetShowHelp(false)
@@ -91,11 +125,53 @@ func initialModel() model {
 	newNameInput.CharLimit = 128
 	newNameInput.Width = 32
 
+	tagInput := textinput.New()
+	tagInput.PlaceholderStyle = myStyle.styleInactiveText
+	tagInput.TextStyle = myStyle.styleActiveText
+	tagInput.Placeholder = "tax-2026, car ..."
+	tagInput.Prompt = ""
+	tagInput.Focus()
+	tagInput.CharLimit = 128
+	tagInput.Width = 32
+
+	passwordInput := textinput.New()
+	passwordInput.TextStyle = myStyle.styleActiveText
+	passwordInput.Prompt = ""
+	passwordInput.EchoMode = textinput.EchoPassword
+	passwordInput.EchoCharacter = '•'
+	passwordInput.CharLimit = 128
+	passwordInput.Width = 32
+
+	rememberInput := textinput.New()
+	rememberInput.PlaceholderStyle = myStyle.styleInactiveText
+	rememberInput.TextStyle = myStyle.styleActiveText
+	rememberInput.Placeholder = "sender to remember the password for ..."
+	rememberInput.Prompt = ""
+	rememberInput.CharLimit = 64
+	rememberInput.Width = 32
+
+	searchInput := textinput.New()
+	searchInput.PlaceholderStyle = myStyle.styleInactiveText
+	searchInput.TextStyle = myStyle.styleActiveText
+	searchInput.Placeholder = "search all documents ... #tag filters by tag"
+	searchInput.Prompt = ""
+	searchInput.CharLimit = 128
+	searchInput.Width = 32
+
+	searchResultList := list.New(nil, itemDelegate{}, 0, 0)
+	searchResultList.Title = "Results"
+	searchResultList.SetShowHelp(false)
+	searchResultList.SetShowStatusBar(false)
+	searchResultList.SetFilteringEnabled(false)
+	searchResultList.Styles.Title = myStyle.titleStyleSelected
+	searchResultList.Styles.PaginationStyle = myStyle.paginationStyle
+
 	m := model{
 		appHeightPercent:     0.4,
 		spinner:              s,
 		inboundList:          inboundList,
 		inboundColumnWidth:   listMaxItemLength(inbounds),
+		inboundFingerprint:   inboundFingerprint,
 		selectedInbound:      selectedInbound,
 		directoryList:        directoryList,
 		directoryColumnWidth: listMaxItemLength(directories),
@@ -104,8 +180,15 @@ func initialModel() model {
 
 		newNameInput:       newNameInput,
 		newNameHeaderStyle: myStyle.titleStyleSelected,
+		tagInput:           tagInput,
+		tagHeaderStyle:     myStyle.titleStyle,
+		passwordInput:      passwordInput,
+		rememberInput:      rememberInput,
+		searchInput:        searchInput,
+		searchResultList:   searchResultList,
 		help:               help.New(),
 		previewWidth:       35,
+		previewPage:        1,
 
 		timeStamp: core.GetTimestampFilePrefix(),
 	}
@@ -142,6 +225,9 @@ func (m model) reactToWindowSize(msg tea.WindowSizeMsg) model {
 	m.directoryList.SetSize(m.directoryColumnWidth, height-3-2-helpHeight)
 	m.directoryFileList.SetSize(m.directoryFilesColumnWidth, height-3-4-helpHeight)
 	m.newNameInput.Width = m.width - lipgloss.Width(core.GetTimestampFilePrefix())
+	m.tagInput.Width = m.width - lipgloss.Width(core.GetTimestampFilePrefix())
+	m.searchResultList.SetSize(m.inboundColumnWidth, height-3-2-helpHeight)
+	m.searchInput.Width = m.width - 20
 
 	// preview
 	headerHeight := 3
@@ -166,10 +252,16 @@ func (m model) updatePreviewViews() model {
 	// update previews if items have changed
 	if m.inboundList.SelectedItem() != m.selectedInbound {
 		m.selectedInbound = m.inboundList.SelectedItem()
+		m.previewPage = 1
+		m.previewPageCount = 0
 		if m.selectedInbound != nil {
-			m.preview.SetContent(myStyle.textDimmedStyle.Render(wrap.String(wordwrap.String(m.docPreview(), m.previewWidth), m.previewWidth)))
+			m = m.showDocPreview()
 		}
 	}
+	if m.focus == FOCUS_SEARCH && m.searchResultList.SelectedItem() != m.selectedSearchResult {
+		m.selectedSearchResult = m.searchResultList.SelectedItem()
+		m = m.updateSearchPreview()
+	}
 	if m.directoryFileList.SelectedItem() != m.selectedDirectory {
 		m.selectedDirectory = m.directoryList.SelectedItem()
 		if m.selectedDirectory != nil {
@@ -191,16 +283,189 @@ func (m model) focusDirectories() model {
 }
 
 func (m model) focusNewName() model {
-	m.focus = FOCUS_NEWNAME
 	m.statusMessage = "Enter a file name..."
 
 	m.directoryList.Styles.Title = myStyle.titleStyle
-	m.timeStamp = core.GetTimestampFilePrefix()
+	if m.focus != FOCUS_TAGS {
+		m.timeStamp = core.GetTimestampFilePrefix()
+	}
+	m.focus = FOCUS_NEWNAME
 	m.newNameHeaderStyle = myStyle.titleStyleSelected
+	m.tagHeaderStyle = myStyle.titleStyle
+
+	return m
+}
+
+func (m model) focusTags() model {
+	m.focus = FOCUS_TAGS
+	m.statusMessage = "Enter tags separated by comma; tab completes tags in use, enter files the document"
+
+	m.newNameHeaderStyle = myStyle.titleStyle
+	m.tagHeaderStyle = myStyle.titleStyleSelected
+	m.tagInput.CursorEnd()
 
 	return m
 }
 
+// tagCompletion returns what is missing from the tag which is typed to the most used known tag
+func (m model) tagCompletion() string {
+	value := m.tagInput.Value()
+	start := strings.LastIndexAny(value, ", ") + 1
+	typed := value[start:]
+	if typed == "" {
+		return ""
+	}
+	completions := core.CompleteTag(typed)
+	if len(completions) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(completions[0], strings.ToLower(strings.TrimLeft(typed, "#")))
+}
+
+// selectedInboundName returns the file name of the selected inbound file or an empty string
+func (m model) selectedInboundName() string {
+	if m.selectedInbound != nil && m.selectedInbound.(inboundItem).file != nil {
+		return m.selectedInbound.(inboundItem).file.Name()
+	}
+	return ""
+}
+
+// showDocPreview puts the preview of the selected inbound file into the preview view
+// in thumbnail mode, the first page is shown as image instead
+func (m model) showDocPreview() model {
+	if m.previewThumbnail {
+		if img, ok := core.GetCachedDocThumbnail(m.selectedInboundName()); ok {
+			m.preview.SetContent(halfBlockThumbnail(img, m.previewWidth))
+			return m
+		}
+		m.preview.SetContent(myStyle.textDimmedStyle.Render("rendering ..."))
+		return m
+	}
+
+	m.preview.SetContent(myStyle.textDimmedStyle.Render(wrap.String(wordwrap.String(m.docPreview(), m.previewWidth), m.previewWidth)))
+	return m
+}
+
+// toggleThumbnail switches the preview between text and an image of the first page
+func (m model) toggleThumbnail() (model, tea.Cmd) {
+	name := m.selectedInboundName()
+	m.previewThumbnail = !m.previewThumbnail
+	m = m.showDocPreview()
+	m.preview.GotoTop()
+
+	if _, ok := core.GetCachedDocThumbnail(name); m.previewThumbnail && !ok && name != "" {
+		return m, makeThumbnailCommand(name)
+	}
+	return m, nil
+}
+
+// turnPreviewPage shows the given page of the selected inbound file in the preview
+// pages which are not extracted yet are loaded async
+func (m model) turnPreviewPage(page int, layout bool) (model, tea.Cmd) {
+	name := m.selectedInboundName()
+	if name == "" || page < 1 || (m.previewPageCount > 0 && page > m.previewPageCount) {
+		return m, nil
+	}
+
+	m.previewPage = page
+	m.previewLayout = layout
+	m.previewThumbnail = false
+	m = m.showDocPreview()
+	m.preview.GotoTop()
+
+	if _, ok := core.GetCachedDocPagePreview(name, page, layout); ok {
+		return m, nil
+	}
+	return m, makePagePreviewCommand(name, page, layout)
+}
+
+func (m model) focusSearch() model {
+	m.focus = FOCUS_SEARCH
+	m.statusMessage = "Search your documents..."
+
+	m.inboundList.Styles.Title = myStyle.titleStyle
+	m.directoryList.Styles.Title = myStyle.titleStyle
+	m.newNameHeaderStyle = myStyle.titleStyle
+	m.tagHeaderStyle = myStyle.titleStyle
+	m.searchInput.Focus()
+
+	// force preview update for the current result
+	m.selectedSearchResult = nil
+	m = m.updateSearchPreview()
+
+	return m
+}
+
+// updateSearchPreview shows the text of the selected search result in the preview with all hits highlighted
+func (m model) updateSearchPreview() model {
+	if m.selectedSearchResult == nil {
+		m.preview.SetContent(myStyle.textDimmedStyle.Render("-"))
+		return m
+	}
+
+	text := core.GetCachedDocText(m.selectedSearchResult.(searchResult).result.Path)
+	if text == "" {
+		text = "- no OCR content -"
+	}
+
+	content, firstHit := highlightTerms(
+		wrap.String(wordwrap.String(text, m.previewWidth), m.previewWidth),
+		core.SearchQueryTerms(m.searchInput.Value()),
+		myStyle.textDimmedStyle,
+		myStyle.searchHitStyle)
+	m.preview.SetContent(content)
+
+	m.preview.GotoTop()
+	if firstHit > 0 {
+		m.preview.SetYOffset(firstHit)
+	}
+
+	return m
+}
+
+// jumpToDirectory selects the directory with the given name in the directory list and focuses it
+func (m model) jumpToDirectory(name string) model {
+	for i, item := range m.directoryList.Items() {
+		if item.(directory).name == name {
+			m.directoryList.Select(i)
+			m.selectedDirectory = item
+			m = m.updateDirectoryFiles()
+			break
+		}
+	}
+	m.searchInput.Blur()
+	m.preview.SetContent(myStyle.textDimmedStyle.Render(wrap.String(wordwrap.String(m.docPreview(), m.previewWidth), m.previewWidth)))
+
+	return m.focusDirectories()
+}
+
+// markArchivedInboundItems flags all inbound items which already exist in the destination
+func (m model) markArchivedInboundItems() model {
+	for i, item := range m.inboundList.Items() {
+		itm := item.(inboundItem)
+		itm.archived = core.GetArchivedCopies(itm.name)
+		m.inboundList.SetItem(i, itm)
+	}
+	m.inboundColumnWidth = listMaxItemLength(m.inboundList.Items())
+	m.inboundList.SetWidth(m.inboundColumnWidth)
+	m.selectedInbound = m.inboundList.SelectedItem()
+
+	return m
+}
+
+// removeInboundItem removes the inbound file with the given name from the list
+// the selection may have moved on while the file was moved or deleted, so the item is looked up by name
+func (m model) removeInboundItem(name string) model {
+	for i, item := range m.inboundList.Items() {
+		if item.(inboundItem).name == name {
+			m.inboundList.RemoveItem(i)
+			break
+		}
+	}
+	m.selectedInbound = m.inboundList.SelectedItem()
+	return m
+}
+
 func (m model) focusInbound() model {
 	m.focus = FOCUS_INBOUND
 	m.statusMessage = "Select a file..."
@@ -208,6 +473,7 @@ func (m model) focusInbound() model {
 	//m.inboundList.SetDelegate(listItemActiveDelegate)
 	m.inboundList.Styles.Title = myStyle.titleStyleSelected
 	m.newNameHeaderStyle = myStyle.titleStyle
+	m.tagHeaderStyle = myStyle.titleStyle
 
 	return m
 }
@@ -0,0 +1,224 @@
+package bubl
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/zmnpl/ding/core"
+)
+
+// RunDedupe starts an interactive screen to go through the given duplicate groups
+// and keep one document per group, while the others are moved to the trash
+func RunDedupe(groups []core.DuplicateGroup) error {
+	p := tea.NewProgram(dedupeModel{groups: groups, trashed: make(map[string]bool), help: help.New()})
+	return p.Start()
+}
+
+type dedupeModel struct {
+	groups []core.DuplicateGroup
+	// files which went to the trash; they are left out of the groups which follow
+	trashed map[string]bool
+	group   int
+	cursor  int
+	width   int
+	busy    bool
+
+	statusMessage string
+	help          help.Model
+}
+
+type trashMsg struct {
+	messageText string
+	trashed     []string
+	err         error
+}
+
+func makeTrashCommand(files []string) func() tea.Msg {
+	return func() tea.Msg {
+		failed := make([]string, 0)
+		trashed := make([]string, 0, len(files))
+		for _, f := range files {
+			if err := core.TrashFile(f); err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			trashed = append(trashed, f)
+		}
+		if len(failed) > 0 {
+			return trashMsg{
+				messageText: strings.Join(failed, "; "),
+				trashed:     trashed,
+				err:         fmt.Errorf("could not trash all files"),
+			}
+		}
+		return trashMsg{messageText: fmt.Sprintf("Moved %v files to the trash", len(files)), trashed: trashed}
+	}
+}
+
+func (m dedupeModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m dedupeModel) done() bool {
+	return m.group >= len(m.groups)
+}
+
+// nextGroup moves on to the next group which still has at least two files that are not in the trash
+func (m dedupeModel) nextGroup() dedupeModel {
+	m.cursor = 0
+	for m.group++; m.group < len(m.groups); m.group++ {
+		files := make([]string, 0, len(m.groups[m.group].Files))
+		for _, f := range m.groups[m.group].Files {
+			if !m.trashed[f] {
+				files = append(files, f)
+			}
+		}
+		m.groups[m.group].Files = files
+		if len(files) >= 2 {
+			break
+		}
+	}
+	return m
+}
+
+func (m dedupeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.help.Width = msg.Width
+
+	case trashMsg:
+		m.busy = false
+		m.statusMessage = msg.messageText
+		for _, f := range msg.trashed {
+			m.trashed[f] = true
+		}
+		m = m.nextGroup()
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, dedupeKeys.Quit):
+			return m, tea.Quit
+		}
+
+		if m.done() || m.busy {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, dedupeKeys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, dedupeKeys.Down):
+			if m.cursor < len(m.groups[m.group].Files)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, dedupeKeys.Skip):
+			m.statusMessage = fmt.Sprintf("Skipped group %v", m.group+1)
+			m = m.nextGroup()
+		case key.Matches(msg, dedupeKeys.Keep):
+			trash := make([]string, 0)
+			for i, f := range m.groups[m.group].Files {
+				if i != m.cursor {
+					trash = append(trash, f)
+				}
+			}
+			m.busy = true
+			m.statusMessage = "Moving files to the trash..."
+			return m, makeTrashCommand(trash)
+		}
+	}
+
+	return m, nil
+}
+
+func (m dedupeModel) View() string {
+	var b strings.Builder
+
+	if len(m.groups) == 0 {
+		b.WriteString(myStyle.titleStyleSelected.Render("Duplicates") + "\n\n")
+		b.WriteString(myStyle.itemStyle.Render("No duplicates found.") + "\n")
+	} else if m.done() {
+		b.WriteString(myStyle.titleStyleSelected.Render("Duplicates") + "\n\n")
+		b.WriteString(myStyle.itemStyle.Render(fmt.Sprintf("Reviewed all %v groups.", len(m.groups))) + "\n")
+	} else {
+		g := m.groups[m.group]
+		b.WriteString(myStyle.titleStyleSelected.Render("Duplicates") + " " +
+			myStyle.textDimmedStyle.Render(fmt.Sprintf("group %v of %v • %s • %.0f%% similar", m.group+1, len(m.groups), g.Kind, g.Similarity*100)) +
+			"\n\n")
+
+		for i, f := range g.Files {
+			size := ""
+			if info, err := os.Stat(filepath.Join(core.Dest, f)); err == nil {
+				size = fmt.Sprintf("(%v Mib)", math.Round(float64(info.Size())*100/1048576)/100)
+			}
+
+			line := myStyle.itemStyle.Render(f)
+			if i == m.cursor {
+				line = myStyle.itemStyleSelected.Render("> " + f)
+			}
+			b.WriteString(line + " " + myStyle.textDimmedStyle.Render(size) + "\n")
+		}
+	}
+
+	statusBar := lipgloss.NewStyle().Margin(1, 0, 0, 0).Render(
+		myStyle.titleStyle.Render("$ ") + myStyle.statusBarStyle.Render(m.statusMessage))
+	helpView := lipgloss.NewStyle().Margin(1, 0, 0, 0).Render(m.help.FullHelpView(dedupeKeys.FullHelp()))
+
+	return myStyle.docStyle.Copy().MarginLeft(2).Render(b.String() + statusBar + "\n" + helpView)
+}
+
+// -----------------------------------------------------------------------------
+// help
+
+type dedupeKeyMap struct {
+	Up   key.Binding
+	Down key.Binding
+	Keep key.Binding
+	Skip key.Binding
+	Quit key.Binding
+}
+
+func (k dedupeKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Keep, k.Quit}
+}
+
+func (k dedupeKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Keep, k.Skip},
+		{k.Up, k.Down},
+		{k.Quit},
+	}
+}
+
+var dedupeKeys = dedupeKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	Keep: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "keep selected, trash the rest"),
+	),
+	Skip: key.NewBinding(
+		key.WithKeys("s", "n"),
+		key.WithHelp("s", "skip group"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
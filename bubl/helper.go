@@ -1,7 +1,10 @@
 package bubl
 
 import (
+	"strings"
+
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type myListItem interface {
@@ -23,3 +26,59 @@ func listMaxItemLength(items []list.Item) (max int) {
 	}
 	return
 }
+
+// highlightTerms renders all case insensitive occurences of the given terms in the text with the hit style
+// and the rest of the text with the base style
+// it also returns the line number of the first hit, so that a view can scroll there
+func highlightTerms(text string, terms []string, base, hit lipgloss.Style) (highlighted string, firstHitLine int) {
+	lower := strings.ToLower(text)
+	firstHitLine = -1
+
+	// mark every byte which belongs to a hit
+	isHit := make([]bool, len(text))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			for j := offset + i; j < offset+i+len(term) && j < len(text); j++ {
+				isHit[j] = true
+			}
+			offset += i + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && isHit[end] == isHit[start] {
+			end++
+		}
+		style := base
+		if isHit[start] {
+			if firstHitLine < 0 {
+				firstHitLine = strings.Count(text[:start], "\n")
+			}
+			style = hit
+		}
+		b.WriteString(renderLines(style, text[start:end]))
+		start = end
+	}
+
+	return b.String(), firstHitLine
+}
+
+// renderLines renders every line on its own, so that lipgloss does not pad lines of multi line strings
+func renderLines(style lipgloss.Style, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = style.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,7 +1,11 @@
 package bubl
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/lipgloss"
+	"github.com/zmnpl/ding/core"
 )
 
 // part renders
@@ -18,12 +22,20 @@ func (m model) mainSection() string {
 	if m.focus == FOCUS_INBOUND {
 		ms := lipgloss.JoinHorizontal(lipgloss.Top,
 			lipgloss.NewStyle().Margin(0, 1, 0, 0).Width(m.inboundColumnWidth).Render(m.inboundList.View()),
-			lipgloss.NewStyle().Margin(2, 1, 0, 0).Width(m.previewWidth).Render(myStyle.previewInactiveColorStyle.Render(m.preview.View())),
+			lipgloss.NewStyle().Margin(1, 1, 0, 0).Width(m.previewWidth).Render(
+				m.previewHeader()+"\n"+myStyle.previewInactiveColorStyle.Render(m.preview.View())),
 		)
 		return ms
 
 	}
 
+	if m.focus == FOCUS_SEARCH {
+		return lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.NewStyle().Margin(0, 1, 0, 0).Width(m.inboundColumnWidth).Render(m.searchResultList.View()),
+			lipgloss.NewStyle().Margin(2, 1, 0, 0).Width(m.previewWidth).Render(myStyle.previewInactiveColorStyle.Render(m.preview.View())),
+		)
+	}
+
 	ms := lipgloss.JoinHorizontal(lipgloss.Top,
 		lipgloss.NewStyle().Margin(0, 1, 0, 0).Width(m.directoryColumnWidth).Render(m.directoryList.View()),
 		lipgloss.NewStyle().Margin(1, 0, 0, 0).Width(m.directoryFilesColumnWidth).Render(m.directoryFileList.View()),
@@ -31,12 +43,36 @@ func (m model) mainSection() string {
 	return ms
 }
 
+// previewHeader shows which page of the document is previewed and how
+func (m model) previewHeader() string {
+	mode := "raw"
+	if m.previewLayout {
+		mode = "layout"
+	}
+	if m.previewThumbnail {
+		return myStyle.textDimmedStyle.Render("page 1 • thumbnail")
+	}
+	pages := "?"
+	if m.previewPageCount > 0 {
+		pages = fmt.Sprint(m.previewPageCount)
+	}
+	header := myStyle.textDimmedStyle.Render(fmt.Sprintf("page %v/%s • %s", m.previewPage, pages, mode))
+	if due, ok := core.GetCachedDueDate(m.selectedInboundName()); ok {
+		header += myStyle.textDimmedStyle.Render(" • ") + myStyle.highlightStyle.Render("due "+due.Format("2006-01-02"))
+	}
+	return header
+}
+
 func (m model) selectedFile() string {
 	s := lipgloss.NewStyle().Margin(1, 0, 0, 0).Padding(0, 0).Render("  " +
 		myStyle.titleStyle.Render("Selected File") + "  ")
 
 	if m.inboundList.SelectedItem() != nil {
-		return s + m.inboundList.SelectedItem().(inboundItem).Title()
+		itm := m.inboundList.SelectedItem().(inboundItem)
+		if len(itm.archived) > 0 {
+			return s + itm.Title() + myStyle.textDimmedStyle.Render(" - already archived as "+strings.Join(itm.archived, ", "))
+		}
+		return s + itm.Title()
 	}
 	return s
 }
@@ -48,6 +84,31 @@ func (m model) newNameSection() string {
 		m.newNameInput.View())
 }
 
+func (m model) tagSection() string {
+	hint := ""
+	if m.focus == FOCUS_TAGS {
+		if completion := m.tagCompletion(); completion != "" {
+			hint = myStyle.textDimmedStyle.Render(completion)
+		} else if known := core.GetKnownTags(); len(known) > 0 {
+			if len(known) > 8 {
+				known = known[:8]
+			}
+			hint = myStyle.textDimmedStyle.Render("  in use: " + strings.Join(known, ", "))
+		}
+	}
+
+	return lipgloss.NewStyle().Margin(0, 0, 0, 0).Padding(0, 0).Render("  " +
+		m.tagHeaderStyle.Render("Tags") + "           " +
+		strings.Repeat(" ", lipgloss.Width(m.timeStamp)) +
+		m.tagInput.View() + hint)
+}
+
+func (m model) searchSection() string {
+	return lipgloss.NewStyle().Margin(1, 0, 0, 0).Padding(0, 0).Render("  " +
+		myStyle.titleStyleSelected.Render("Search") + "  " +
+		m.searchInput.View())
+}
+
 func (m model) statusBar() string {
 	statusWidth := m.width - 2
 
@@ -2,6 +2,7 @@ package bubl
 
 import (
 	"fmt"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/zmnpl/ding/core"
@@ -10,10 +11,26 @@ import (
 // -----------------------------------------------------------------------------
 // messages
 type moveMsg struct {
+	messageText   string
+	fileName      string
+	newName       string
+	directoryName string
+	tags          []string
+	results       []core.StepResult
+	renamed       bool
+	err           error
+}
+
+type deleteMsg struct {
 	messageText string
+	fileName    string
 	err         error
 }
 
+type hashIndexMsg struct {
+	err error
+}
+
 type ocrMessageMulti struct {
 	message string
 	err     error
@@ -22,46 +39,145 @@ type ocrMessageMulti struct {
 type ocrMessageSingle struct {
 	message string
 	err     error
+	// images become pdfs with another name
+	renamed bool
+}
+
+type pagePreviewMsg struct {
+	name   string
+	page   int
+	layout bool
+}
+
+type pageCountMsg struct {
+	name  string
+	count int
+	err   error
+}
+
+type optimizeMsg struct {
+	name   string
+	before int64
+	after  int64
+	err    error
+}
+
+type searchMsg struct {
+	query   string
+	results []core.SearchResult
+}
+
+type searchIndexMsg struct {
+	err error
 }
 
 // -----------------------------------------------------------------------------
 // commands
-func makeMoveCommand(fileName, newName, directoryName string) func() tea.Msg {
+func makeMoveCommand(fileName, newName, directoryName string, tags []string, force bool) func() tea.Msg {
 	return func() tea.Msg {
-		neeewName, err := core.MoveFileToDirectory(fileName, newName, directoryName)
+		job := core.NewIngestJob(fileName, newName, directoryName, tags, force)
+		results, err := core.Ingest(job)
 
-		messageWaht := fmt.Sprintf("\"%v\" to \"%v\"", fileName, neeewName)
+		messageWaht := fmt.Sprintf("\"%v\" to \"%v\"", fileName, job.NewName)
 		message := "Moved " + messageWaht
-		if err != nil {
-			message = "Failed to move " + messageWaht
+		if dupErr, ok := err.(*core.DuplicateError); ok {
+			message = fmt.Sprintf("Already archived as %s - s: skip, d: delete inbound file, f: file anyway", strings.Join(dupErr.Copies, ", "))
+		} else if err != nil {
+			message = "Failed to move " + messageWaht + ": " + err.Error()
+		}
+		if summary := core.StepSummary(results); summary != "" && err == nil {
+			message += " (" + summary + ")"
 		}
 		return moveMsg{
+			messageText:   message,
+			fileName:      fileName,
+			newName:       newName,
+			directoryName: directoryName,
+			tags:          tags,
+			results:       results,
+			renamed:       job.Name != job.Original,
+			err:           err,
+		}
+	}
+}
+
+func makeOptimizeCommand(name string) func() tea.Msg {
+	return func() tea.Msg {
+		before, after, err := core.OptimizePdf(name)
+		return optimizeMsg{name: name, before: before, after: after, err: err}
+	}
+}
+
+func makeDeleteInboundCommand(fileName string) func() tea.Msg {
+	return func() tea.Msg {
+		err := core.DeleteInboundFile(fileName)
+		message := fmt.Sprintf("Deleted \"%v\"", fileName)
+		if err != nil {
+			message = err.Error()
+		}
+		return deleteMsg{
 			messageText: message,
+			fileName:    fileName,
 			err:         err,
 		}
 	}
 }
 
+func makeWarmHashIndexCommand() func() tea.Msg {
+	return func() tea.Msg {
+		return hashIndexMsg{err: core.WarmHashIndex()}
+	}
+}
+
 func (i inboundItem) makeOcrCommand(single bool) func() tea.Msg {
 
-	action := func() (string, error) {
-		err := core.OcrPdf(i.name)
+	action := func() (string, bool, error) {
+		name, err := core.OcrInboundFile(i.name)
 		message := "success"
 		if err != nil {
 			message = err.Error()
 		}
-		return message, err
+		return message, name != i.name, err
 	}
 
 	if single {
 		return func() tea.Msg {
-			message, err := action()
-			return ocrMessageSingle{message: message, err: err}
+			message, renamed, err := action()
+			return ocrMessageSingle{message: message, err: err, renamed: renamed}
+		}
+	}
+
+	return func() tea.Msg {
+		message, renamed, err := action()
+		return ocrMessageSingle{message: message, err: err, renamed: renamed}
+	}
+}
+
+func makePagePreviewCommand(name string, page int, layout bool) func() tea.Msg {
+	return func() tea.Msg {
+		core.GetDocPagePreview(name, page, layout)
+		return pagePreviewMsg{name: name, page: page, layout: layout}
+	}
+}
+
+func makePageCountCommand(name string) func() tea.Msg {
+	return func() tea.Msg {
+		count, err := core.GetCachedDocPageCount(name)
+		return pageCountMsg{name: name, count: count, err: err}
+	}
+}
+
+func makeSearchCommand(query string) func() tea.Msg {
+	return func() tea.Msg {
+		return searchMsg{
+			query:   query,
+			results: core.SearchDocuments(query),
 		}
 	}
+}
 
+func makeWarmSearchIndexCommand() func() tea.Msg {
 	return func() tea.Msg {
-		message, err := action()
-		return ocrMessageSingle{message: message, err: err}
+		return searchIndexMsg{err: core.WarmSearchIndex()}
 	}
 }
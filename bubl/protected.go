@@ -0,0 +1,108 @@
+package bubl
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/zmnpl/ding/core"
+)
+
+type unprotectMsg struct {
+	name        string
+	newName     string
+	sender      string
+	fromKeyring bool
+	remembered  bool
+	err         error
+	// the password worked but could not be stored in the keyring
+	rememberErr error
+}
+
+// makeUnprotectCommand removes the password of a protected inbound pdf; without password the keyring is tried
+// a password which works is stored in the keyring for the sender to remember it for, if there is one
+func makeUnprotectCommand(name, password, remember string) func() tea.Msg {
+	return func() tea.Msg {
+		if password == "" {
+			newName, sender, err := core.RemovePdfPasswordFromKeyring(name)
+			return unprotectMsg{name: name, newName: newName, sender: sender, fromKeyring: true, err: err}
+		}
+		newName, err := core.RemovePdfPassword(name, password)
+		msg := unprotectMsg{name: name, newName: newName, err: err}
+		if err == nil && remember != "" {
+			msg.sender = remember
+			msg.rememberErr = core.AddToKeyring(core.KeyringEntry{Sender: remember, Password: password})
+			msg.remembered = msg.rememberErr == nil
+		}
+		return msg
+	}
+}
+
+func (m model) focusPassword(name string) model {
+	m.focus = FOCUS_PASSWORD
+	m.protectedName = name
+
+	m.inboundList.Styles.Title = myStyle.titleStyle
+	m.passwordInput.SetValue("")
+	m.passwordInput.Focus()
+	m.rememberInput.Blur()
+
+	return m
+}
+
+// togglePasswordInput switches between the password and the sender to remember it for
+func (m model) togglePasswordInput() model {
+	if m.passwordInput.Focused() {
+		m.passwordInput.Blur()
+		m.rememberInput.Focus()
+		return m
+	}
+	m.rememberInput.Blur()
+	m.passwordInput.Focus()
+	return m
+}
+
+// handleUnprotect reacts to the outcome of removing a password
+func (m model) handleUnprotect(msg unprotectMsg) (model, tea.Cmd) {
+	if msg.err == nil {
+		m.statusMessage = fmt.Sprintf("Removed the password of \"%s\" into \"%s\"", msg.name, msg.newName)
+		switch {
+		case msg.fromKeyring:
+			m.statusMessage += " with the password of " + msg.sender
+		case msg.remembered:
+			m.statusMessage += ", remembered the password for " + msg.sender
+		case msg.rememberErr != nil:
+			m.statusMessage += fmt.Sprintf(", but could not remember the password: %s", msg.rememberErr)
+		}
+		m.passwordInput.SetValue("")
+		m.rememberInput.SetValue("")
+		m = m.focusInbound()
+		cmd := m.inboundList.SetItems(InboundItemsAsBubblesList())
+		return m, cmd
+	}
+
+	switch {
+	case msg.fromKeyring:
+		m = m.focusPassword(msg.name)
+		m.statusMessage = fmt.Sprintf("%s - enter the password, tab remembers it for a sender, esc cancels", msg.err)
+	case msg.err == core.ErrWrongPassword:
+		m = m.focusPassword(msg.name)
+		m.statusMessage = "Wrong password, try again - esc cancels"
+	default:
+		m = m.focusInbound()
+		m.statusMessage = msg.err.Error()
+	}
+	return m, nil
+}
+
+func (m model) passwordSection() string {
+	passwordHeader, rememberHeader := myStyle.titleStyleSelected, myStyle.titleStyle
+	if m.rememberInput.Focused() {
+		passwordHeader, rememberHeader = myStyle.titleStyle, myStyle.titleStyleSelected
+	}
+	padding := strings.Repeat(" ", lipgloss.Width(m.timeStamp))
+	return lipgloss.NewStyle().Margin(0, 0, 0, 0).Padding(0, 0).Render(lipgloss.JoinVertical(lipgloss.Left,
+		"  "+passwordHeader.Render("Password")+"       "+padding+m.passwordInput.View(),
+		"  "+rememberHeader.Render("Remember as")+"    "+padding+m.rememberInput.View()))
+}
@@ -0,0 +1,187 @@
+package bubl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/zmnpl/ding/core"
+)
+
+// RunRetention starts an interactive screen to go through documents which are past their retention period
+// and move them to the trash one by one or all at once
+func RunRetention(expired []core.ExpiredDocument) error {
+	p := tea.NewProgram(retentionModel{expired: expired, help: help.New()})
+	return p.Start()
+}
+
+type retentionModel struct {
+	expired []core.ExpiredDocument
+	cursor  int
+	width   int
+	busy    bool
+	// documents which are moved to the trash right now
+	trashing []string
+
+	statusMessage string
+	help          help.Model
+}
+
+func (m retentionModel) Init() tea.Cmd {
+	return nil
+}
+
+// without removes the given documents from the list
+func (m retentionModel) without(paths []string) retentionModel {
+	gone := make(map[string]bool)
+	for _, p := range paths {
+		gone[p] = true
+	}
+	left := make([]core.ExpiredDocument, 0, len(m.expired))
+	for _, e := range m.expired {
+		if !gone[e.Path] {
+			left = append(left, e)
+		}
+	}
+	m.expired = left
+	if m.cursor >= len(m.expired) && m.cursor > 0 {
+		m.cursor = len(m.expired) - 1
+	}
+	return m
+}
+
+func (m retentionModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.help.Width = msg.Width
+
+	case trashMsg:
+		m.busy = false
+		m.statusMessage = msg.messageText
+		if msg.err == nil {
+			m = m.without(m.trashing)
+		}
+		m.trashing = nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, retentionKeys.Quit):
+			return m, tea.Quit
+		}
+
+		if len(m.expired) == 0 || m.busy {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, retentionKeys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, retentionKeys.Down):
+			if m.cursor < len(m.expired)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, retentionKeys.Keep):
+			m.statusMessage = fmt.Sprintf("Kept %s", m.expired[m.cursor].Path)
+			m = m.without([]string{m.expired[m.cursor].Path})
+		case key.Matches(msg, retentionKeys.Trash):
+			m.trashing = []string{m.expired[m.cursor].Path}
+			m.busy = true
+			m.statusMessage = "Moving file to the trash..."
+			return m, makeTrashCommand(m.trashing)
+		case key.Matches(msg, retentionKeys.TrashAll):
+			m.trashing = make([]string, len(m.expired))
+			for i, e := range m.expired {
+				m.trashing[i] = e.Path
+			}
+			m.busy = true
+			m.statusMessage = "Moving files to the trash..."
+			return m, makeTrashCommand(m.trashing)
+		}
+	}
+
+	return m, nil
+}
+
+func (m retentionModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(myStyle.titleStyleSelected.Render("Retention") + " " +
+		myStyle.textDimmedStyle.Render(fmt.Sprintf("%v documents past their retention period", len(m.expired))) +
+		"\n\n")
+
+	if len(m.expired) == 0 {
+		b.WriteString(myStyle.itemStyle.Render("Nothing to dispose of.") + "\n")
+	}
+	for i, e := range m.expired {
+		info := fmt.Sprintf("dated %s • expired %s • %s", e.Start.Format("2006-01-02"), e.Expired.Format("2006-01-02"), e.Rule)
+		line := myStyle.itemStyle.Render(e.Path)
+		if i == m.cursor {
+			line = myStyle.itemStyleSelected.Render("> " + e.Path)
+		}
+		b.WriteString(line + " " + myStyle.textDimmedStyle.Render(info) + "\n")
+	}
+
+	statusBar := lipgloss.NewStyle().Margin(1, 0, 0, 0).Render(
+		myStyle.titleStyle.Render("$ ") + myStyle.statusBarStyle.Render(m.statusMessage))
+	helpView := lipgloss.NewStyle().Margin(1, 0, 0, 0).Render(m.help.FullHelpView(retentionKeys.FullHelp()))
+
+	return myStyle.docStyle.Copy().MarginLeft(2).Render(b.String() + statusBar + "\n" + helpView)
+}
+
+// -----------------------------------------------------------------------------
+// help
+
+type retentionKeyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	Trash    key.Binding
+	TrashAll key.Binding
+	Keep     key.Binding
+	Quit     key.Binding
+}
+
+func (k retentionKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Trash, k.Quit}
+}
+
+func (k retentionKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Trash, k.TrashAll, k.Keep},
+		{k.Up, k.Down},
+		{k.Quit},
+	}
+}
+
+var retentionKeys = retentionKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	Trash: key.NewBinding(
+		key.WithKeys("enter", "t"),
+		key.WithHelp("enter", "trash selected"),
+	),
+	TrashAll: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "trash all listed"),
+	),
+	Keep: key.NewBinding(
+		key.WithKeys("s", "n"),
+		key.WithHelp("s", "keep, remove from list"),
+	),
+	Quit: key.NewBinding(
+		key.WithKeys("q", "esc", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
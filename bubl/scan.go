@@ -0,0 +1,48 @@
+package bubl
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmnpl/ding/core"
+)
+
+type scanMsg struct {
+	result core.ScanResult
+	err    error
+}
+
+// makeScanCommand scans with the default profile into inbound
+func makeScanCommand() func() tea.Msg {
+	return func() tea.Msg {
+		profile, err := core.GetScanProfile("")
+		if err != nil {
+			return scanMsg{err: err}
+		}
+		result, err := core.Scan(profile)
+		return scanMsg{result: result, err: err}
+	}
+}
+
+// handleScan shows the new scan in the inbound list
+func (m model) handleScan(msg scanMsg) (model, tea.Cmd) {
+	m.scanning = false
+	if msg.err != nil {
+		m.statusMessage = fmt.Sprintf("Could not scan: %s", msg.err)
+		return m, nil
+	}
+
+	m.statusMessage = fmt.Sprintf("Scanned %v page(s) into \"%s\"", msg.result.Pages, msg.result.Name)
+	if msg.result.OCRError != "" {
+		m.statusMessage += "; " + msg.result.OCRError
+	}
+	cmd := m.inboundList.SetItems(InboundItemsAsBubblesList())
+	for i, itm := range m.inboundList.Items() {
+		if itm.(inboundItem).name == msg.result.Name {
+			m.inboundList.Select(i)
+			break
+		}
+	}
+	m = m.updatePreviewViews()
+	return m, cmd
+}
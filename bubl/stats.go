@@ -0,0 +1,112 @@
+package bubl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/zmnpl/ding/core"
+)
+
+// number of months shown in the ingest chart of the dashboard
+const dashboardMonths = 12
+
+type statsMsg struct {
+	stats core.Stats
+	err   error
+}
+
+func makeStatsCommand() func() tea.Msg {
+	return func() tea.Msg {
+		stats, err := core.GetStats(5)
+		return statsMsg{stats: stats, err: err}
+	}
+}
+
+func (m model) focusDashboard() (model, tea.Cmd) {
+	m.focus = FOCUS_DASHBOARD
+	m.statusMessage = "Collecting statistics..."
+
+	m.inboundList.Styles.Title = myStyle.titleStyle
+	m.directoryList.Styles.Title = myStyle.titleStyle
+	m.newNameHeaderStyle = myStyle.titleStyle
+	m.tagHeaderStyle = myStyle.titleStyle
+
+	return m, makeStatsCommand()
+}
+
+// dashboardSection renders the statistics of the archive; it is read only
+func (m model) dashboardSection() string {
+	if m.stats == nil {
+		return lipgloss.NewStyle().Margin(1, 0, 0, 2).Render(
+			myStyle.titleStyleSelected.Render("Dashboard") + "\n\n" + m.spinner.View() + " reading your documents ...")
+	}
+	s := *m.stats
+	dimmed := myStyle.textDimmedStyle.Render
+
+	// overview
+	backlog := "empty"
+	if s.InboundFiles > 0 {
+		backlog = fmt.Sprintf("%v files, %s, oldest waits %s", s.InboundFiles, core.FormatSize(s.InboundSize), core.FormatAge(s.InboundAge()))
+	}
+	overview := strings.Join([]string{
+		myStyle.titleStyle.Render("Archive"),
+		fmt.Sprintf("%v documents %s", s.Documents, dimmed(core.FormatSize(s.Size))),
+		fmt.Sprintf("%v without text layer %s", s.WithoutText, dimmed(fmt.Sprintf("(%.0f%%)", s.WithoutTextShare()*100))),
+		"",
+		myStyle.titleStyle.Render("Inbound"),
+		backlog,
+	}, "\n")
+
+	// directories
+	nameWidth := 0
+	for _, d := range s.Directories {
+		if len(d.Name) > nameWidth {
+			nameWidth = len(d.Name)
+		}
+	}
+	directories := []string{myStyle.titleStyle.Render("Directories")}
+	for _, d := range s.Directories {
+		directories = append(directories, fmt.Sprintf("%-*s %5v %s", nameWidth, d.Name, d.Documents, dimmed(core.FormatSize(d.Size))))
+	}
+
+	// ingests per month as bar chart
+	months := s.IngestsPerMonth
+	if len(months) > dashboardMonths {
+		months = months[len(months)-dashboardMonths:]
+	}
+	max := 0
+	for _, mo := range months {
+		if mo.Documents > max {
+			max = mo.Documents
+		}
+	}
+	ingests := []string{myStyle.titleStyle.Render("Ingests per month")}
+	for _, mo := range months {
+		bar := strings.Repeat("█", mo.Documents*20/max)
+		ingests = append(ingests, fmt.Sprintf("%s %s %v", dimmed(mo.Month), myStyle.highlightStyle.Render(bar), mo.Documents))
+	}
+
+	// largest files
+	largest := []string{myStyle.titleStyle.Render("Largest files")}
+	for _, f := range s.Largest {
+		name := filepath.Join(filepath.Dir(f.Path), core.RemoveTimeStampFilePrefix(filepath.Base(f.Path)))
+		if m.width > 20 {
+			name = truncate.StringWithTail(name, uint(m.width-16), "…")
+		}
+		largest = append(largest, fmt.Sprintf("%s %s", name, dimmed(core.FormatSize(f.Size))))
+	}
+
+	column := lipgloss.NewStyle().Margin(0, 4, 1, 0).Render
+	return lipgloss.NewStyle().Margin(1, 0, 0, 2).Render(
+		myStyle.titleStyleSelected.Render("Dashboard") + "\n\n" +
+			lipgloss.JoinHorizontal(lipgloss.Top,
+				column(overview),
+				column(strings.Join(directories, "\n")),
+				column(strings.Join(ingests, "\n")),
+			) + "\n" +
+			strings.Join(largest, "\n"))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
 	"github.com/zmnpl/ding/core"
 )
 
@@ -62,6 +63,7 @@ type appStyle struct {
 	paginationStyle    lipgloss.Style
 	statusBarStyle     lipgloss.Style
 	textDimmedStyle    lipgloss.Style
+	searchHitStyle     lipgloss.Style
 }
 
 func init() {
@@ -101,6 +103,7 @@ func defaultStyle() (s appStyle) {
 	s.statusBarStyle = lipgloss.NewStyle().Foreground(s.COLOR_DIMMED_TEXT)
 
 	s.textDimmedStyle = lipgloss.NewStyle().Foreground(s.COLOR_DIMMED_TEXT)
+	s.searchHitStyle = lipgloss.NewStyle().Foreground(s.COLOR_ACTIVE_ITEM).Bold(true)
 	return
 }
 
@@ -150,5 +153,12 @@ func (d dimmedDelegate) Render(w io.Writer, m list.Model, index int, listItem li
 		str = fmt.Sprintf("%s...", str[:30])
 	}
 
+	// metadata from the sidecar as far as there is room
+	if bf, ok := listItem.(directoryFile); ok {
+		if summary := bf.SidecarSummary(); summary != "" && m.Width() > 2 {
+			str = truncate.StringWithTail(fmt.Sprintf("%s  %s", str, summary), uint(m.Width()-2), "…")
+		}
+	}
+
 	fmt.Fprintf(w, myStyle.textDimmedStyle.Render(myStyle.itemStyle.Render(str)))
 }
@@ -0,0 +1,78 @@
+package bubl
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/zmnpl/ding/core"
+	"github.com/zmnpl/ding/termimg"
+)
+
+type thumbnailMsg struct {
+	name string
+	err  error
+}
+
+func makeThumbnailCommand(name string) func() tea.Msg {
+	return func() tea.Msg {
+		_, err := core.GetDocThumbnail(name)
+		return thumbnailMsg{name: name, err: err}
+	}
+}
+
+// halfBlockThumbnail renders the image with unicode half blocks in the given width
+func halfBlockThumbnail(img image.Image, width int) string {
+	lines := termimg.HalfBlocks(img, width, func(top, bottom color.RGBA) string {
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", top.R, top.G, top.B))).
+			Background(lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", bottom.R, bottom.G, bottom.B))).
+			Render("▀")
+	})
+	return strings.Join(lines, "\n")
+}
+
+// makeGraphicsCommand shows the thumbnail of the given file full screen with the terminals graphics protocol
+// the image is not placed into the preview pane; bubbletea redraws the whole screen and would
+// overwrite it, so the ui is suspended while the image is shown
+func makeGraphicsCommand(name, protocol string) tea.Cmd {
+	img, err := core.GetDocThumbnail(name)
+	if err != nil {
+		return func() tea.Msg { return thumbnailMsg{name: name, err: err} }
+	}
+	encoded, err := termimg.Encode(img, protocol)
+	if err != nil {
+		return func() tea.Msg { return thumbnailMsg{name: name, err: err} }
+	}
+
+	return tea.Exec(&graphicsView{image: encoded, protocol: protocol}, func(err error) tea.Msg {
+		return thumbnailMsg{name: name, err: err}
+	})
+}
+
+// graphicsView writes an image onto the otherwise empty terminal and waits for the user to return
+type graphicsView struct {
+	image    string
+	protocol string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func (g *graphicsView) Run() error {
+	fmt.Fprint(g.stdout, "\x1b[2J\x1b[H"+g.image+"\r\n\r\n  press enter to return ")
+	_, err := bufio.NewReader(g.stdin).ReadString('\n')
+	if g.protocol == termimg.PROTOCOL_KITTY {
+		fmt.Fprint(g.stdout, termimg.KITTY_CLEAR)
+	}
+	fmt.Fprint(g.stdout, "\x1b[2J\x1b[H")
+	return err
+}
+
+func (g *graphicsView) SetStdin(r io.Reader)  { g.stdin = r }
+func (g *graphicsView) SetStdout(w io.Writer) { g.stdout = w }
+func (g *graphicsView) SetStderr(w io.Writer) {}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/fs"
 	"math"
+	"path/filepath"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -12,7 +14,13 @@ import (
 
 func (m model) docPreview() string {
 	if m.selectedInbound != nil && m.selectedInbound.(inboundItem).file != nil {
-		return core.GetCachedDocPreview(m.selectedInbound.(inboundItem).file.Name())
+		if m.previewPage <= 1 && !m.previewLayout {
+			return core.GetCachedDocPreview(m.selectedInbound.(inboundItem).file.Name())
+		}
+		if preview, ok := core.GetCachedDocPagePreview(m.selectedInbound.(inboundItem).file.Name(), m.previewPage, m.previewLayout); ok {
+			return preview
+		}
+		return "loading ..."
 	}
 	return "-"
 }
@@ -29,18 +37,25 @@ func (m model) updateDirectoryFiles() model {
 // -----------------------------------------------------------------------------
 // directory file
 type directoryFile struct {
-	name string
-	size int64
-	file fs.DirEntry
+	name    string
+	size    int64
+	file    fs.DirEntry
+	sidecar *core.Sidecar
+	tags    []string
 }
 
-func NewDirectoryFile(file fs.DirEntry) directoryFile {
+func NewDirectoryFile(directory string, file fs.DirEntry) directoryFile {
 	info, _ := file.Info()
-	return directoryFile{
+	bf := directoryFile{
 		name: info.Name(),
 		size: info.Size(),
 		file: file,
+		tags: core.GetCachedTags(filepath.Join(directory, file.Name())),
+	}
+	if sidecar, ok := core.GetCachedSidecar(filepath.Join(directory, file.Name())); ok {
+		bf.sidecar = &sidecar
 	}
+	return bf
 }
 
 func (bf directoryFile) Title() string {
@@ -49,10 +64,44 @@ func (bf directoryFile) Title() string {
 
 func (bf directoryFile) Description() string {
 	sizeMiBs := math.Round(float64(bf.size)*100/1048576) / 100
+	if summary := bf.SidecarSummary(); summary != "" {
+		return fmt.Sprintf("(%v Mib) %s", sizeMiBs, summary)
+	}
 	return fmt.Sprintf("(%v Mib)", sizeMiBs)
 }
 
-func (bf directoryFile) FilterValue() string { return bf.name }
+// SidecarSummary returns the metadata from the sidecar in one line; document date, ocr status, tags and notes
+func (bf directoryFile) SidecarSummary() string {
+	if bf.sidecar == nil {
+		return ""
+	}
+	parts := make([]string, 0, 4)
+	if bf.sidecar.DocumentDate != "" {
+		parts = append(parts, bf.sidecar.DocumentDate)
+	}
+	if bf.sidecar.DueDate != "" {
+		parts = append(parts, "due "+bf.sidecar.DueDate)
+	}
+	if bf.sidecar.OCR == core.OCR_STATUS_NONE {
+		parts = append(parts, "no text")
+	}
+	for _, tag := range bf.sidecar.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if bf.sidecar.Notes != "" {
+		parts = append(parts, strings.SplitN(bf.sidecar.Notes, "\n", 2)[0])
+	}
+	return strings.Join(parts, " • ")
+}
+
+// FilterValue is the name and the tags as #tag, so the list can be filtered by both
+func (bf directoryFile) FilterValue() string {
+	value := bf.name
+	for _, tag := range bf.tags {
+		value += " #" + tag
+	}
+	return value
+}
 
 func (bf directoryFile) RenderLength() int {
 	return len(bf.Title()) + len(bf.Description()) + 3 // "> " + " "
@@ -60,15 +109,15 @@ func (bf directoryFile) RenderLength() int {
 
 func (b directory) GetDirectoryFiles() []list.Item {
 	var files []fs.DirEntry
+	name := "-"
 	if b.dir != nil {
-		files, _ = core.GetCachedDirectoryFiles(b.dir.Name())
-	} else {
-		files, _ = core.GetCachedDirectoryFiles("-")
+		name = b.dir.Name()
 	}
+	files, _ = core.GetCachedDirectoryFiles(name)
 
 	result := make([]list.Item, len(files))
 	for i, file := range files {
-		result[i] = NewDirectoryFile(file)
+		result[i] = NewDirectoryFile(name, file)
 	}
 
 	return result
@@ -77,17 +126,20 @@ func (b directory) GetDirectoryFiles() []list.Item {
 // -----------------------------------------------------------------------------
 // inbound item
 type inboundItem struct {
-	name string
-	size int64
-	file fs.DirEntry
+	name      string
+	size      int64
+	file      fs.DirEntry
+	archived  []string
+	protected bool
 }
 
 func NewInboundItem(file fs.DirEntry) inboundItem {
 	info, _ := file.Info()
 	return inboundItem{
-		name: file.Name(),
-		size: info.Size(),
-		file: file,
+		name:      file.Name(),
+		size:      info.Size(),
+		file:      file,
+		protected: core.GetCachedPasswordProtected(file.Name()),
 	}
 }
 
@@ -96,8 +148,13 @@ func (i inboundItem) Title() string {
 }
 
 func (i inboundItem) Description() string {
-	sizeMiBs := math.Round(float64(i.size)*100/1048576) / 100
-	return fmt.Sprintf("(%v Mib)", sizeMiBs)
+	if i.protected {
+		return fmt.Sprintf("(%s, password protected)", core.FormatSize(i.size))
+	}
+	if len(i.archived) > 0 {
+		return fmt.Sprintf("(%s, archived)", core.FormatSize(i.size))
+	}
+	return fmt.Sprintf("(%s)", core.FormatSize(i.size))
 }
 
 func (i inboundItem) FilterValue() string {
@@ -159,6 +216,39 @@ func DirectoriesAsBubblesList() []list.Item {
 	return items
 }
 
+// -----------------------------------------------------------------------------
+// search result
+type searchResult struct {
+	result core.SearchResult
+}
+
+func NewSearchResult(result core.SearchResult) searchResult {
+	return searchResult{result: result}
+}
+
+func (r searchResult) Title() string {
+	return core.RemoveTimeStampFilePrefix(r.result.Name)
+}
+
+func (r searchResult) Description() string {
+	return fmt.Sprintf("(%s, score %v)", r.result.Directory, r.result.Score)
+}
+
+func (r searchResult) FilterValue() string { return r.result.Name }
+
+func (r searchResult) RenderLength() int {
+	return len(r.Title()) + len(r.Description()) + 3
+}
+
+func SearchResultsAsBubblesList(results []core.SearchResult) []list.Item {
+	items := make([]list.Item, len(results))
+	for i, result := range results {
+		items[i] = NewSearchResult(result)
+	}
+
+	return items
+}
+
 // -----------------------------------------------------------------------------
 // help
 
@@ -172,6 +262,18 @@ type keyMap struct {
 	OpenPreview key.Binding
 	OcrSingle   key.Binding
 	OcrMultiple key.Binding
+	PrevPage    key.Binding
+	NextPage    key.Binding
+	Layout      key.Binding
+	Thumbnail   key.Binding
+	Graphics    key.Binding
+	Search      key.Binding
+	JumpToDir   key.Binding
+	Tags        key.Binding
+	Dashboard   key.Binding
+	Optimize    key.Binding
+	Unprotect   key.Binding
+	Scan        key.Binding
 	Quit        key.Binding
 }
 
@@ -188,10 +290,43 @@ func (k keyMap) FullHelp() [][]key.Binding {
 		{k.Confirm, k.Quit},       // first column
 		{k.Up, k.Down},            // second column
 		{k.OpenPreview, k.Filter}, //...
-		{k.OcrSingle, k.OcrMultiple},
+		{k.OcrSingle, k.OcrMultiple, k.Optimize, k.Unprotect},
+		{k.PrevPage, k.NextPage, k.Layout},
+		{k.Thumbnail, k.Graphics},
+		{k.Search, k.JumpToDir},
+		{k.Tags, k.Dashboard, k.Scan},
 	}
 }
 
+// gatedKeys maps keys to the feature they need
+var gatedKeys = map[string]string{
+	"f1":     core.FEATURE_OPEN,
+	"f2":     core.FEATURE_OCR,
+	"f3":     core.FEATURE_OCR,
+	"f6":     core.FEATURE_THUMBNAIL,
+	"f7":     core.FEATURE_THUMBNAIL,
+	"f9":     core.FEATURE_OPTIMIZE,
+	"f10":    core.FEATURE_UNPROTECT,
+	"ctrl+s": core.FEATURE_SCAN,
+}
+
+// gateKeys hides the key bindings of features whose tools are missing from the help
+func gateKeys() {
+	core.CheckFeatures()
+	for _, binding := range []*key.Binding{&keys.OpenPreview, &keys.OcrSingle, &keys.OcrMultiple, &keys.Thumbnail, &keys.Graphics, &keys.Optimize, &keys.Unprotect, &keys.Scan} {
+		if feature, ok := gatedKeys[binding.Keys()[0]]; ok {
+			binding.SetEnabled(core.FeatureAvailable(feature))
+		}
+	}
+}
+
+// keyIsGated reports whether the key of the feature would act in the current focus, but the tools are missing
+// ocr runs from everywhere, opening from inbound and search, the rest only from inbound
+func (m model) keyIsGated(feature string) bool {
+	acts := m.focus == FOCUS_INBOUND || feature == core.FEATURE_OCR || (feature == core.FEATURE_OPEN && m.focus == FOCUS_SEARCH)
+	return acts && !core.FeatureAvailable(feature)
+}
+
 var keys = keyMap{
 	Up: key.NewBinding(
 		key.WithKeys("up", "k"),
@@ -221,6 +356,54 @@ var keys = keyMap{
 		key.WithKeys("f3"),
 		key.WithHelp("f3", "ocr all"),
 	),
+	PrevPage: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "previous page"),
+	),
+	NextPage: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next page"),
+	),
+	Layout: key.NewBinding(
+		key.WithKeys("f4"),
+		key.WithHelp("f4", "toggle raw/layout preview"),
+	),
+	Thumbnail: key.NewBinding(
+		key.WithKeys("f6"),
+		key.WithHelp("f6", "toggle text/thumbnail"),
+	),
+	Graphics: key.NewBinding(
+		key.WithKeys("f7"),
+		key.WithHelp("f7", "show first page full screen"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("ctrl+f"),
+		key.WithHelp("ctrl+f", "search archive"),
+	),
+	JumpToDir: key.NewBinding(
+		key.WithKeys("ctrl+g"),
+		key.WithHelp("ctrl+g", "go to directory of result"),
+	),
+	Tags: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "tags / complete tag"),
+	),
+	Optimize: key.NewBinding(
+		key.WithKeys("f9"),
+		key.WithHelp("f9", "make selected pdf smaller"),
+	),
+	Unprotect: key.NewBinding(
+		key.WithKeys("f10"),
+		key.WithHelp("f10", "remove pdf password"),
+	),
+	Dashboard: key.NewBinding(
+		key.WithKeys("f8"),
+		key.WithHelp("f8", "dashboard"),
+	),
+	Scan: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "scan into inbound"),
+	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "esc", "ctrl+c"),
 		key.WithHelp("q", "quit"),
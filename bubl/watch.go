@@ -0,0 +1,43 @@
+package bubl
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zmnpl/ding/core"
+)
+
+type inboundTickMsg struct{}
+
+// pollInbound looks for new inbound files after a while, e.g. uploads from ding serve
+func pollInbound() tea.Cmd {
+	return tea.Tick(core.INBOUND_POLL_INTERVAL, func(time.Time) tea.Msg {
+		return inboundTickMsg{}
+	})
+}
+
+// refreshInbound reloads the inbound list if files came or went, keeping the selection
+// it waits while the user is busy with a file, so the list does not change under their hands
+func (m model) refreshInbound() (model, tea.Cmd) {
+	if m.focus != FOCUS_INBOUND || m.inboundList.FilterState() == list.Filtering {
+		return m, pollInbound()
+	}
+	fingerprint, err := core.InboundFingerprint()
+	if err != nil || fingerprint == m.inboundFingerprint {
+		return m, pollInbound()
+	}
+	m.inboundFingerprint = fingerprint
+
+	selected := m.selectedInboundName()
+	cmd := m.inboundList.SetItems(InboundItemsAsBubblesList())
+	for i, itm := range m.inboundList.Items() {
+		if itm.(inboundItem).name == selected {
+			m.inboundList.Select(i)
+			break
+		}
+	}
+	m = m.markArchivedInboundItems()
+	m = m.updatePreviewViews()
+	return m, tea.Batch(cmd, pollInbound())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// command is a sub command of ding, e.g. "ding dedupe"
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"deadlines":   {"list upcoming due dates of your documents and export them as calendar", runDeadlines},
+	"dedupe":      {"report duplicate and near-duplicate documents in your documents directory", runDedupe},
+	"doctor":      {"check external tools, ocr languages and directories", runDoctor},
+	"import-mail": {"put pdf and image attachments of a maildir, mbox or .eml files into inbound", runImportMail},
+	"ingest":      {"file inbound documents into a directory through the configured pipeline", runIngest},
+	"list":        {"list documents across all directories, optionally only those with a tag", runList},
+	"lock":        {"forget the key of encrypted directories before it expires", runLock},
+	"log":         {"show the git history of your documents directory", runLog},
+	"unprotect":   {"remove the password of protected pdfs in inbound, using the keyring or asking for it", runUnprotect},
+	"unlock":      {"unlock encrypted directories for a while", runUnlock},
+	"serve":       {"accept uploads from phones into inbound over the local network, until stopped", runServe},
+	"scan":        {"scan documents into inbound with a configured scanner profile", runScan},
+	"stats":       {"show statistics about your documents and the inbound backlog", runStats},
+	"retention":   {"list documents which are past their retention period and optionally trash them", runRetention},
+}
+
+// printCommands lists all sub commands in a stable order
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\nCommands:\n")
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, commands[name].description)
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	TEXT_AUTO    = "auto"
+	TEXT_POPPLER = "poppler"
+	TEXT_NATIVE  = "native"
+	TEXT_MUTOOL  = "mutool"
+
+	OCR_OCRMYPDF  = "ocrmypdf"
+	OCR_TESSERACT = "tesseract"
+
+	CONVERT_IMG2PDF = "img2pdf"
+	CONVERT_MUTOOL  = "mutool"
+
+	DEFAULT_OPENER = "xdg-open"
+
+	// resolution pages are rendered with for tesseract
+	OCR_DPI = 300
+)
+
+// TextExtractor reads the text layer of pdfs
+type TextExtractor interface {
+	// PageText returns the text of the given page, page 0 means all pages; with layout the physical
+	// layout of the text is kept if the extractor can do that
+	PageText(path string, page int, layout bool) (string, error)
+	PageCount(path string) (int, error)
+}
+
+// OCREngine adds a text layer to scanned pdfs
+type OCREngine interface {
+	// Ocr replaces the pdf at the given path with a copy that has a text layer
+	Ocr(path, language string) error
+}
+
+// ImageOCREngine recognises text in images
+type ImageOCREngine interface {
+	ImageText(image, language string) (string, error)
+	// ImageToPdf writes a searchable pdf of the image
+	ImageToPdf(image, pdf, language string) error
+}
+
+// Opener shows documents to the user
+type Opener interface {
+	Open(path string) error
+}
+
+// Converter turns images into pdfs
+type Converter interface {
+	// ToPdf writes a pdf with one page per image
+	ToPdf(pdf string, images ...string) error
+}
+
+// toolUser is implemented by backends which run external tools; backends without it need nothing installed
+type toolUser interface {
+	Tools() []string
+}
+
+// the backends ding uses; they are set from the config and can be replaced by fakes, e.g. to ingest without any tools installed
+var (
+	Extractor TextExtractor  = chainExtractor{popplerExtractor{}, nativeExtractor{}}
+	OCR       OCREngine      = ocrmypdfEngine{}
+	ImageOCR  ImageOCREngine = tesseractImageEngine{}
+	DocOpener Opener         = commandOpener{DEFAULT_OPENER}
+	ImageConv Converter      = img2pdfConverter{}
+)
+
+// BackendConfig selects the tools ding uses; empty values keep the defaults
+type BackendConfig struct {
+	// auto (default), poppler, native or mutool; auto uses poppler and falls back to the built-in reader
+	Text string `json:"text,omitempty"`
+	// ocrmypdf (default) or tesseract
+	OCR string `json:"ocr,omitempty"`
+	// command documents are opened with, xdg-open by default
+	Open string `json:"open,omitempty"`
+	// img2pdf (default) or mutool
+	Convert string `json:"convert,omitempty"`
+}
+
+func (c BackendConfig) validate() error {
+	switch c.Text {
+	case "", TEXT_AUTO, TEXT_POPPLER, TEXT_NATIVE, TEXT_MUTOOL:
+	default:
+		return fmt.Errorf("unknown text extractor %q", c.Text)
+	}
+	switch c.OCR {
+	case "", OCR_OCRMYPDF, OCR_TESSERACT:
+	default:
+		return fmt.Errorf("unknown ocr engine %q", c.OCR)
+	}
+	switch c.Convert {
+	case "", CONVERT_IMG2PDF, CONVERT_MUTOOL:
+	default:
+		return fmt.Errorf("unknown converter %q", c.Convert)
+	}
+	if c.Open != "" && len(strings.Fields(c.Open)) == 0 {
+		return fmt.Errorf("the open command is empty")
+	}
+	return nil
+}
+
+// apply sets the backends according to the config
+func (c BackendConfig) apply() {
+	switch c.Text {
+	case TEXT_POPPLER:
+		Extractor = popplerExtractor{}
+	case TEXT_NATIVE:
+		Extractor = nativeExtractor{}
+	case TEXT_MUTOOL:
+		Extractor = mutoolExtractor{}
+	default:
+		Extractor = chainExtractor{popplerExtractor{}, nativeExtractor{}}
+	}
+
+	switch c.OCR {
+	case OCR_TESSERACT:
+		OCR = tesseractEngine{}
+	default:
+		OCR = ocrmypdfEngine{}
+	}
+
+	switch c.Convert {
+	case CONVERT_MUTOOL:
+		ImageConv = mutoolConverter{}
+	default:
+		ImageConv = img2pdfConverter{}
+	}
+
+	DocOpener = commandOpener{DEFAULT_OPENER}
+	if c.Open != "" {
+		DocOpener = commandOpener{c.Open}
+	}
+}
+
+// backendTools returns the external tools the backend needs
+func backendTools(backend interface{}) []string {
+	if b, ok := backend.(toolUser); ok {
+		return b.Tools()
+	}
+	return []string{}
+}
+
+// missingTools returns those of the tools which are not installed
+func missingTools(tools []string) []string {
+	missing := make([]string, 0)
+	for _, tool := range tools {
+		if !checkDep(tool) {
+			missing = append(missing, tool)
+		}
+	}
+	return missing
+}
+
+// run runs the command and returns its output; the error contains what the tool complained about
+func run(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return output, fmt.Errorf("%s failed: %v %s", name, err, strings.TrimSpace(string(exitError.Stderr)))
+		}
+		return output, fmt.Errorf("%s failed: %v", name, err)
+	}
+	return output, nil
+}
+
+// text extractors
+
+// chainExtractor asks one extractor after the other until one succeeds
+type chainExtractor []TextExtractor
+
+func (c chainExtractor) PageText(path string, page int, layout bool) (string, error) {
+	errs := make([]string, 0, len(c))
+	for _, e := range c {
+		text, err := e.PageText(path, page, layout)
+		if err == nil {
+			return text, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+func (c chainExtractor) PageCount(path string) (int, error) {
+	errs := make([]string, 0, len(c))
+	for _, e := range c {
+		pages, err := e.PageCount(path)
+		if err == nil {
+			return pages, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// popplerExtractor runs pdftotext and pdfinfo
+type popplerExtractor struct{}
+
+func (popplerExtractor) Tools() []string {
+	return []string{"pdftotext", "pdfinfo"}
+}
+
+func (popplerExtractor) PageText(path string, page int, layout bool) (string, error) {
+	args := []string{path, "-"}
+	if page > 0 {
+		args = append([]string{"-f", fmt.Sprint(page), "-l", fmt.Sprint(page)}, args...)
+	}
+	if layout {
+		args = append([]string{"-layout"}, args...)
+	}
+	output, err := run("pdftotext", args...)
+	return string(output), err
+}
+
+func (popplerExtractor) PageCount(path string) (int, error) {
+	output, err := run("pdfinfo", path)
+	if err != nil {
+		return 0, err
+	}
+	return pagesLine(string(output))
+}
+
+// nativeExtractor uses the built-in pdf reader, it needs nothing installed
+type nativeExtractor struct{}
+
+func (nativeExtractor) PageText(path string, page int, layout bool) (string, error) {
+	return nativePageText(path, page)
+}
+
+func (nativeExtractor) PageCount(path string) (int, error) {
+	return nativePageCount(path)
+}
+
+// mutoolExtractor runs mutool of mupdf; it does not keep the layout
+type mutoolExtractor struct{}
+
+func (mutoolExtractor) Tools() []string {
+	return []string{"mutool"}
+}
+
+func (mutoolExtractor) PageText(path string, page int, layout bool) (string, error) {
+	args := []string{"draw", "-q", "-F", "txt", "-o", "-", path}
+	if page > 0 {
+		args = append(args, fmt.Sprint(page))
+	}
+	output, err := run("mutool", args...)
+	return string(output), err
+}
+
+func (mutoolExtractor) PageCount(path string) (int, error) {
+	output, err := run("mutool", "info", path)
+	if err != nil {
+		return 0, err
+	}
+	return pagesLine(string(output))
+}
+
+// pagesLine finds the page count in the output of pdfinfo or mutool info
+func pagesLine(output string) (int, error) {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "Pages:") {
+			var pages int
+			if _, err := fmt.Sscan(strings.TrimPrefix(line, "Pages:"), &pages); err == nil {
+				return pages, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("could not find page count")
+}
+
+// ocr engines
+
+var (
+	OCRMYPDF_ERRCODES = map[int]string{
+		0:   "Everything worked as expected.",
+		1:   "Invalid arguments, exited with an error.",
+		2:   "The input file does not seem to be a valid PDF.",
+		3:   "An external program required by OCRmyPDF is missing.",
+		4:   "An output file was created, but it does not seem to be a valid PDF. The file will be available.",
+		5:   "The user running OCRmyPDF does not have sufficient permissions to read the input file and write the output file.",
+		6:   "The file already appears to contain text so it may not need OCR. See output message.",
+		7:   "An error occurred in an external program (child process) and OCRmyPDF cannot continue.",
+		8:   "The input PDF is encrypted. OCRmyPDF does not read encrypted PDFs. Use another program such as qpdf to remove encryption.",
+		9:   "A custom configuration file was forwarded to Tesseract using --tesseract-config, and Tesseract rejected this file.",
+		10:  "A valid PDF was created, PDF/A conversion failed. The file will be available.",
+		15:  "Some other error occurred.",
+		130: "The program was interrupted by pressing Ctrl+C.",
+	}
+)
+
+// ocrmypdfEngine runs ocrmypdf, which keeps the pdf as it is and only adds the text layer
+type ocrmypdfEngine struct{}
+
+func (ocrmypdfEngine) Tools() []string {
+	return []string{"ocrmypdf", "tesseract"}
+}
+
+func (ocrmypdfEngine) Ocr(path, language string) error {
+	cmd := exec.Command("ocrmypdf", "-q", "-l", language, "--redo-ocr", path, path)
+	_, err := cmd.CombinedOutput()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("ocr error: %v", OCRMYPDF_ERRCODES[exitError.ExitCode()])
+		}
+		return fmt.Errorf("ocr error: %v", err)
+	}
+	return nil
+}
+
+// tesseractEngine renders the pages with pdftoppm and lets tesseract build a new pdf from them
+// the pages of the result are images, so text which was in the pdf before is recognised again
+type tesseractEngine struct{}
+
+func (tesseractEngine) Tools() []string {
+	return []string{"pdftoppm", "tesseract"}
+}
+
+func (tesseractEngine) Ocr(path, language string) error {
+	tmp, err := os.MkdirTemp("", "ding-ocr-")
+	if err != nil {
+		return fmt.Errorf("ocr error: %s", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if _, err := run("pdftoppm", "-r", fmt.Sprint(OCR_DPI), "-png", path, filepath.Join(tmp, "page")); err != nil {
+		return fmt.Errorf("ocr error: %s", err)
+	}
+	pages, err := filepath.Glob(filepath.Join(tmp, "page*.png"))
+	if err != nil || len(pages) == 0 {
+		return fmt.Errorf("ocr error: no pages rendered")
+	}
+	// pdftoppm pads the page numbers, so they sort
+	sort.Strings(pages)
+	list := filepath.Join(tmp, "pages.txt")
+	if err := os.WriteFile(list, []byte(strings.Join(pages, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("ocr error: %s", err)
+	}
+
+	out := filepath.Join(tmp, "out")
+	if _, err := run("tesseract", list, out, "-l", language, "pdf"); err != nil {
+		return fmt.Errorf("ocr error: %s", err)
+	}
+	return replaceFile(out+".pdf", path)
+}
+
+// tesseractImageEngine runs tesseract on images directly
+type tesseractImageEngine struct{}
+
+func (tesseractImageEngine) Tools() []string {
+	return []string{"tesseract"}
+}
+
+func (tesseractImageEngine) ImageText(image, language string) (string, error) {
+	output, err := run("tesseract", image, "stdout", "-l", language)
+	return string(output), err
+}
+
+func (tesseractImageEngine) ImageToPdf(image, pdf, language string) error {
+	// tesseract appends the extension itself
+	if _, err := run("tesseract", image, strings.TrimSuffix(pdf, filepath.Ext(pdf)), "-l", language, "pdf"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replaceFile overwrites target with the content of source; they may be on different file systems
+// the content is written next to target first and renamed over it, so target is never left half written
+func replaceFile(source, target string) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", source, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %s", target, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), TEMP_FILE_PREFIX+"replace-*")
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %s", target, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %s", target, err)
+	}
+	return nil
+}
+
+// openers
+
+// commandOpener runs a command with the path as last argument, e.g. xdg-open or a pdf viewer with flags
+type commandOpener struct {
+	command string
+}
+
+func (o commandOpener) Tools() []string {
+	return []string{strings.Fields(o.command)[0]}
+}
+
+func (o commandOpener) Open(path string) error {
+	fields := strings.Fields(o.command)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	// xdg-open hands over and returns, viewers keep running until they are closed
+	if fields[0] == DEFAULT_OPENER {
+		return cmd.Run()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// converters
+
+// img2pdfConverter runs img2pdf, which embeds the image without recompressing it
+type img2pdfConverter struct{}
+
+func (img2pdfConverter) Tools() []string {
+	return []string{"img2pdf"}
+}
+
+func (img2pdfConverter) ToPdf(pdf string, images ...string) error {
+	if _, err := run("img2pdf", append([]string{"-o", pdf}, images...)...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mutoolConverter runs mutool convert
+type mutoolConverter struct{}
+
+func (mutoolConverter) Tools() []string {
+	return []string{"mutool"}
+}
+
+func (mutoolConverter) ToPdf(pdf string, images ...string) error {
+	if _, err := run("mutool", append([]string{"convert", "-o", pdf}, images...)...); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const DEFAULT_OCR_LANGUAGE = "deu"
+
+// Config holds everything which can be set in the config file; all of it is optional
+type Config struct {
+	Retention []RetentionRule `json:"retention,omitempty"`
+	Optimize  OptimizeConfig  `json:"optimize"`
+	// steps every document goes through when it is filed; see Pipeline for the default
+	Pipeline []PipelineStep `json:"pipeline,omitempty"`
+	// shell commands run before and after a document is moved; a failing pre_ingest command keeps the document in inbound
+	PreIngest  string `json:"pre_ingest,omitempty"`
+	PostIngest string `json:"post_ingest,omitempty"`
+	// commit every change ding makes in the documents directory if it is a git repository
+	Git bool `json:"git,omitempty"`
+	// directories whose documents are encrypted when they are filed
+	Encrypt []string `json:"encrypt,omitempty"`
+	// tesseract languages for ocr, several are joined with +, e.g. deu+eng
+	OCRLanguage string `json:"ocr_language,omitempty"`
+	// tools for text extraction, ocr, opening and converting
+	Backends BackendConfig `json:"backends"`
+	// scanner profiles for ding scan
+	Scan ScanConfig `json:"scan"`
+	// upload endpoint of ding serve, which only runs when started explicitly
+	Upload UploadConfig `json:"upload"`
+}
+
+// Conf is the loaded configuration; without config file it is empty
+var Conf Config
+
+// OcrLanguage returns the configured ocr language, german by default
+func (c Config) OcrLanguage() string {
+	if c.OCRLanguage == "" {
+		return DEFAULT_OCR_LANGUAGE
+	}
+	return c.OCRLanguage
+}
+
+// ConfigPath returns where the config file is expected, following the XDG base directory specification
+func ConfigPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, _ := homedir.Dir()
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "ding", "config.json")
+}
+
+// LoadConfig reads the config file at the given path into Conf; a missing file is no error
+func LoadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read config: %s", err)
+	}
+
+	var conf Config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return fmt.Errorf("could not parse config %s: %s", path, err)
+	}
+	if err := conf.Optimize.validate(); err != nil {
+		return fmt.Errorf("invalid optimize settings in %s: %s", path, err)
+	}
+	if err := conf.Backends.validate(); err != nil {
+		return fmt.Errorf("invalid backends in %s: %s", path, err)
+	}
+	if err := conf.Scan.validate(); err != nil {
+		return fmt.Errorf("invalid scan settings in %s: %s", path, err)
+	}
+	if len(conf.Pipeline) > 0 {
+		if err := validatePipeline(conf.Pipeline); err != nil {
+			return fmt.Errorf("invalid pipeline in %s: %s", path, err)
+		}
+	}
+	for _, directory := range conf.Encrypt {
+		if directory == "" || strings.ContainsRune(directory, filepath.Separator) {
+			return fmt.Errorf("invalid encrypted directory %q in %s: only top level directories can be encrypted", directory, path)
+		}
+	}
+	for i, rule := range conf.Retention {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid retention rule %v in %s: %s", i+1, path, err)
+		}
+	}
+
+	Conf = conf
+	Conf.Backends.apply()
+	return nil
+}
@@ -1,12 +1,15 @@
 package core
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,8 +20,16 @@ var (
 	Inbound = "."
 	Dest    = "~/Documents"
 
-	previewCache map[string]string
-	previewsMu   sync.Mutex
+	previewCache map[string]cachedPreview
+	// inbound files whose preview is about to be extracted in the background
+	previewPending map[string]bool
+	previewsMu     sync.Mutex
+	// limits how many previews are extracted in the background at once; ocr of images is expensive
+	previewWorkers = make(chan struct{}, 2)
+
+	pagePreviewCache map[pagePreviewKey]string
+	pageCountCache   map[string]int
+	pagePreviewsMu   sync.Mutex
 
 	directoryFileCache map[string][]fs.DirEntry
 	directoryFilesMu   sync.Mutex
@@ -27,48 +38,212 @@ var (
 )
 
 func init() {
-	previewCache = make(map[string]string)
+	previewCache = make(map[string]cachedPreview)
+	previewPending = make(map[string]bool)
+	pagePreviewCache = make(map[pagePreviewKey]string)
+	pageCountCache = make(map[string]int)
 	directoryFileCache = make(map[string][]fs.DirEntry)
 
 	Dest, _ = homedir.Expand(Dest)
 }
 
 // GetInboundFiles returns a slice of all inbound files
+// files which are still being written by ding are left out
 func GetInboundFiles() ([]fs.DirEntry, error) {
-	inboundFiles, err := os.ReadDir(Inbound)
+	inboundFiles, err := readInbound()
 	if err != nil {
-		return nil, fmt.Errorf("could not read inbound directory: %s", err)
+		return nil, err
 	}
 	WarmInboundFilePreviewCache(inboundFiles)
 	return inboundFiles, nil
 }
 
+func readInbound() ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(Inbound)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inbound directory: %s", err)
+	}
+	inboundFiles := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		if !isPartialFile(e.Name()) && !isTempFile(e.Name()) {
+			inboundFiles = append(inboundFiles, e)
+		}
+	}
+	return inboundFiles, nil
+}
+
+// INBOUND_POLL_INTERVAL is how often the uis look for new inbound files, e.g. uploads or scans
+const INBOUND_POLL_INTERVAL = 2 * time.Second
+
+// InboundFingerprint changes whenever files are added to, changed in or removed from inbound
+// and when the warm-up found out that a file is password protected, so the uis can mark it
+// it is cheap enough to poll, unlike GetInboundFiles which also extracts the previews
+func InboundFingerprint() (string, error) {
+	files, err := readInbound()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%v\x00%v\x00%v\n", f.Name(), info.Size(), info.ModTime().UnixNano(), GetCachedPasswordProtected(f.Name()))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+type cachedPreview struct {
+	modTime time.Time
+	text    string
+}
+
 // WarmInboundFilePreviewCache loads text previews for all inbound files into the cache
+// files whose preview is cached and which did not change since are skipped
 // the first preview will be loaded sync to have it available as soon as the it is displayed by some ui
-// the rest will be loaded async in goroutines
+// the rest will be loaded async, a few at a time
 func WarmInboundFilePreviewCache(files []fs.DirEntry) {
 	for i, f := range files {
+		name := f.Name()
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		previewsMu.Lock()
+		cached, ok := previewCache[name]
+		previewsMu.Unlock()
+		if ok && cached.modTime.Equal(info.ModTime()) {
+			continue
+		}
+
 		if i == 0 {
-			UpdateFilePreviewCache(f.Name())
+			UpdateFilePreviewCache(name)
 			continue
 		}
-		go UpdateFilePreviewCache(f.Name())
+		queuePreviewUpdate(name)
 	}
 }
 
+// queuePreviewUpdate updates the preview of the inbound file in the background once a worker is free
+// files which are queued already are not queued again
+func queuePreviewUpdate(name string) {
+	previewsMu.Lock()
+	if previewPending[name] {
+		previewsMu.Unlock()
+		return
+	}
+	previewPending[name] = true
+	previewsMu.Unlock()
+
+	go func() {
+		previewWorkers <- struct{}{}
+		defer func() { <-previewWorkers }()
+		UpdateFilePreviewCache(name)
+
+		previewsMu.Lock()
+		delete(previewPending, name)
+		previewsMu.Unlock()
+	}()
+}
+
 // UpdateFilePreviewCache upates the text preview for the given file name in the cache
+// cached previews of further pages are dropped, they are extracted again when needed
+// it also finds out whether the file is password protected
 func UpdateFilePreviewCache(filename string) {
+	forgetPagePreviews(filename)
+	IsPasswordProtected(filename)
+
+	// the preview belongs to the file as it was before extracting; if it changes meanwhile, it is extracted again
+	var modTime time.Time
+	if info, err := os.Stat(filepath.Join(Inbound, filename)); err == nil {
+		modTime = info.ModTime()
+	}
+	preview := GetDocPreview(filename)
+
 	previewsMu.Lock()
 	defer previewsMu.Unlock()
-	previewCache[filename] = GetDocPreview(filename)
+	previewCache[filename] = cachedPreview{modTime: modTime, text: preview}
+}
+
+type pagePreviewKey struct {
+	name   string
+	page   int
+	layout bool
+}
+
+// GetDocPagePreview returns the text preview of a single page of the given file
+// the text is extracted on first request and cached afterwards
+func GetDocPagePreview(name string, page int, layout bool) string {
+	key := pagePreviewKey{name: name, page: page, layout: layout}
+
+	pagePreviewsMu.Lock()
+	preview, ok := pagePreviewCache[key]
+	pagePreviewsMu.Unlock()
+	if ok {
+		return preview
+	}
+
+	preview = getDocPagePreview(name, page, layout)
+
+	pagePreviewsMu.Lock()
+	defer pagePreviewsMu.Unlock()
+	pagePreviewCache[key] = preview
+	return preview
+}
+
+// GetCachedDocPagePreview returns the text preview of a single page of the given file from the cache
+func GetCachedDocPagePreview(name string, page int, layout bool) (string, bool) {
+	if page == 1 && !layout {
+		previewsMu.Lock()
+		defer previewsMu.Unlock()
+		preview, ok := previewCache[name]
+		return preview.text, ok
+	}
+
+	pagePreviewsMu.Lock()
+	defer pagePreviewsMu.Unlock()
+	preview, ok := pagePreviewCache[pagePreviewKey{name: name, page: page, layout: layout}]
+	return preview, ok
+}
+
+// GetCachedDocPageCount returns the number of pages of the given file; it is determined once and cached
+func GetCachedDocPageCount(name string) (int, error) {
+	pagePreviewsMu.Lock()
+	count, ok := pageCountCache[name]
+	pagePreviewsMu.Unlock()
+	if ok {
+		return count, nil
+	}
+
+	count, err := GetDocPageCount(name)
+	if err != nil {
+		return 0, err
+	}
+
+	pagePreviewsMu.Lock()
+	defer pagePreviewsMu.Unlock()
+	pageCountCache[name] = count
+	return count, nil
+}
+
+func forgetPagePreviews(name string) {
+	pagePreviewsMu.Lock()
+	defer pagePreviewsMu.Unlock()
+	delete(pageCountCache, name)
+	for key := range pagePreviewCache {
+		if key.name == name {
+			delete(pagePreviewCache, key)
+		}
+	}
 }
 
 // GetCachedDocPreview returns the text preview for the given file name from the cache
 func GetCachedDocPreview(name string) string {
-	if val, ok := previewCache[name]; ok {
-		return val
-	}
-	return ""
+	previewsMu.Lock()
+	defer previewsMu.Unlock()
+	return previewCache[name].text
 }
 
 // GetDirectories returns a slice of the existing directories
@@ -108,6 +283,12 @@ func UpdateDirectoryFilesCache(directoryname string) {
 	defer directoryFilesMu.Unlock()
 	if bfs, _ := GetDirectoryFiles(directoryname); bfs != nil {
 		directoryFileCache[directoryname] = bfs
+
+		names := make([]string, len(bfs))
+		for i, f := range bfs {
+			names[i] = f.Name()
+		}
+		warmSidecarCache(directoryname, names)
 	}
 }
 
@@ -119,16 +300,54 @@ func GetCachedDirectoryFiles(directoryname string) ([]fs.DirEntry, error) {
 	return make([]fs.DirEntry, 0), fmt.Errorf("could not find file list for %s", directoryname)
 }
 
-// GetDirectoryFiles returns a slice of files in the directory
+// GetDirectoryFiles returns a slice of files in the directory; sidecars are left out
 func GetDirectoryFiles(directory string) ([]fs.DirEntry, error) {
-	directoryFiles, err := os.ReadDir(filepath.Join(Dest, directory))
+	entries, err := os.ReadDir(filepath.Join(Dest, directory))
 	if err != nil {
 		return nil, fmt.Errorf("could not read directory directory: %s", err)
 	}
+	directoryFiles := make([]fs.DirEntry, 0, len(entries))
+	for _, f := range entries {
+		if !IsSidecar(f.Name()) {
+			directoryFiles = append(directoryFiles, f)
+		}
+	}
 	sortFilesByModTime(directoryFiles)
 	return directoryFiles, nil
 }
 
+// GetAllDocuments returns the paths relative to the destination of all documents in all directories
+// including sub directories; hidden files and sidecars are left out
+func GetAllDocuments() ([]string, error) {
+	documents := make([]string, 0)
+	err := filepath.WalkDir(Dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != Dest {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || IsSidecar(d.Name()) {
+			return nil
+		}
+		// only documents in directories count; files lying around in the destination itself are not filed
+		rel, err := filepath.Rel(Dest, path)
+		if err != nil || !strings.ContainsRune(rel, filepath.Separator) {
+			return nil
+		}
+		documents = append(documents, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read destination directory: %s", err)
+	}
+	sort.Strings(documents)
+	return documents, nil
+}
+
 // CountDirectory returns the number of files in a directory
 func CountDirectory(directory string) int {
 	directoryFiles, err := os.ReadDir(filepath.Join(Dest, directory))
@@ -138,7 +357,7 @@ func CountDirectory(directory string) int {
 
 	cnt := 0
 	for _, f := range directoryFiles {
-		if f.IsDir() {
+		if f.IsDir() || IsSidecar(f.Name()) {
 			continue
 		}
 
@@ -148,47 +367,72 @@ func CountDirectory(directory string) int {
 	return cnt
 }
 
-// MoveFileToDirectory moves the given file with the given new name to the given directory.
-// This also triggers a cache update for this directory.
-func MoveFileToDirectory(name, newName, directoryName string) (string, error) {
-	// test if both source and destination can be written
-	// destination
-	destTest := filepath.Join(Dest, directoryName, "docin.test")
-	err := ioutil.WriteFile(destTest, []byte("write test"), 0755)
+// fileDocument writes the inbound file of the job into its directory and removes the original
+func fileDocument(job *IngestJob) error {
+	// read inbound file
+	bytesRead, err := ioutil.ReadFile(filepath.Join(Inbound, job.Name))
 	if err != nil {
-		return "", fmt.Errorf("test to write destination failed: %s", err)
+		return fmt.Errorf("could not read original file; did not move it: %s", err)
+	}
+
+	// write file to destination directory
+	// collect metadata before the original is gone
+	sidecar := newSidecar(job.Name, job.hash)
+	sidecar.OriginalName = job.Original
+	sidecar.Tags = job.Tags
+
+	newName := checkFixExtension(job.Name, job.NewName)
+	if WriteMetadataOnIngest {
+		bytesRead = withPdfMetadata(bytesRead, newName, job.Directory, job.Tags, metadataDate(sidecar, job.Name))
 	}
-	os.Remove(destTest)
-	// inbound
-	sourceTest := filepath.Join(Inbound, "docin.test")
-	err = ioutil.WriteFile(sourceTest, []byte("write test"), 0755)
+	bytesRead, err = encryptDocument(job.Directory, bytesRead)
 	if err != nil {
-		return "", fmt.Errorf("test to write inbound failed: %s", err)
+		return fmt.Errorf("could not encrypt file; did not move it: %s", err)
 	}
-	os.Remove(sourceTest)
-
-	// read inbound file
-	bytesRead, err := ioutil.ReadFile(filepath.Join(Inbound, name))
+	target := filepath.Join(Dest, job.Directory, newName)
+	err = ioutil.WriteFile(target, bytesRead, 0755)
 	if err != nil {
-		return "", fmt.Errorf("could not read original file; did not move it: %s", err)
+		return fmt.Errorf("could not write file into directory; did not move it: %s", err)
 	}
 
-	// write file to destination directory
-	newName = checkFixExtension(name, newName)
-	err = ioutil.WriteFile(filepath.Join(Dest, directoryName, newName), bytesRead, 0755)
+	// remove original
+	err = os.Remove(filepath.Join(Inbound, job.Name))
 	if err != nil {
-		return "", fmt.Errorf("could not write file into directory; did not move it: %s", err)
+		return fmt.Errorf("could not remove original, please do manually!: %s", err)
+	}
+	job.NewName = newName
+	job.Target = filepath.Join(job.Directory, newName)
+
+	// the document is filed, a missing sidecar or attribute is no reason to fail
+	WriteSidecar(job.Target, sidecar)
+	// extended attributes can not be encrypted; tags of encrypted documents are only in their sidecar
+	if len(job.Tags) > 0 && !IsEncryptedDirectory(job.Directory) {
+		setXattrTags(target, job.Tags)
 	}
+	tagMu.Lock()
+	tagCache[job.Target] = job.Tags
+	tagMu.Unlock()
 
-	// remove original
-	err = os.Remove(filepath.Join(Inbound, name))
+	addToHashIndex(target, fmt.Sprintf("%x", sha256.Sum256(bytesRead)), job.hash)
+	go UpdateDirectoryFilesCache(job.Directory)
+
+	job.commitErr = commitDocuments("add "+job.Target, job.Target)
+
+	return nil
+}
+
+// DeleteInboundFile removes the given file from the inbound directory
+func DeleteInboundFile(name string) error {
+	err := os.Remove(filepath.Join(Inbound, name))
 	if err != nil {
-		return "", fmt.Errorf("could not remove original, please do manually!: %s", err)
+		return fmt.Errorf("could not delete inbound file: %s", err)
 	}
 
-	go UpdateDirectoryFilesCache(directoryName)
+	previewsMu.Lock()
+	delete(previewCache, name)
+	previewsMu.Unlock()
 
-	return newName, nil
+	return nil
 }
 
 // GetTimestampFilePrefix returns as timestamp prefix
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingExtractor counts extractions and how many of them run at once
+type countingExtractor struct {
+	mu      sync.Mutex
+	calls   int
+	running int
+	max     int
+}
+
+func (e *countingExtractor) PageText(path string, page int, layout bool) (string, error) {
+	e.mu.Lock()
+	e.calls++
+	e.running++
+	if e.running > e.max {
+		e.max = e.running
+	}
+	e.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+	return "text of " + filepath.Base(path), nil
+}
+
+func (e *countingExtractor) PageCount(path string) (int, error) {
+	return 1, nil
+}
+
+func (e *countingExtractor) counts() (int, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls, e.max
+}
+
+func TestWarmInboundFilePreviewCacheSkipsCurrentPreviews(t *testing.T) {
+	setupIngest(t)
+	extractor := &countingExtractor{}
+	Extractor = extractor
+
+	writeInboundFile(t, "a.pdf", []byte("%PDF-1.4"))
+	files, err := readInbound()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WarmInboundFilePreviewCache(files)
+	WarmInboundFilePreviewCache(files)
+	if calls, _ := extractor.counts(); calls != 1 {
+		t.Errorf("expected the unchanged file to be extracted once, got %v extractions", calls)
+	}
+	if preview, _ := GetCachedDocPagePreview("a.pdf", 1, false); preview != "text of a.pdf" {
+		t.Errorf("unexpected preview %q", preview)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(Inbound, "a.pdf"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = readInbound()
+	WarmInboundFilePreviewCache(files)
+	if calls, _ := extractor.counts(); calls != 2 {
+		t.Errorf("expected the changed file to be extracted again, got %v extractions", calls)
+	}
+}
+
+func TestWarmInboundFilePreviewCacheLimitsWorkers(t *testing.T) {
+	setupIngest(t)
+	extractor := &countingExtractor{}
+	Extractor = extractor
+
+	count := 10
+	for i := 0; i < count; i++ {
+		writeInboundFile(t, fmt.Sprintf("%02d.pdf", i), []byte("%PDF-1.4"))
+	}
+	files, err := readInbound()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WarmInboundFilePreviewCache(files)
+	// warming again while the previews are extracted must not queue them twice
+	WarmInboundFilePreviewCache(files)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		calls, _ := extractor.counts()
+		if calls >= count {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %v of %v previews extracted", calls, count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// give extractions which were queued twice a chance to show up
+	time.Sleep(50 * time.Millisecond)
+
+	calls, max := extractor.counts()
+	if calls != count {
+		t.Errorf("expected %v extractions, got %v", count, calls)
+	}
+	// the first preview is extracted right away, the others by the workers
+	if max > cap(previewWorkers) {
+		t.Errorf("expected at most %v extractions at once, got %v", cap(previewWorkers), max)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	numericDateMatch = regexp.MustCompile(`\b(\d{1,2})\.\s?(\d{1,2})\.\s?(\d{4}|\d{2})\b`)
+	isoDateMatch     = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	writtenDateMatch = regexp.MustCompile(`(?i)\b(\d{1,2})\.?\s+([a-zä]{3,9})\.?\s+(\d{4})\b`)
+
+	MONTH_NAMES = map[string]time.Month{
+		"jan": time.January, "januar": time.January, "january": time.January,
+		"feb": time.February, "februar": time.February, "february": time.February,
+		"mär": time.March, "märz": time.March, "maerz": time.March, "mar": time.March, "march": time.March,
+		"apr": time.April, "april": time.April,
+		"mai": time.May, "may": time.May,
+		"jun": time.June, "juni": time.June, "june": time.June,
+		"jul": time.July, "juli": time.July, "july": time.July,
+		"aug": time.August, "august": time.August,
+		"sep": time.September, "sept": time.September, "september": time.September,
+		"okt": time.October, "oktober": time.October, "oct": time.October, "october": time.October,
+		"nov": time.November, "november": time.November,
+		"dez": time.December, "dezember": time.December, "dec": time.December, "december": time.December,
+	}
+)
+
+// foundDate is a date found in a text together with its byte offset
+type foundDate struct {
+	date time.Time
+	pos  int
+}
+
+// findDates returns all plausible dates in the text in the order they appear
+// understood are 18.10.2026, 18.10.26, 2026-10-18 and 18. Oktober 2026 / 18 October 2026
+func findDates(text string) []foundDate {
+	dates := make([]foundDate, 0)
+	add := func(pos int, year, month, day int) {
+		if year < 100 {
+			year += 2000
+		}
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return
+		}
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+		// reject overflows like 31.02. and dates far away
+		if date.Day() != day || year < 1950 || year > time.Now().Year()+10 {
+			return
+		}
+		dates = append(dates, foundDate{date: date, pos: pos})
+	}
+
+	for _, m := range numericDateMatch.FindAllStringSubmatchIndex(text, -1) {
+		day, _ := strconv.Atoi(text[m[2]:m[3]])
+		month, _ := strconv.Atoi(text[m[4]:m[5]])
+		year, _ := strconv.Atoi(text[m[6]:m[7]])
+		add(m[0], year, month, day)
+	}
+	for _, m := range isoDateMatch.FindAllStringSubmatchIndex(text, -1) {
+		year, _ := strconv.Atoi(text[m[2]:m[3]])
+		month, _ := strconv.Atoi(text[m[4]:m[5]])
+		day, _ := strconv.Atoi(text[m[6]:m[7]])
+		add(m[0], year, month, day)
+	}
+	for _, m := range writtenDateMatch.FindAllStringSubmatchIndex(text, -1) {
+		month, ok := MONTH_NAMES[strings.ToLower(text[m[4]:m[5]])]
+		if !ok {
+			continue
+		}
+		day, _ := strconv.Atoi(text[m[2]:m[3]])
+		year, _ := strconv.Atoi(text[m[6]:m[7]])
+		add(m[0], year, int(month), day)
+	}
+
+	sort.SliceStable(dates, func(i, j int) bool { return dates[i].pos < dates[j].pos })
+	return dates
+}
+
+// DetectDocumentDate returns the first date which is found in the text and not in the future
+// letters usually carry their date near the top, so the first one is most likely the document date
+func DetectDocumentDate(text string) (time.Time, bool) {
+	now := time.Now()
+	for _, d := range findDates(text) {
+		if !d.date.After(now) {
+			return d.date, true
+		}
+	}
+	return time.Time{}, false
+}
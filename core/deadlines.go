@@ -0,0 +1,167 @@
+package core
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// phrases which introduce a due date in german and english letters; only whole words count,
+	// so "residue" or "befristet" do not, and generic ones like "bis zum" need a verb of paying before them
+	dueDateKeywordMatch = regexp.MustCompile(`(?i)\b(zahlbar\s+(bis|spätestens|spaetestens)|f(ä|ae)llig(keit)?|zahlungsziel|(zahlungs|einreichungs|antwort)?frist|einzureichen\s+bis|zu\s+(zahlen|überweisen|ueberweisen|begleichen)\s+bis|(spätestens|spaetestens)\s+(bis|zum|am)|due\s+(date|by|on)|(payment|amount|balance)\s+due|payable\s+(by|until)|deadline|pay\s+by)\b`)
+)
+
+// how far behind a keyword the date may be, e.g. "Zahlbar bis spätestens zum 30.10.2026"
+const dueDateDistance = 60
+
+// Deadline is a due date of a filed document
+type Deadline struct {
+	Path string    `json:"path"`
+	Due  time.Time `json:"due"`
+}
+
+// DetectDueDate returns the first date in the text which is introduced by a phrase like "zahlbar bis"
+// or "Frist"; dates without such a phrase are not taken as due dates
+func DetectDueDate(text string) (time.Time, bool) {
+	dates := findDates(text)
+	for _, m := range dueDateKeywordMatch.FindAllStringIndex(text, -1) {
+		for _, d := range dates {
+			if d.pos >= m[1] && d.pos-m[1] <= dueDateDistance {
+				return d.date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// GetCachedDueDate returns the due date found in the cached preview of the given inbound file
+func GetCachedDueDate(name string) (time.Time, bool) {
+	return DetectDueDate(GetCachedDocPreview(name))
+}
+
+// FindDeadlines returns the due dates of all filed documents from their sidecars, soonest first
+// only deadlines at or after from are returned; a zero from returns all of them
+func FindDeadlines(from time.Time) ([]Deadline, error) {
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	deadlines := make([]Deadline, 0)
+	for _, rel := range documents {
+		sidecar, err := ReadSidecar(rel)
+		if err != nil || sidecar.DueDate == "" {
+			continue
+		}
+		due, err := time.ParseInLocation(SIDECAR_DATE_FORMAT, sidecar.DueDate, time.Local)
+		if err != nil || due.Before(from) {
+			continue
+		}
+		deadlines = append(deadlines, Deadline{Path: rel, Due: due})
+	}
+
+	sort.SliceStable(deadlines, func(i, j int) bool { return deadlines[i].Due.Before(deadlines[j].Due) })
+	return deadlines, nil
+}
+
+// DetectMissingDueDates looks for due dates in the text of filed documents which have none in their sidecar yet
+// e.g. for documents which were filed before ding knew about due dates; returns the number of new deadlines
+func DetectMissingDueDates() (int, error) {
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return 0, err
+	}
+
+	found := 0
+	for _, rel := range documents {
+		sidecar, err := ReadSidecar(rel)
+		if err == nil && sidecar.DueDate != "" {
+			continue
+		}
+		text, err := GetDocText(filepath.Join(Dest, rel))
+		if err != nil {
+			continue
+		}
+		due, ok := DetectDueDate(text)
+		if !ok {
+			continue
+		}
+		err = UpdateSidecar(rel, func(s *Sidecar) {
+			s.DueDate = due.Format(SIDECAR_DATE_FORMAT)
+		})
+		if err != nil {
+			return found, err
+		}
+		found++
+	}
+	return found, nil
+}
+
+// WriteICS writes the deadlines as iCalendar with one all day event per deadline
+// every event links to the document in the destination
+func WriteICS(w io.Writer, deadlines []Deadline) error {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ding//deadlines//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, d := range deadlines {
+		path := filepath.Join(Dest, d.Path)
+		link := (&url.URL{Scheme: "file", Path: path}).String()
+		title := RemoveTimeStampFilePrefix(filepath.Base(d.Path))
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:%x@ding", sha1.Sum([]byte(d.Path+d.Due.Format(SIDECAR_DATE_FORMAT)))))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + d.Due.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + d.Due.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText("Due: "+title))
+		line("DESCRIPTION:" + escapeICSText(path))
+		line("URL:" + link)
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		return fmt.Errorf("could not write calendar: %s", err)
+	}
+	return nil
+}
+
+// escapeICSText escapes a value of a text property as required by RFC 5545
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// foldICSLine breaks lines longer than 75 octets; continuation lines start with a space
+// lines are only broken between utf-8 sequences
+func foldICSLine(s string) string {
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xc0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	return b.String()
+}
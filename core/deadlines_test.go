@@ -0,0 +1,130 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDetectDueDate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "zahlbar bis", text: "Rechnung vom 01.10.2026\nZahlbar bis spätestens zum 30.10.2026", want: "2026-10-30"},
+		{name: "fällig am", text: "Der Betrag ist fällig am 15. November 2026.", want: "2026-11-15"},
+		{name: "fälligkeit", text: "Fälligkeit: 2026-11-01", want: "2026-11-01"},
+		{name: "frist", text: "Bitte beachten Sie die Frist: 01.12.26", want: "2026-12-01"},
+		{name: "zahlungsfrist", text: "Zahlungsfrist 14.11.2026", want: "2026-11-14"},
+		{name: "zu zahlen bis zum", text: "Der Beitrag ist zu zahlen bis zum 20.10.2026", want: "2026-10-20"},
+		{name: "due by", text: "Invoice date 01.10.2026, payment due 31 October 2026", want: "2026-10-31"},
+		{name: "amount due", text: "Amount due: 2026-10-31", want: "2026-10-31"},
+		{name: "no keyword", text: "Rechnung vom 01.10.2026", want: ""},
+		{name: "date too far away", text: "Zahlbar bis" + strings.Repeat(" ", dueDateDistance+1) + "30.10.2026", want: ""},
+		{name: "residue", text: "Residue 30.10.2026", want: ""},
+		{name: "due to", text: "Due to maintenance on 30.10.2026 the office is closed", want: ""},
+		{name: "befristet", text: "Der Vertrag ist befristet bis 30.10.2026", want: ""},
+		{name: "bis zum alone", text: "Leistungszeitraum vom 01.10.2026 bis zum 31.10.2026", want: ""},
+		{name: "überfällig", text: "Überfälliger Betrag aus 09.2026", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, ok := DetectDueDate(tt.text)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("expected no due date, got %s", due.Format(SIDECAR_DATE_FORMAT))
+				}
+				return
+			}
+			if !ok || due.Format("2006-01-02") != tt.want {
+				t.Errorf("expected due date %s, got %s (%v)", tt.want, due.Format("2006-01-02"), ok)
+			}
+		})
+	}
+}
+
+func TestFindDates(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "numeric", text: "am 18.10.2026 und 1. 2. 26", want: []string{"2026-10-18", "2026-02-01"}},
+		{name: "iso", text: "2026-10-18", want: []string{"2026-10-18"}},
+		{name: "written", text: "18. Oktober 2026, 3 March 2026 and 7. Mär 2026", want: []string{"2026-10-18", "2026-03-03", "2026-03-07"}},
+		{name: "in order of appearance", text: "2026-12-01 before 30.11.2026", want: []string{"2026-12-01", "2026-11-30"}},
+		{name: "overflow", text: "31.02.2026", want: []string{}},
+		{name: "invalid month", text: "12.13.2026 and 2026-13-01", want: []string{}},
+		{name: "unknown month name", text: "18 Foo 2026", want: []string{}},
+		{name: "too old", text: "01.01.1900", want: []string{}},
+		{name: "part of a number", text: "IBAN 12.10.20261", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates := findDates(tt.text)
+			got := make([]string, 0, len(dates))
+			for _, d := range dates {
+				got = append(got, d.date.Format("2006-01-02"))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "short", in: "SUMMARY:Due: Rechnung"},
+		{name: "exactly 75", in: strings.Repeat("a", 75)},
+		{name: "long", in: "DESCRIPTION:" + strings.Repeat("x", 200)},
+		{name: "utf-8 at the border", in: "SUMMARY:" + strings.Repeat("ä", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldICSLine(tt.in)
+			lines := strings.Split(folded, "\r\n")
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %v is longer than 75 octets: %v", i, len(line))
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %v does not start with a space: %q", i, line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %v breaks a utf-8 sequence: %q", i, line)
+				}
+			}
+			if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.in {
+				t.Errorf("unfolding does not give the line back: %q", unfolded)
+			}
+			if len(tt.in) <= 75 && folded != tt.in {
+				t.Errorf("short lines must not be folded: %q", folded)
+			}
+		})
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	setupIngest(t)
+	due := time.Date(2026, 10, 30, 0, 0, 0, 0, time.Local)
+
+	var b strings.Builder
+	if err := WriteICS(&b, []Deadline{{Path: "rechnungen/20261001-120000.000_strom, gas; wasser.pdf", Due: due}}); err != nil {
+		t.Fatal(err)
+	}
+	ics := b.String()
+	for _, want := range []string{"DTSTART;VALUE=DATE:20261030\r\n", "DTEND;VALUE=DATE:20261031\r\n", `SUMMARY:Due: strom\, gas\; wasser.pdf`} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("expected %q in calendar:\n%s", want, ics)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DUPLICATE_IDENTICAL = "identical"
+	DUPLICATE_SIMILAR   = "similar"
+
+	// minhash parameters; bands * rows must be the signature length
+	minHashLength = 128
+	minHashBands  = 32
+	minHashRows   = 4
+
+	// words per shingle
+	shingleSize = 2
+	// documents with less words are not compared by text; they are mostly scans without OCR
+	minShingleWords = 20
+)
+
+// DuplicateGroup is a set of documents in the destination which are identical or have almost the same text
+type DuplicateGroup struct {
+	Kind       string   `json:"kind"`
+	Similarity float64  `json:"similarity"`
+	Files      []string `json:"files"`
+}
+
+// FindDuplicateGroups returns groups of byte identical documents and groups of documents whose texts have an
+// estimated similarity of at least the given threshold (0..1); file paths are relative to the destination
+func FindDuplicateGroups(threshold float64) ([]DuplicateGroup, error) {
+	if err := WarmHashIndex(); err != nil {
+		return nil, err
+	}
+
+	groups := identicalGroups()
+
+	if err := WarmSearchIndex(); err != nil {
+		return nil, err
+	}
+	groups = append(groups, similarGroups(threshold)...)
+
+	return groups, nil
+}
+
+func identicalGroups() []DuplicateGroup {
+	byHash := make(map[string][]string)
+	hashIndexMu.Lock()
+	for rel, f := range hashIndex {
+		byHash[f.contentHash()] = append(byHash[f.contentHash()], rel)
+	}
+	hashIndexMu.Unlock()
+
+	groups := make([]DuplicateGroup, 0)
+	for _, files := range byHash {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Strings(files)
+		groups = append(groups, DuplicateGroup{Kind: DUPLICATE_IDENTICAL, Similarity: 1, Files: files})
+	}
+	sortDuplicateGroups(groups)
+
+	return groups
+}
+
+func similarGroups(threshold float64) []DuplicateGroup {
+	type candidate struct {
+		rel       string
+		hash      string
+		signature []uint64
+	}
+
+	// compute signatures for all documents with enough text
+	candidates := make([]candidate, 0)
+	searchIndexMu.Lock()
+	for path, doc := range searchIndex {
+		words := textWords(doc.text)
+		if len(words) < minShingleWords {
+			continue
+		}
+		rel, err := filepath.Rel(Dest, path)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{rel: rel, signature: minHashSignature(words)})
+	}
+	searchIndexMu.Unlock()
+
+	hashIndexMu.Lock()
+	for i := range candidates {
+		candidates[i].hash = hashIndex[candidates[i].rel].contentHash()
+	}
+	hashIndexMu.Unlock()
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].rel < candidates[j].rel })
+
+	// byte identical documents are reported separately; only the first of them takes part here,
+	// so no document shows up in an identical and a similar group
+	seen := make(map[string]bool)
+	unique := candidates[:0]
+	for _, c := range candidates {
+		if c.hash != "" && seen[c.hash] {
+			continue
+		}
+		seen[c.hash] = true
+		unique = append(unique, c)
+	}
+	candidates = unique
+
+	// locality sensitive hashing; documents which share a band become candidate pairs
+	buckets := make(map[uint64][]int)
+	for i, c := range candidates {
+		for b := 0; b < minHashBands; b++ {
+			h := fnv.New64a()
+			buf := make([]byte, 8)
+			binary.LittleEndian.PutUint64(buf, uint64(b))
+			h.Write(buf)
+			for _, v := range c.signature[b*minHashRows : (b+1)*minHashRows] {
+				binary.LittleEndian.PutUint64(buf, v)
+				h.Write(buf)
+			}
+			key := h.Sum64()
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	// union find over all pairs which are similar enough
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	lowest := make(map[int]float64)
+	checked := make(map[[2]int]bool)
+	for _, members := range buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				i, j := members[x], members[y]
+				if checked[[2]int{i, j}] {
+					continue
+				}
+				checked[[2]int{i, j}] = true
+
+				similarity := signatureSimilarity(candidates[i].signature, candidates[j].signature)
+				if similarity < threshold {
+					continue
+				}
+
+				ri, rj := find(i), find(j)
+				low := similarity
+				for _, r := range []int{ri, rj} {
+					if s, ok := lowest[r]; ok && s < low {
+						low = s
+					}
+				}
+				parent[rj] = ri
+				delete(lowest, rj)
+				lowest[ri] = low
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+	for i, c := range candidates {
+		r := find(i)
+		members[r] = append(members[r], c.rel)
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for r, files := range members {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Strings(files)
+		groups = append(groups, DuplicateGroup{Kind: DUPLICATE_SIMILAR, Similarity: lowest[r], Files: files})
+	}
+	sortDuplicateGroups(groups)
+
+	return groups
+}
+
+func sortDuplicateGroups(groups []DuplicateGroup) {
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0] < groups[j].Files[0] })
+}
+
+// textWords splits a text into lower case words; punctuation, which ocr tends to get wrong, is dropped
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// minHashSignature computes the minhash signature of the word shingles of a text
+func minHashSignature(words []string) []uint64 {
+	signature := make([]uint64, minHashLength)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		shingle := h.Sum64()
+
+		for k := range signature {
+			if v := mix64(shingle ^ uint64(k)*0x9e3779b97f4a7c15); v < signature[k] {
+				signature[k] = v
+			}
+		}
+	}
+
+	return signature
+}
+
+// mix64 is the splitmix64 finalizer; used to derive independent hash functions from one shingle hash
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// signatureSimilarity estimates the jaccard similarity of two texts by their minhash signatures
+func signatureSimilarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSimilarGroupsCollapsesIdenticalCopies(t *testing.T) {
+	setupIngest(t)
+	hashes, docs := hashIndex, searchIndex
+	t.Cleanup(func() { hashIndex, searchIndex = hashes, docs })
+
+	text := strings.Repeat("sehr geehrte damen und herren anbei die rechnung fuer den monat januar ", 3)
+	files := map[string]struct{ hash, text string }{
+		"rechnungen/a.pdf":      {"1", text},
+		"rechnungen/a_copy.pdf": {"1", text},
+		"rechnungen/b.pdf":      {"2", text + "mit freundlichen gruessen"},
+	}
+	hashIndex = make(map[string]hashedFile)
+	searchIndex = make(map[string]indexedDoc)
+	for rel, f := range files {
+		hashIndex[rel] = hashedFile{hash: f.hash}
+		searchIndex[filepath.Join(Dest, rel)] = indexedDoc{text: f.text}
+	}
+
+	groups := similarGroups(0.5)
+	if len(groups) != 1 {
+		t.Fatalf("expected one similar group, got %+v", groups)
+	}
+	if got := strings.Join(groups[0].Files, ","); got != "rechnungen/a.pdf,rechnungen/b.pdf" {
+		t.Errorf("identical copies must be collapsed to one document, got %s", got)
+	}
+}
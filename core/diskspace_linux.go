@@ -0,0 +1,15 @@
+//go:build linux
+// +build linux
+
+package core
+
+import "syscall"
+
+// freeDiskSpace returns the bytes available to the user on the filesystem of path
+func freeDiskSpace(path string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package core
+
+// freeDiskSpace is not implemented on this platform
+func freeDiskSpace(path string) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const (
+	CHECK_OK      = "ok"
+	CHECK_WARNING = "warning"
+	CHECK_FAILED  = "failed"
+
+	CHECK_TOOL      = "tool"
+	CHECK_OCR       = "ocr"
+	CHECK_DIRECTORY = "directory"
+	CHECK_CONFIG    = "config"
+
+	// less free space than this is worth a warning
+	LOW_DISK_SPACE = 500 * 1048576
+)
+
+var (
+	// arguments which make a tool print its version; --version if not listed
+	VERSION_ARGS = map[string][]string{
+		"pdftotext": {"-v"},
+		"pdfinfo":   {"-v"},
+		"pdftoppm":  {"-v"},
+		"gs":        {"--version"},
+		"mutool":    {"-v"},
+	}
+)
+
+// Check is the outcome of a single check of the doctor
+type Check struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail"`
+	// ding does not work as configured if a required check fails
+	Required bool `json:"required"`
+}
+
+// DoctorReport lists everything the doctor checked, in a stable order
+type DoctorReport struct {
+	Checks []Check `json:"checks"`
+}
+
+// Healthy reports whether all required checks passed
+func (r DoctorReport) Healthy() bool {
+	for _, c := range r.Checks {
+		if c.Required && c.Status == CHECK_FAILED {
+			return false
+		}
+	}
+	return true
+}
+
+// RunDoctor checks the external tools, the ocr languages and the directories
+func RunDoctor() DoctorReport {
+	report := DoctorReport{Checks: make([]Check, 0)}
+	required := requiredTools()
+
+	for _, dep := range DependencyNames() {
+		check := Check{Category: CHECK_TOOL, Name: dep, Status: CHECK_OK}
+		reason, isRequired := required[dep]
+		check.Required = isRequired
+		if !checkDep(dep) {
+			check.Status = CHECK_WARNING
+			check.Detail = "missing, you won't be able to " + DEPENDENCIES[dep]
+			if isRequired {
+				check.Status = CHECK_FAILED
+				check.Detail = "missing, but needed by " + reason
+			}
+		} else {
+			check.Detail = toolVersion(dep)
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	if Conf.Optimize.OnIngest || pipelineHas(STEP_OPTIMIZE) {
+		check := Check{Category: CHECK_CONFIG, Name: "optimize", Status: CHECK_OK, Required: true}
+		if tool, err := Conf.Optimize.optimizeTool(); err != nil {
+			check.Status = CHECK_FAILED
+			check.Detail = err.Error()
+		} else {
+			check.Detail = "uses " + tool
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	report.Checks = append(report.Checks, ocrLanguageChecks(required)...)
+	report.Checks = append(report.Checks, directoryCheck("inbound", Inbound), directoryCheck("documents", Dest))
+
+	return report
+}
+
+// requiredTools returns the tools the configuration can not do without and what needs them
+func requiredTools() map[string]string {
+	required := make(map[string]string)
+	if pipelineHas(STEP_OCR) {
+		for _, tool := range backendTools(OCR) {
+			required[tool] = "the ocr step of the pipeline"
+		}
+	}
+	if pipelineHas(STEP_CONVERT) {
+		for _, tool := range backendTools(ImageConv) {
+			required[tool] = "the convert step of the pipeline"
+		}
+	}
+	if Conf.Backends.Text != "" && Conf.Backends.Text != TEXT_AUTO {
+		for _, tool := range backendTools(Extractor) {
+			required[tool] = "the text backend"
+		}
+	}
+	if (Conf.Optimize.OnIngest || pipelineHas(STEP_OPTIMIZE)) && Conf.Optimize.Tool != "" && Conf.Optimize.Tool != OPTIMIZE_AUTO {
+		required[Conf.Optimize.Tool] = "the optimize settings"
+	}
+	if Conf.Git {
+		required["git"] = "the git setting"
+	}
+	return required
+}
+
+// pipelineHas reports whether the configured pipeline contains the step
+func pipelineHas(step string) bool {
+	for _, s := range Pipeline() {
+		if s.Step == step {
+			return true
+		}
+	}
+	return false
+}
+
+// toolVersion returns the first line a tool prints about its version
+func toolVersion(tool string) string {
+	args, ok := VERSION_ARGS[tool]
+	if !ok {
+		args = []string{"--version"}
+	}
+	output, _ := exec.Command(tool, args...).CombinedOutput()
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return "installed, version unknown"
+}
+
+// TesseractLanguages returns the languages tesseract can recognise
+func TesseractLanguages() ([]string, error) {
+	output, err := exec.Command("tesseract", "--list-langs").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("could not list tesseract languages: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	languages := make([]string, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		// the first line tells where the languages are installed
+		if line == "" || strings.HasPrefix(line, "List of") {
+			continue
+		}
+		languages = append(languages, line)
+	}
+	sort.Strings(languages)
+	return languages, nil
+}
+
+// ocrLanguageChecks checks that every configured ocr language is installed
+func ocrLanguageChecks(required map[string]string) []Check {
+	_, isRequired := required["tesseract"]
+	status := CHECK_WARNING
+	if isRequired {
+		status = CHECK_FAILED
+	}
+
+	checks := make([]Check, 0)
+	installed, err := TesseractLanguages()
+	if err != nil {
+		return append(checks, Check{Category: CHECK_OCR, Name: Conf.OcrLanguage(), Status: status, Detail: err.Error(), Required: isRequired})
+	}
+
+	known := make(map[string]bool)
+	for _, language := range installed {
+		known[language] = true
+	}
+	for _, language := range strings.Split(Conf.OcrLanguage(), "+") {
+		check := Check{Category: CHECK_OCR, Name: language, Status: CHECK_OK, Detail: "installed", Required: isRequired}
+		if !known[language] {
+			check.Status = status
+			check.Detail = "not installed; installed are " + strings.Join(installed, ", ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// directoryCheck checks that the directory exists, can be written and has space left
+func directoryCheck(name, path string) Check {
+	check := Check{Category: CHECK_DIRECTORY, Name: name, Status: CHECK_FAILED, Required: true}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s does not exist", path)
+		return check
+	}
+	if !info.IsDir() {
+		check.Detail = fmt.Sprintf("%s is no directory", path)
+		return check
+	}
+	f, err := ioutil.TempFile(path, TEMP_FILE_PREFIX+"doctor-")
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s can not be written", path)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	check.Status = CHECK_OK
+	check.Detail = path
+	if free, ok := freeDiskSpace(path); ok {
+		check.Detail = fmt.Sprintf("%s, %s free", path, FormatSize(free))
+		if free < LOW_DISK_SPACE {
+			check.Status = CHECK_WARNING
+		}
+	}
+	return check
+}
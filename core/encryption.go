@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// encrypted documents start with the magic, followed by the nonce and the sealed content
+	ENCRYPTION_MAGIC = "DINGENC1"
+	// the key file holds the salt and a sealed check value, so a wrong passphrase is noticed on unlock
+	KEY_FILE        = ".ding-key.json"
+	KEY_CHECK_VALUE = "ding"
+
+	DEFAULT_UNLOCK_DURATION = 15 * time.Minute
+	// how long a decrypted copy opened in an external application is kept
+	DECRYPTED_OPEN_LIFETIME = 5 * time.Minute
+)
+
+// ErrLocked is returned when an encrypted document is needed but no key is unlocked
+var ErrLocked = errors.New("encrypted documents are locked, run ding unlock")
+
+type keyFile struct {
+	Salt  []byte `json:"salt"`
+	Check []byte `json:"check"`
+}
+
+type cachedKey struct {
+	Key     []byte    `json:"key"`
+	Expires time.Time `json:"expires"`
+}
+
+// IsEncryptedDirectory reports whether documents filed into the directory get encrypted
+func IsEncryptedDirectory(directory string) bool {
+	for _, d := range Conf.Encrypt {
+		if d == topDirectory(directory) {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeDirectory returns a directory only the user can read which does not survive a reboot if possible
+func runtimeDirectory() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("ding-%v", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "ding")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create %s: %s", dir, err)
+	}
+	// someone else may have created it in the shared temp directory
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		return "", fmt.Errorf("%s is not private", dir)
+	}
+	return dir, nil
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not derive key: %s", err)
+	}
+	return key, nil
+}
+
+// HasEncryptionKey reports whether a passphrase has been set for the destination
+func HasEncryptionKey() bool {
+	_, err := os.Stat(filepath.Join(Dest, KEY_FILE))
+	return err == nil
+}
+
+// Unlock derives the key from the passphrase and caches it for the given duration
+// the first unlock sets the passphrase for the destination
+func Unlock(passphrase string, duration time.Duration) error {
+	if passphrase == "" {
+		return fmt.Errorf("the passphrase must not be empty")
+	}
+
+	path := filepath.Join(Dest, KEY_FILE)
+	var kf keyFile
+	var key []byte
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		kf.Salt = make([]byte, 16)
+		if _, err := rand.Read(kf.Salt); err != nil {
+			return fmt.Errorf("could not create salt: %s", err)
+		}
+		if key, err = deriveKey(passphrase, kf.Salt); err != nil {
+			return err
+		}
+		if kf.Check, err = encrypt(key, []byte(KEY_CHECK_VALUE)); err != nil {
+			return err
+		}
+		data, _ := json.Marshal(kf)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("could not write key file: %s", err)
+		}
+	case err != nil:
+		return fmt.Errorf("could not read key file: %s", err)
+	default:
+		if err := json.Unmarshal(data, &kf); err != nil {
+			return fmt.Errorf("could not parse key file %s: %s", path, err)
+		}
+		if key, err = deriveKey(passphrase, kf.Salt); err != nil {
+			return err
+		}
+		if check, err := decrypt(key, kf.Check); err != nil || string(check) != KEY_CHECK_VALUE {
+			return fmt.Errorf("wrong passphrase")
+		}
+	}
+
+	if err := storeCachedKey(cachedKey{Key: key, Expires: time.Now().Add(duration)}); err != nil {
+		return fmt.Errorf("could not cache key: %s", err)
+	}
+	return nil
+}
+
+// Lock forgets the cached key
+func Lock() error {
+	if err := forgetCachedKey(); err != nil {
+		return fmt.Errorf("could not remove cached key: %s", err)
+	}
+	return nil
+}
+
+// UnlockedUntil returns when the cached key expires; false if there is none
+func UnlockedUntil() (time.Time, bool) {
+	key, err := readCachedKey()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return key.Expires, true
+}
+
+func readCachedKey() (cachedKey, error) {
+	key, err := loadCachedKey()
+	if err != nil {
+		return key, ErrLocked
+	}
+	if time.Now().After(key.Expires) {
+		forgetCachedKey()
+		return key, ErrLocked
+	}
+	return key, nil
+}
+
+// encryptionKey returns the unlocked key or ErrLocked
+func encryptionKey() ([]byte, error) {
+	key, err := readCachedKey()
+	if err != nil {
+		return nil, err
+	}
+	return key.Key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %s", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %s", err)
+	}
+	return gcm, nil
+}
+
+// encrypt seals the data with AES-GCM under a random nonce
+func encrypt(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not create nonce: %s", err)
+	}
+	sealed := append([]byte(ENCRYPTION_MAGIC), nonce...)
+	return gcm.Seal(sealed, nonce, plain, []byte(ENCRYPTION_MAGIC)), nil
+}
+
+// decrypt opens data which has been sealed by encrypt
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(ENCRYPTION_MAGIC)) || len(data) < len(ENCRYPTION_MAGIC)+gcm.NonceSize() {
+		return nil, fmt.Errorf("not encrypted by ding")
+	}
+	data = data[len(ENCRYPTION_MAGIC):]
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(ENCRYPTION_MAGIC))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt: %s", err)
+	}
+	return plain, nil
+}
+
+// encryptDocument encrypts the content of a document which is filed into the directory, if the directory wants that
+func encryptDocument(directory string, data []byte) ([]byte, error) {
+	if !IsEncryptedDirectory(directory) {
+		return data, nil
+	}
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(key, data)
+}
+
+// IsEncryptedFile reports whether the file at the given path has been encrypted by ding
+func IsEncryptedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(ENCRYPTION_MAGIC))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == ENCRYPTION_MAGIC
+}
+
+// plainPath returns a path where the document can be read in plain text
+// encrypted documents are decrypted into a private directory; cleanup removes the copy
+func plainPath(path string) (plain string, cleanup func(), err error) {
+	if !IsEncryptedFile(path) {
+		return path, func() {}, nil
+	}
+	key, err := encryptionKey()
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read %s: %s", path, err)
+	}
+	content, err := decrypt(key, data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := runtimeDirectory()
+	if err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.MkdirTemp(dir, "decrypted-")
+	if err != nil {
+		return "", nil, fmt.Errorf("could not create directory for decrypted document: %s", err)
+	}
+	plain = filepath.Join(tmp, filepath.Base(path))
+	if err := os.WriteFile(plain, content, 0600); err != nil {
+		os.RemoveAll(tmp)
+		return "", nil, fmt.Errorf("could not write decrypted document: %s", err)
+	}
+	return plain, func() { os.RemoveAll(tmp) }, nil
+}
+
+// CleanupDecrypted removes decrypted copies which are left over, e.g. because ding was closed
+// while a document was open in an external application
+func CleanupDecrypted() {
+	dir, err := runtimeDirectory()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !strings.HasPrefix(e.Name(), "decrypted-") {
+			continue
+		}
+		if time.Since(info.ModTime()) > DECRYPTED_OPEN_LIFETIME {
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+		}
+	}
+}
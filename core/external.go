@@ -4,22 +4,34 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 )
 
-const ()
+const (
+	NO_TEXT_PREVIEW = "- no OCR content -"
+)
 
 var (
 	DEPENDENCIES = map[string]string{
 		"pdftotext": "display textual preview of pdf",
+		"pdfinfo":   "page through the preview of multi-page pdfs",
+		"pdftoppm":  "show the first page of a pdf as image",
 		"xdg-open":  "open pdf in your default viewer",
 		"ocrmypdf":  "run ocr on pdf",
 		"img2pdf":   "convert image to pdf",
+		"mutool":    "extract text and convert images with mupdf instead of poppler and img2pdf",
+		"gs":        "make scanned pdfs smaller",
+		"qpdf":      "make pdfs smaller without loss and remove pdf passwords",
 		"ag":        "list your documents very fast",
 		"fzf":       "fuzzy search through your documents",
 		"rga":       "ripgrep-all - use in combination with fzf to fuzzy search your documents",
+		"scanimage": "scan documents straight into inbound",
+		"tesseract": "recognise text in scans and images, ocrmypdf needs it",
+		"git":       "keep the history of your documents directory",
 	}
 
 	redPrinter   = color.New(color.FgRed).SprintFunc()
@@ -35,10 +47,21 @@ func checkDep(dep string) bool {
 
 // functions which run external commands
 
+// DependencyNames returns the names of all external dependencies in a stable order
+func DependencyNames() []string {
+	names := make([]string, 0, len(DEPENDENCIES))
+	for dep := range DEPENDENCIES {
+		names = append(names, dep)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // PrintCheckDeps runs a check for all external dependecies and prints out the result
 func PrintCheckDeps() {
 	fmt.Printf("Running depency check for 'ding'...\n")
-	for dep, depFunction := range DEPENDENCIES {
+	for _, dep := range DependencyNames() {
+		depFunction := DEPENDENCIES[dep]
 		if checkDep(dep) {
 			fmt.Printf("%s: %-10s - You can %s.\n", greenPrinter("FOUND"), dep, depFunction)
 			continue
@@ -52,7 +75,7 @@ func PrintCheckDeps() {
 func CheckDependencies() (available, missing []string) {
 	available = make([]string, 0)
 	missing = make([]string, 0)
-	for _, dep := range DEPENDENCIES {
+	for _, dep := range DependencyNames() {
 		if checkDep(dep) {
 			available = append(available, dep)
 			continue
@@ -63,29 +86,86 @@ func CheckDependencies() (available, missing []string) {
 }
 
 // OpenDocExternal tries to open the document in the systems default application for given type
+// encrypted documents are opened as a decrypted copy which is removed after a while
 func OpenDocExternal(path string) error {
-	cmd := exec.Command("xdg-open", path)
-	err := cmd.Run()
+	plain, cleanup, err := plainPath(path)
 	if err != nil {
+		return fmt.Errorf("could not open document: %s", err)
+	}
+	err = DocOpener.Open(plain)
+	if err != nil {
+		cleanup()
 		return fmt.Errorf("could not open document in external preferred application: %v", err)
 	}
+	if plain != path {
+		time.AfterFunc(DECRYPTED_OPEN_LIFETIME, cleanup)
+	}
 	return nil
 }
 
-// GetDocPreview returns the text layer of a pdf as simple string by running the external commant
-// pdftotext on it
+// GetDocPreview returns the text layer of a pdf as simple string
 func GetDocPreview(name string) string {
-	//cmd := exec.Command("pdftotext", "-layout", "-f", "1", "-l", "1", filepath.Join(Inbound, name), "-")
-	cmd := exec.Command("pdftotext", "-f", "1", "-l", "1", filepath.Join(Inbound, name), "-")
-	output, err := cmd.CombinedOutput()
+	return getDocPagePreview(name, 1, false)
+}
+
+// getDocPagePreview returns the text layer of a single page of a pdf; with layout the physical layout
+// of the text is kept, otherwise text is in reading order
+// images have a single page whose text is recognised by ocr
+func getDocPagePreview(name string, page int, layout bool) string {
+	var text string
+	var err error
+	if IsImage(name) {
+		text, err = getImageText(filepath.Join(Inbound, name))
+	} else {
+		text, err = Extractor.PageText(filepath.Join(Inbound, name), page, layout)
+	}
 	if err != nil {
-		return fmt.Sprintf("could not get preview:\n\n%s\n\n%v", string(output), err)
+		if IsPasswordProtected(name) {
+			return PROTECTED_PREVIEW
+		}
+		return fmt.Sprintf("could not get preview:\n\n%v", err)
 	}
-	out := strings.TrimSpace(string(output))
+	out := strings.TrimSpace(text)
 	if out == "" {
-		out = "- no OCR content -"
+		out = NO_TEXT_PREVIEW
+	}
+	return out
+}
+
+// GetDocPageCount returns the number of pages of the given inbound pdf
+func GetDocPageCount(name string) (int, error) {
+	if IsImage(name) {
+		return 1, nil
+	}
+	pages, err := Extractor.PageCount(filepath.Join(Inbound, name))
+	if err != nil {
+		return 0, fmt.Errorf("could not get page count: %s", err)
+	}
+	return pages, nil
+}
+
+// GetDocText returns the whole text layer of the pdf at the given path, or the text recognised in an image
+// encrypted documents are decrypted for the time it takes
+func GetDocText(path string) (string, error) {
+	plain, cleanup, err := plainPath(path)
+	if err == ErrLocked {
+		return "", err
 	}
-	return string(out)
+	if err != nil {
+		return "", fmt.Errorf("could not extract text from %s: %s", path, err)
+	}
+	defer cleanup()
+
+	var text string
+	if IsImage(path) {
+		text, err = getImageText(plain)
+	} else {
+		text, err = Extractor.PageText(plain, 0, false)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not extract text from %s: %s", path, err)
+	}
+	return strings.TrimSpace(text), nil
 }
 
 // GetOcrInboundFunc returns a function that can be run async to iterate all inbound files
@@ -103,7 +183,7 @@ func GetOcrInboundFunc(progress chan float32, currentFile chan string) (runner f
 			for i, f := range files {
 				progress <- float32(i) / float32(fileCnt)
 				currentFile <- f.Name()
-				OcrPdf(f.Name())
+				OcrInboundFile(f.Name())
 			}
 			progress <- 1.0
 			currentFile <- ""
@@ -113,43 +193,18 @@ func GetOcrInboundFunc(progress chan float32, currentFile chan string) (runner f
 	return nil, err
 }
 
-var (
-	OCRMYPDF_ERRCODES = map[int]string{
-		0:   "Everything worked as expected.",
-		1:   "Invalid arguments, exited with an error.",
-		2:   "The input file does not seem to be a valid PDF.",
-		3:   "An external program required by OCRmyPDF is missing.",
-		4:   "An output file was created, but it does not seem to be a valid PDF. The file will be available.",
-		5:   "The user running OCRmyPDF does not have sufficient permissions to read the input file and write the output file.",
-		6:   "The file already appears to contain text so it may not need OCR. See output message.",
-		7:   "An error occurred in an external program (child process) and OCRmyPDF cannot continue.",
-		8:   "The input PDF is encrypted. OCRmyPDF does not read encrypted PDFs. Use another program such as qpdf to remove encryption.",
-		9:   "A custom configuration file was forwarded to Tesseract using --tesseract-config, and Tesseract rejected this file.",
-		10:  "A valid PDF was created, PDF/A conversion failed. The file will be available.",
-		15:  "Some other error occurred.",
-		130: "The program was interrupted by pressing Ctrl+C.",
-	}
-)
-
-// OcrPdf runs the external command ocrmypdf and so tries to add a text layer to scans
+// OcrPdf tries to add a text layer to scans with the configured ocr engine
 func OcrPdf(name string) error {
-	f := filepath.Join(Inbound, name)
-	cmd := exec.Command("ocrmypdf", "-q", "-l", "deu", "--redo-ocr", f, f)
-
-	//var outb, errb bytes.Buffer
-	//cmd.Stdout = &outb
-	//cmd.Stderr = &errb
-	//fmt.Println("out:", outb.String(), "err:", errb.String())
-
-	_, err := cmd.CombinedOutput()
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("ocr error: %v", OCRMYPDF_ERRCODES[exitError.ExitCode()])
-		}
-		return fmt.Errorf("ocr error: %v", err)
+	if err := OCR.Ocr(filepath.Join(Inbound, name), Conf.OcrLanguage()); err != nil {
+		return err
 	}
 
-	go UpdateFilePreviewCache(name)
+	ocrDoneMu.Lock()
+	ocrDone[name] = true
+	ocrDoneMu.Unlock()
+
+	// not in the background; filing the document right after reads the preview for its sidecar
+	UpdateFilePreviewCache(name)
 
 	return nil
 }
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	FEATURE_OPEN      = "open"
+	FEATURE_OCR       = "ocr"
+	FEATURE_THUMBNAIL = "thumbnail"
+	FEATURE_OPTIMIZE  = "optimize"
+	FEATURE_UNPROTECT = "unprotect"
+	FEATURE_SCAN      = "scan"
+)
+
+// feature is something the uis offer which needs an external tool
+type feature struct {
+	description string
+	// any of the tools will do
+	tools []string
+	// if set, the feature needs all tools of the configured backend instead
+	backend func() interface{}
+}
+
+var (
+	FEATURES = map[string]feature{
+		FEATURE_OPEN:      {"open documents in your viewer", nil, func() interface{} { return DocOpener }},
+		FEATURE_OCR:       {"run ocr on scans", nil, func() interface{} { return OCR }},
+		FEATURE_THUMBNAIL: {"show the first page of a pdf as image", []string{"pdftoppm"}, nil},
+		FEATURE_OPTIMIZE:  {"make pdfs smaller", []string{OPTIMIZE_OCRMYPDF, OPTIMIZE_GS, OPTIMIZE_QPDF}, nil},
+		FEATURE_UNPROTECT: {"remove pdf passwords", []string{"qpdf"}, nil},
+		FEATURE_SCAN:      {"scan documents", []string{"scanimage"}, nil},
+	}
+
+	featureAvailable map[string]bool
+	featuresMu       sync.Mutex
+)
+
+// CheckFeatures looks up which features the installed tools allow; the uis call it once at startup
+func CheckFeatures() {
+	available := make(map[string]bool)
+	for name, f := range FEATURES {
+		if f.backend != nil {
+			available[name] = len(missingTools(backendTools(f.backend()))) == 0
+			continue
+		}
+		for _, tool := range f.tools {
+			if checkDep(tool) {
+				available[name] = true
+				break
+			}
+		}
+	}
+	// an explicitly configured optimizer has to be there
+	if available[FEATURE_OPTIMIZE] {
+		_, err := Conf.Optimize.optimizeTool()
+		available[FEATURE_OPTIMIZE] = err == nil
+	}
+
+	featuresMu.Lock()
+	featureAvailable = available
+	featuresMu.Unlock()
+}
+
+// FeatureAvailable reports whether the tools for the feature are installed
+func FeatureAvailable(name string) bool {
+	featuresMu.Lock()
+	checked := featureAvailable != nil
+	featuresMu.Unlock()
+	if !checked {
+		CheckFeatures()
+	}
+
+	featuresMu.Lock()
+	defer featuresMu.Unlock()
+	return featureAvailable[name]
+}
+
+// MissingFeatureHint tells the user what to install to use the feature
+func MissingFeatureHint(name string) string {
+	f, ok := FEATURES[name]
+	if !ok {
+		return fmt.Sprintf("unknown feature %s", name)
+	}
+	if name == FEATURE_OPTIMIZE && Conf.Optimize.Tool != "" && Conf.Optimize.Tool != OPTIMIZE_AUTO {
+		return fmt.Sprintf("Install %s to %s, or change the optimize tool in the config", Conf.Optimize.Tool, f.description)
+	}
+	if f.backend != nil {
+		return fmt.Sprintf("Install %s to %s, or choose another backend in the config", strings.Join(missingTools(backendTools(f.backend())), " and "), f.description)
+	}
+	if len(f.tools) == 1 {
+		return fmt.Sprintf("Install %s to %s", f.tools[0], f.description)
+	}
+	return fmt.Sprintf("Install %s or %s to %s", strings.Join(f.tools[:len(f.tools)-1], ", "), f.tools[len(f.tools)-1], f.description)
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GIT_COMMIT_PREFIX = "ding: "
+)
+
+var gitMu sync.Mutex
+
+// GitLogEntry is a commit in the history of the documents directory
+type GitLogEntry struct {
+	Hash    string    `json:"hash"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+	Files   []string  `json:"files"`
+}
+
+// git runs git in the destination and returns its trimmed output
+func git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", Dest}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsGitRepository reports whether the destination is inside a git repository
+func IsGitRepository() bool {
+	if !checkDep("git") {
+		return false
+	}
+	_, err := git("rev-parse", "--show-toplevel")
+	return err == nil
+}
+
+// gitEnabled reports whether changes of the documents should be committed
+func gitEnabled() bool {
+	return Conf.Git && IsGitRepository()
+}
+
+// commitDocuments commits the given documents and their sidecars if git versioning is enabled
+// paths are relative to the destination; documents which are gone are committed as deleted
+func commitDocuments(message string, rels ...string) error {
+	if !gitEnabled() {
+		return nil
+	}
+	gitMu.Lock()
+	defer gitMu.Unlock()
+
+	paths := make([]string, 0, len(rels)*2)
+	for _, rel := range rels {
+		for _, path := range []string{rel, SidecarPath(rel)} {
+			if _, err := os.Stat(filepath.Join(Dest, path)); err == nil {
+				paths = append(paths, path)
+				continue
+			}
+			// a path which is gone only matters if git knows it
+			if _, err := git("ls-files", "--error-unmatch", "--", path); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if err := checkGitLfs(paths); err != nil {
+		return err
+	}
+	if _, err := git(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	// e.g. a sidecar which was written without changes; git would refuse the empty commit
+	if _, err := git(append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return nil
+	}
+	if _, err := git(append([]string{"commit", "-q", "-m", GIT_COMMIT_PREFIX + message, "--"}, paths...)...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkGitLfs makes sure documents which are meant for git lfs do not end up in the repository itself
+func checkGitLfs(paths []string) error {
+	output, err := git(append([]string{"check-attr", "filter", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasSuffix(line, ": filter: lfs") {
+			continue
+		}
+		if _, err := git("lfs", "version"); err != nil {
+			return fmt.Errorf("%s is tracked by git lfs, but git lfs is not installed", strings.TrimSuffix(line, ": filter: lfs"))
+		}
+		return nil
+	}
+	return nil
+}
+
+// GitLog returns the latest commits of the documents directory, optionally only those touching the given path
+// paths of the files are relative to the destination; sidecars are left out
+func GitLog(limit int, rel string) ([]GitLogEntry, error) {
+	if !IsGitRepository() {
+		return nil, fmt.Errorf("%s is no git repository", Dest)
+	}
+
+	args := []string{"log", "--relative", "--name-only", "--format=%x1e%H%x1f%aI%x1f%s"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("-n%v", limit))
+	}
+	args = append(args, "--")
+	if rel != "" {
+		args = append(args, rel)
+	}
+	output, err := git(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]GitLogEntry, 0)
+	for _, record := range strings.Split(output, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[1])
+		entry := GitLogEntry{Hash: fields[0], Date: date, Message: fields[2], Files: []string{}}
+		for _, file := range lines[1:] {
+			if file = strings.TrimSpace(file); file != "" && !IsSidecar(file) {
+				entry.Files = append(entry.Files, file)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// hashes of all documents in the destination; key is the path relative to the destination
+	hashIndex   map[string]hashedFile
+	hashIndexMu sync.Mutex
+
+	// hashes of inbound files; key is the file name
+	inboundHashCache map[string]hashedFile
+	inboundHashMu    sync.Mutex
+)
+
+func init() {
+	hashIndex = make(map[string]hashedFile)
+	inboundHashCache = make(map[string]hashedFile)
+}
+
+type hashedFile struct {
+	modTime time.Time
+	size    int64
+	hash    string
+	// hash of the document before ding changed it, e.g. by writing metadata; taken from the sidecar
+	original string
+	// the sidecar could not be read as encrypted documents were locked; it is read again by the next warm-up
+	sidecarLocked bool
+}
+
+// contentHash returns the hash which identifies the content of the document as it was scanned
+func (f hashedFile) contentHash() string {
+	if f.original != "" {
+		return f.original
+	}
+	return f.hash
+}
+
+// DuplicateError is returned when a document which should be filed already exists in the destination
+type DuplicateError struct {
+	Name   string
+	Copies []string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s is already archived as %s", e.Name, strings.Join(e.Copies, ", "))
+}
+
+// HashFile returns the hex encoded sha256 sum of the file at the given path
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open file for hashing: %s", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("could not hash file: %s", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WarmHashIndex hashes all documents in the destination (including sub directories) and puts them into the index
+// only new or changed files are hashed again
+func WarmHashIndex() error {
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return fmt.Errorf("could not index destination directory: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, rel := range documents {
+		seen[rel] = true
+
+		path := filepath.Join(Dest, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		hashIndexMu.Lock()
+		known, ok := hashIndex[rel]
+		hashIndexMu.Unlock()
+		hash := known.hash
+		if !ok || !known.modTime.Equal(info.ModTime()) || known.size != info.Size() {
+			if hash, err = HashFile(path); err != nil {
+				continue
+			}
+		} else if !known.sidecarLocked {
+			continue
+		}
+
+		original := ""
+		sidecar, err := ReadSidecar(rel)
+		if err == nil {
+			original = sidecar.Hash
+		}
+		hashIndexMu.Lock()
+		hashIndex[rel] = hashedFile{modTime: info.ModTime(), size: info.Size(), hash: hash, original: original, sidecarLocked: err == ErrLocked}
+		hashIndexMu.Unlock()
+	}
+
+	hashIndexMu.Lock()
+	defer hashIndexMu.Unlock()
+	for rel := range hashIndex {
+		if !seen[rel] {
+			delete(hashIndex, rel)
+		}
+	}
+
+	return nil
+}
+
+// addToHashIndex puts a freshly written document into the index
+// original is the hash of the inbound file, which differs from hash if ding changed the document
+func addToHashIndex(path, hash, original string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(Dest, path)
+	if err != nil {
+		return
+	}
+
+	hashIndexMu.Lock()
+	defer hashIndexMu.Unlock()
+	hashIndex[rel] = hashedFile{modTime: info.ModTime(), size: info.Size(), hash: hash, original: original}
+}
+
+// documentsWithHash returns the paths relative to the destination of all indexed documents with the given hash
+func documentsWithHash(hash string) []string {
+	hashIndexMu.Lock()
+	defer hashIndexMu.Unlock()
+
+	copies := make([]string, 0)
+	for rel, f := range hashIndex {
+		if f.hash == hash || f.original == hash {
+			copies = append(copies, rel)
+		}
+	}
+	sort.Strings(copies)
+	return copies
+}
+
+// inboundFileHash returns the hash of the given inbound file; hashes are cached as long as the file does not change
+func inboundFileHash(name string) (string, error) {
+	path := filepath.Join(Inbound, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read inbound file: %s", err)
+	}
+
+	inboundHashMu.Lock()
+	known, ok := inboundHashCache[name]
+	inboundHashMu.Unlock()
+	if ok && known.modTime.Equal(info.ModTime()) && known.size == info.Size() {
+		return known.hash, nil
+	}
+
+	hash, err := HashFile(path)
+	if err != nil {
+		return "", err
+	}
+	inboundHashMu.Lock()
+	defer inboundHashMu.Unlock()
+	inboundHashCache[name] = hashedFile{modTime: info.ModTime(), size: info.Size(), hash: hash}
+	return hash, nil
+}
+
+// GetArchivedCopies returns the paths relative to the destination of documents which are identical to the given
+// inbound file; it only looks at the index as it is, see WarmHashIndex
+func GetArchivedCopies(name string) []string {
+	hash, err := inboundFileHash(name)
+	if err != nil {
+		return nil
+	}
+	return documentsWithHash(hash)
+}
+
+// FindArchivedCopies brings the index up to date and returns the paths relative to the destination
+// of documents which are identical to the given inbound file
+func FindArchivedCopies(name string) ([]string, error) {
+	if err := WarmHashIndex(); err != nil {
+		return nil, err
+	}
+	hash, err := inboundFileHash(name)
+	if err != nil {
+		return nil, err
+	}
+	return documentsWithHash(hash), nil
+}
@@ -1,10 +1,13 @@
 package core
 
 import (
+	"fmt"
 	"io/fs"
+	"math"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 func sortFilesByModTime(files []fs.DirEntry) {
@@ -23,3 +26,16 @@ func checkFixExtension(name, newName string) string {
 	}
 	return newName
 }
+
+// FormatSize returns a file size in Mib, rounded to two decimals
+func FormatSize(size int64) string {
+	return fmt.Sprintf("%v Mib", math.Round(float64(size)*100/1048576)/100)
+}
+
+// FormatAge returns a duration in days, or hours if it is less than a day
+func FormatAge(age time.Duration) string {
+	if age < 24*time.Hour {
+		return fmt.Sprintf("%v hours", int(age.Hours()))
+	}
+	return fmt.Sprintf("%v days", int(age.Hours()/24))
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	PRE_INGEST  = "pre_ingest"
+	POST_INGEST = "post_ingest"
+)
+
+var hookLogMu sync.Mutex
+
+// HookLogPath returns where the output of hooks is logged, following the XDG base directory specification
+func HookLogPath() string {
+	return filepath.Join(stateDirectory(), "hooks.log")
+}
+
+// stateDirectory returns where ding keeps logs and records, following the XDG base directory specification
+func stateDirectory() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, _ := homedir.Dir()
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "ding")
+}
+
+// withHooks puts the pre and post ingest hooks of the config around the move step
+// the pre hook aborts the pipeline when it fails, the post hook can not undo anything anymore
+func withHooks(pipeline []PipelineStep) []PipelineStep {
+	if Conf.PreIngest == "" && Conf.PostIngest == "" {
+		return pipeline
+	}
+
+	hooked := make([]PipelineStep, 0, len(pipeline)+2)
+	for _, step := range pipeline {
+		if step.Step == STEP_MOVE && Conf.PreIngest != "" {
+			hooked = append(hooked, PipelineStep{Step: STEP_HOOK, Name: PRE_INGEST, Command: []string{"sh", "-c", Conf.PreIngest}})
+		}
+		hooked = append(hooked, step)
+		if step.Step == STEP_MOVE && Conf.PostIngest != "" {
+			hooked = append(hooked, PipelineStep{Step: STEP_HOOK, Name: POST_INGEST, OnError: ON_ERROR_SKIP, Command: []string{"sh", "-c", Conf.PostIngest}})
+		}
+	}
+	return hooked
+}
+
+// logHook appends the output of a hook to the hook log; the log is a convenience, so errors are ignored
+func logHook(name string, command []string, job *IngestJob, output string, err error) {
+	hookLogMu.Lock()
+	defer hookLogMu.Unlock()
+
+	path := HookLogPath()
+	if mkErr := os.MkdirAll(filepath.Dir(path), 0755); mkErr != nil {
+		return
+	}
+	f, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return
+	}
+	defer f.Close()
+
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+	fmt.Fprintf(f, "%s %s %s: %s\n", time.Now().Format(time.RFC3339), name, job.Original, strings.Join(command, " "))
+	for _, line := range job.environment() {
+		fmt.Fprintf(f, "  %s\n", line)
+	}
+	if output != "" {
+		fmt.Fprintf(f, "  %s\n", strings.ReplaceAll(output, "\n", "\n  "))
+	}
+	fmt.Fprintf(f, "  -> %s\n", status)
+}
+
+// firstLine returns the first line of the output, marking that there is more
+func firstLine(output string) string {
+	lines := strings.SplitN(output, "\n", 2)
+	if len(lines) > 1 {
+		return strings.TrimSpace(lines[0]) + " …"
+	}
+	return lines[0]
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type imageText struct {
+	modTime time.Time
+	text    string
+}
+
+var (
+	// recognising text in images takes a while, so it is only done again if the image changed
+	imageTextCache = make(map[string]imageText)
+	imageTextMu    sync.Mutex
+)
+
+// IsImage reports whether the file is an image ding can recognise text in and convert
+func IsImage(name string) bool {
+	return CONVERTIBLE_IMAGES[strings.ToLower(filepath.Ext(name))]
+}
+
+// getImageText returns the text recognised in the image at the given path
+func getImageText(path string) (string, error) {
+	if missing := missingTools(backendTools(ImageOCR)); len(missing) > 0 {
+		return "", fmt.Errorf("install %s to read text in images", strings.Join(missing, " and "))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %s", path, err)
+	}
+
+	imageTextMu.Lock()
+	cached, ok := imageTextCache[path]
+	imageTextMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.text, nil
+	}
+
+	text, err := ImageOCR.ImageText(path, Conf.OcrLanguage())
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+
+	imageTextMu.Lock()
+	imageTextCache[path] = imageText{modTime: info.ModTime(), text: text}
+	imageTextMu.Unlock()
+	return text, nil
+}
+
+// OcrImage turns the inbound image into a searchable pdf next to it and removes the image
+// it returns the name of the pdf
+func OcrImage(name string) (string, error) {
+	newName, err := imageToSearchablePdf(name)
+	if err != nil {
+		return "", err
+	}
+	if err := DeleteInboundFile(name); err != nil {
+		return "", err
+	}
+	return newName, nil
+}
+
+// imageToSearchablePdf writes a searchable pdf of the inbound image next to it; the image is kept
+func imageToSearchablePdf(name string) (string, error) {
+	if missing := missingTools(backendTools(ImageOCR)); len(missing) > 0 {
+		return "", fmt.Errorf("ocr error: %s is not installed", strings.Join(missing, ", "))
+	}
+
+	newName := strings.TrimSuffix(name, filepath.Ext(name)) + ".pdf"
+	target := filepath.Join(Inbound, newName)
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("ocr error: %s already exists", newName)
+	}
+	if err := ImageOCR.ImageToPdf(filepath.Join(Inbound, name), target, Conf.OcrLanguage()); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("ocr error: %s", err)
+	}
+
+	ocrDoneMu.Lock()
+	ocrDone[newName] = true
+	ocrDoneMu.Unlock()
+
+	queuePreviewUpdate(newName)
+
+	return newName, nil
+}
+
+// OcrInboundFile adds a text layer to the inbound pdf or turns the image into a searchable pdf
+// it returns the name of the file afterwards, which differs from the given one for images
+func OcrInboundFile(name string) (string, error) {
+	if IsImage(name) {
+		return OcrImage(name)
+	}
+	return name, OcrPdf(name)
+}
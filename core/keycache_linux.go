@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// the key is kept in the kernel keyring of the user; it never touches the disk and is gone on reboot or timeout
+const (
+	// other ding processes of the user find the key, so ding unlock works for the uis
+	KEY_CACHE_SHARED = true
+
+	keyringKeyType = "user"
+	// possessor and user may view, read, write, search, link and set attributes
+	keyringKeyPerm = 0x3f3f0000
+)
+
+// keyringDescription names the key of the destination in the keyring
+func keyringDescription() string {
+	return "ding:" + Dest
+}
+
+// storeCachedKey puts the key into the keyring; the kernel removes it when it expires
+func storeCachedKey(key cachedKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	id, err := unix.AddKey(keyringKeyType, keyringDescription(), data, unix.KEY_SPEC_USER_KEYRING)
+	if err != nil {
+		return err
+	}
+	if err := unix.KeyctlSetperm(id, keyringKeyPerm); err != nil {
+		return err
+	}
+	timeout := math.Ceil(time.Until(key.Expires).Seconds())
+	_, err = unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, int(math.Max(timeout, 1)), 0, 0)
+	return err
+}
+
+// loadCachedKey reads the key from the keyring
+func loadCachedKey() (cachedKey, error) {
+	var key cachedKey
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, keyringKeyType, keyringDescription(), 0)
+	if err != nil {
+		return key, err
+	}
+	data := make([]byte, 512)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, data, 0)
+	if err != nil {
+		return key, err
+	}
+	if n > len(data) {
+		data = make([]byte, n)
+		if n, err = unix.KeyctlBuffer(unix.KEYCTL_READ, id, data, 0); err != nil {
+			return key, err
+		}
+	}
+	err = json.Unmarshal(data[:n], &key)
+	return key, err
+}
+
+// forgetCachedKey removes the key from the keyring
+func forgetCachedKey() error {
+	id, err := unix.KeyctlSearch(unix.KEY_SPEC_USER_KEYRING, keyringKeyType, keyringDescription(), 0)
+	if err != nil {
+		// nothing to forget
+		return nil
+	}
+	_, err = unix.KeyctlInt(unix.KEYCTL_UNLINK, id, unix.KEY_SPEC_USER_KEYRING, 0, 0)
+	return err
+}
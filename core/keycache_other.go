@@ -0,0 +1,43 @@
+//go:build !linux
+// +build !linux
+
+package core
+
+import (
+	"os"
+	"sync"
+)
+
+// without a kernel keyring the key is only kept by the running process
+const KEY_CACHE_SHARED = false
+
+var (
+	memoryKey   *cachedKey
+	memoryKeyMu sync.Mutex
+)
+
+// storeCachedKey keeps the key in memory
+func storeCachedKey(key cachedKey) error {
+	memoryKeyMu.Lock()
+	defer memoryKeyMu.Unlock()
+	memoryKey = &key
+	return nil
+}
+
+// loadCachedKey returns the key kept in memory
+func loadCachedKey() (cachedKey, error) {
+	memoryKeyMu.Lock()
+	defer memoryKeyMu.Unlock()
+	if memoryKey == nil {
+		return cachedKey{}, os.ErrNotExist
+	}
+	return *memoryKey, nil
+}
+
+// forgetCachedKey drops the key kept in memory
+func forgetCachedKey() error {
+	memoryKeyMu.Lock()
+	defer memoryKeyMu.Unlock()
+	memoryKey = nil
+	return nil
+}
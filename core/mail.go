@@ -0,0 +1,452 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	MAIL_RECORD_FILE    = "imported-mail.json"
+	MAIL_SUBJECT_LENGTH = 60
+	// images below this size are mostly logos of signatures
+	MAIL_MIN_IMAGE_SIZE = 20 * 1024
+)
+
+var (
+	// types of attachments which are imported, by extension
+	MAIL_ATTACHMENT_TYPES = map[string]string{
+		"application/pdf": ".pdf",
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+		"image/tiff":      ".tiff",
+	}
+
+	// prefixes of replies and forwards which do not belong into the name
+	mailSubjectPrefix = regexp.MustCompile(`(?i)^((re|aw|fwd?|wg)\s*:\s*)+`)
+
+	mailRecordMu sync.Mutex
+)
+
+// MailImport is the outcome of importing a single message
+type MailImport struct {
+	MessageID string   `json:"message_id"`
+	From      string   `json:"from"`
+	Subject   string   `json:"subject"`
+	Files     []string `json:"files"`
+	// the message has been imported before
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type mailAttachment struct {
+	ext  string
+	data []byte
+}
+
+// headerGetter is implemented by the headers of messages and of their parts
+type headerGetter interface {
+	Get(key string) string
+}
+
+// MailRecordPath returns where the ids of imported messages are kept
+func MailRecordPath() string {
+	return filepath.Join(stateDirectory(), MAIL_RECORD_FILE)
+}
+
+func readMailRecord() (map[string]time.Time, error) {
+	record := make(map[string]time.Time)
+	data, err := os.ReadFile(MailRecordPath())
+	if os.IsNotExist(err) {
+		return record, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read record of imported mail: %s", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("could not parse record of imported mail %s: %s", MailRecordPath(), err)
+	}
+	return record, nil
+}
+
+func writeMailRecord(record map[string]time.Time) error {
+	path := MailRecordPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not write record of imported mail: %s", err)
+	}
+	// message ids are in angle brackets, which should stay readable
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(record)
+	if err := os.WriteFile(path, data.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write record of imported mail: %s", err)
+	}
+	return nil
+}
+
+// ImportMail puts the pdf and image attachments of the messages in a maildir, an mbox or an .eml file into inbound
+// messages which have been imported before are skipped
+func ImportMail(source string) ([]MailImport, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", source, err)
+	}
+
+	var messages [][]byte
+	switch {
+	case info.IsDir():
+		messages, err = readMaildir(source)
+	case strings.EqualFold(filepath.Ext(source), ".eml"):
+		var data []byte
+		data, err = os.ReadFile(source)
+		messages = [][]byte{data}
+	default:
+		messages, err = readMbox(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return importMessages(messages)
+}
+
+// ImportInboundMails imports the .eml files in inbound and removes them once their attachments are there
+func ImportInboundMails() ([]MailImport, error) {
+	files, err := os.ReadDir(Inbound)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inbound directory: %s", err)
+	}
+
+	imports := make([]MailImport, 0)
+	for _, f := range files {
+		if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), ".eml") {
+			continue
+		}
+		imported, err := ImportMail(filepath.Join(Inbound, f.Name()))
+		if err != nil {
+			return imports, err
+		}
+		imports = append(imports, imported...)
+		if imported[0].Error == "" {
+			if err := DeleteInboundFile(f.Name()); err != nil {
+				return imports, err
+			}
+		}
+	}
+	return imports, nil
+}
+
+// readMaildir returns the messages in new and cur of the maildir
+func readMaildir(dir string) ([][]byte, error) {
+	paths := make([]string, 0)
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read maildir: %s", err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				paths = append(paths, filepath.Join(dir, sub, e.Name()))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(filepath.Join(dir, "cur")); err != nil {
+			return nil, fmt.Errorf("%s is no maildir, it has neither new nor cur", dir)
+		}
+	}
+	sort.Strings(paths)
+
+	messages := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read message: %s", err)
+		}
+		messages = append(messages, data)
+	}
+	return messages, nil
+}
+
+// readMbox splits an mbox into its messages; lines starting with "From " after an empty line begin a new message
+func readMbox(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mbox: %s", err)
+	}
+	defer f.Close()
+
+	messages := make([][]byte, 0)
+	var current *bytes.Buffer
+	previousEmpty := true
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case previousEmpty && bytes.HasPrefix(line, []byte("From ")):
+				if current != nil {
+					messages = append(messages, current.Bytes())
+				}
+				current = &bytes.Buffer{}
+			case current == nil:
+				return nil, fmt.Errorf("%s is no mbox", path)
+			default:
+				// mboxrd quotes lines starting with From by >
+				if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>' {
+					line = line[1:]
+				}
+				current.Write(line)
+			}
+			previousEmpty = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read mbox: %s", err)
+		}
+	}
+	if current != nil {
+		messages = append(messages, current.Bytes())
+	}
+	return messages, nil
+}
+
+// importMessages extracts the attachments of all messages which are not in the record yet
+func importMessages(messages [][]byte) ([]MailImport, error) {
+	mailRecordMu.Lock()
+	defer mailRecordMu.Unlock()
+
+	record, err := readMailRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make([]MailImport, 0, len(messages))
+	for _, raw := range messages {
+		imported := importMessage(raw, record)
+		imports = append(imports, imported)
+		if imported.Error != "" || imported.Skipped {
+			continue
+		}
+		// written after every message, so an interrupted import does not import twice
+		record[imported.MessageID] = time.Now()
+		if err := writeMailRecord(record); err != nil {
+			return imports, err
+		}
+	}
+	return imports, nil
+}
+
+func importMessage(raw []byte, record map[string]time.Time) MailImport {
+	var imported MailImport
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		imported.Error = fmt.Sprintf("could not parse message: %s", err)
+		return imported
+	}
+
+	decoder := &mime.WordDecoder{}
+	imported.MessageID = strings.TrimSpace(msg.Header.Get("Message-Id"))
+	if imported.MessageID == "" {
+		imported.MessageID = fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+	}
+	imported.Subject = decodeMailHeader(decoder, msg.Header.Get("Subject"))
+	from, _ := (&mail.AddressParser{WordDecoder: decoder}).Parse(msg.Header.Get("From"))
+	if from != nil {
+		imported.From = from.Address
+		if from.Name != "" {
+			imported.From = fmt.Sprintf("%s <%s>", from.Name, from.Address)
+		}
+	}
+	imported.Files = []string{}
+
+	if _, ok := record[imported.MessageID]; ok {
+		imported.Skipped = true
+		return imported
+	}
+
+	attachments := make([]mailAttachment, 0)
+	if err := walkMailPart(msg.Header, msg.Body, decoder, &attachments); err != nil {
+		imported.Error = fmt.Sprintf("could not read attachments: %s", err)
+		return imported
+	}
+
+	date, err := msg.Header.Date()
+	if err != nil {
+		date = time.Now()
+	}
+	base := date.Format(SIDECAR_DATE_FORMAT) + "_" + mailSender(from) + "_" + mailSubject(imported.Subject)
+	for i, a := range attachments {
+		name := base + a.ext
+		if len(attachments) > 1 {
+			name = fmt.Sprintf("%s_%v%s", base, i+1, a.ext)
+		}
+		name = freeInboundName(name)
+		if err := writeIntoInbound(name, bytes.NewReader(a.data)); err != nil {
+			// the message is not recorded as imported, so the attachments written so far have to go;
+			// they would be there twice after the next import
+			for _, written := range imported.Files {
+				DeleteInboundFile(written)
+			}
+			imported.Files = []string{}
+			imported.Error = err.Error()
+			return imported
+		}
+		imported.Files = append(imported.Files, name)
+	}
+	for _, name := range imported.Files {
+		queuePreviewUpdate(name)
+	}
+	return imported
+}
+
+// walkMailPart collects the attachments of the part and of all parts inside it
+func walkMailPart(header headerGetter, body io.Reader, decoder *mime.WordDecoder, attachments *[]mailAttachment) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkMailPart(part.Header, part, decoder, attachments); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	filename = decodeMailHeader(decoder, filename)
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != ".pdf" && !CONVERTIBLE_IMAGES[ext] {
+		ext = MAIL_ATTACHMENT_TYPES[mediaType]
+	}
+	if ext == "" {
+		return nil
+	}
+
+	// the multipart reader removes the quoted-printable encoding of parts and drops the header,
+	// messages which consist of the attachment alone still have it
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	// images shown in the text, like logos, are no documents
+	if ext != ".pdf" && (disposition == "inline" || len(data) < MAIL_MIN_IMAGE_SIZE) {
+		return nil
+	}
+	*attachments = append(*attachments, mailAttachment{ext: ext, data: data})
+	return nil
+}
+
+// decodeMailHeader decodes encoded words like =?UTF-8?Q?Rechnung?=; undecodable values are kept as they are
+func decodeMailHeader(decoder *mime.WordDecoder, value string) string {
+	decoded, err := decoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// mailSender returns a short name of the sender for file names, e.g. stadtwerke
+// the name of the sender is preferred, otherwise the domain is used
+func mailSender(from *mail.Address) string {
+	if from == nil {
+		return "unknown"
+	}
+	if name := slugify(strings.ToLower(from.Name)); name != "" {
+		return name
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	labels := strings.Split(domain, ".")
+	if len(labels) > 1 {
+		domain = labels[len(labels)-2]
+	}
+	if name := slugify(strings.ToLower(domain)); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// mailSubject returns the subject without reply and forward prefixes for file names
+func mailSubject(subject string) string {
+	subject = slugify(mailSubjectPrefix.ReplaceAllString(strings.TrimSpace(subject), ""))
+	if runes := []rune(subject); len(runes) > MAIL_SUBJECT_LENGTH {
+		subject = strings.TrimRight(string(runes[:MAIL_SUBJECT_LENGTH]), "-")
+	}
+	if subject == "" {
+		return "mail"
+	}
+	return subject
+}
+
+// slugify keeps letters and digits and joins everything else into single dashes
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// freeInboundName returns the name, or the name with a counter if a file of that name is in inbound already
+func freeInboundName(name string) string {
+	ext := filepath.Ext(name)
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(Inbound, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%v%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportMessage(t *testing.T) {
+	pdf := "%PDF-1.4\n% caf\xc3\xa9 =\n%%EOF"
+
+	tests := []struct {
+		name    string
+		message string
+		files   []string
+	}{
+		{
+			name: "quoted-printable attachment alone",
+			message: "From: Stadtwerke <rechnung@stadtwerke.example>\r\n" +
+				"Subject: Rechnung\r\n" +
+				"Date: Fri, 02 Jan 2026 10:00:00 +0100\r\n" +
+				"Message-Id: <1@stadtwerke.example>\r\n" +
+				"Content-Type: application/pdf; name=\"rechnung.pdf\"\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"%PDF-1.4\r\n% caf=C3=A9 =3D\r\n%%EOF",
+			files: []string{"2026-01-02_stadtwerke_Rechnung.pdf"},
+		},
+		{
+			name: "base64 attachment alone",
+			message: "From: rechnung@stadtwerke.example\r\n" +
+				"Subject: Rechnung\r\n" +
+				"Date: Fri, 02 Jan 2026 10:00:00 +0100\r\n" +
+				"Message-Id: <2@stadtwerke.example>\r\n" +
+				"Content-Type: application/pdf\r\n" +
+				"Content-Transfer-Encoding: base64\r\n" +
+				"\r\n" +
+				"JVBERi0xLjQKJSBjYWbDqSA9CiUlRU9G\r\n",
+			files: []string{"2026-01-02_stadtwerke_Rechnung.pdf"},
+		},
+		{
+			name: "quoted-printable attachment in multipart",
+			message: "From: Stadtwerke <rechnung@stadtwerke.example>\r\n" +
+				"Subject: Rechnung\r\n" +
+				"Date: Fri, 02 Jan 2026 10:00:00 +0100\r\n" +
+				"Message-Id: <3@stadtwerke.example>\r\n" +
+				"Content-Type: multipart/mixed; boundary=\"b\"\r\n" +
+				"\r\n" +
+				"--b\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"\r\n" +
+				"Ihre Rechnung\r\n" +
+				"--b\r\n" +
+				"Content-Type: application/pdf\r\n" +
+				"Content-Disposition: attachment; filename=\"rechnung.pdf\"\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"%PDF-1.4\r\n% caf=C3=A9 =3D\r\n%%EOF\r\n" +
+				"--b--\r\n",
+			files: []string{"2026-01-02_stadtwerke_Rechnung.pdf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIngest(t)
+
+			imported := importMessage([]byte(tt.message), map[string]time.Time{})
+			if imported.Error != "" {
+				t.Fatalf("import failed: %s", imported.Error)
+			}
+			if strings.Join(imported.Files, ",") != strings.Join(tt.files, ",") {
+				t.Fatalf("expected files %v, got %v", tt.files, imported.Files)
+			}
+			for _, name := range imported.Files {
+				data, err := ioutil.ReadFile(filepath.Join(Inbound, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.ReplaceAll(string(data), "\r\n", "\n") != pdf {
+					t.Errorf("attachment is not decoded: %q", data)
+				}
+			}
+			if names := inboundNames(t); len(names) != len(tt.files) {
+				t.Errorf("partial files are left over: %v", names)
+			}
+		})
+	}
+}
+
+func TestImportMessageRemovesPartialImport(t *testing.T) {
+	setupIngest(t)
+	message := "From: rechnung@stadtwerke.example\r\n" +
+		"Subject: Rechnung\r\n" +
+		"Date: Fri, 02 Jan 2026 10:00:00 +0100\r\n" +
+		"Message-Id: <4@stadtwerke.example>\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b\"\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"rechnung.pdf\"\r\n" +
+		"\r\n" +
+		"%PDF-1.4\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"anlage.pdf\"\r\n" +
+		"\r\n" +
+		"%PDF-1.4\r\n" +
+		"--b--\r\n"
+
+	// the second attachment can not be written, a directory is in the way of its partial file
+	blocked := filepath.Join(Inbound, ".2026-01-02_stadtwerke_Rechnung_2.pdf"+PARTIAL_SUFFIX)
+	if err := os.Mkdir(blocked, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := importMessage([]byte(message), map[string]time.Time{})
+	if imported.Error == "" {
+		t.Fatalf("expected the import to fail, got %+v", imported)
+	}
+	if len(imported.Files) != 0 {
+		t.Errorf("a failed import must not report files, got %v", imported.Files)
+	}
+	if names := inboundNames(t); len(names) != 1 || names[0] != filepath.Base(blocked) {
+		t.Errorf("the first attachment is left over: %v", names)
+	}
+}
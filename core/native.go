@@ -0,0 +1,28 @@
+package core
+
+import (
+	"github.com/zmnpl/ding/pdf"
+)
+
+// functions which use the built-in pdf reader; it is the fallback if poppler is not installed or fails
+
+// nativePageText extracts the text of the given page of the pdf at the given path; page 0 means all pages
+func nativePageText(path string, page int) (string, error) {
+	doc, err := pdf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	if page == 0 {
+		return doc.Text()
+	}
+	return doc.PageText(page)
+}
+
+// nativePageCount returns the number of pages of the pdf at the given path
+func nativePageCount(path string) (int, error) {
+	doc, err := pdf.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	return doc.NumPages(), nil
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/zmnpl/ding/pdf"
+)
+
+const (
+	OPTIMIZE_AUTO     = "auto"
+	OPTIMIZE_OCRMYPDF = "ocrmypdf"
+	OPTIMIZE_GS       = "gs"
+	OPTIMIZE_QPDF     = "qpdf"
+
+	QUALITY_LOW    = "low"
+	QUALITY_MEDIUM = "medium"
+	QUALITY_HIGH   = "high"
+)
+
+// OptimizeConfig configures how scans are made smaller
+type OptimizeConfig struct {
+	// auto (default), ocrmypdf, gs or qpdf; auto takes the first one which is installed in this order
+	Tool string `json:"tool,omitempty"`
+	// low, medium (default) or high; qpdf is always lossless
+	Quality string `json:"quality,omitempty"`
+	// optimize every pdf before it is filed
+	OnIngest bool `json:"on_ingest,omitempty"`
+}
+
+func (c OptimizeConfig) validate() error {
+	switch c.Tool {
+	case "", OPTIMIZE_AUTO, OPTIMIZE_OCRMYPDF, OPTIMIZE_GS, OPTIMIZE_QPDF:
+	default:
+		return fmt.Errorf("unknown tool %q", c.Tool)
+	}
+	switch c.Quality {
+	case "", QUALITY_LOW, QUALITY_MEDIUM, QUALITY_HIGH:
+	default:
+		return fmt.Errorf("unknown quality %q", c.Quality)
+	}
+	return nil
+}
+
+// optimizeTool returns the tool to use
+func (c OptimizeConfig) optimizeTool() (string, error) {
+	if c.Tool != "" && c.Tool != OPTIMIZE_AUTO {
+		if !checkDep(c.Tool) {
+			return "", fmt.Errorf("%s is not installed", c.Tool)
+		}
+		return c.Tool, nil
+	}
+	for _, tool := range []string{OPTIMIZE_OCRMYPDF, OPTIMIZE_GS, OPTIMIZE_QPDF} {
+		if checkDep(tool) {
+			return tool, nil
+		}
+	}
+	return "", fmt.Errorf("neither ocrmypdf, gs nor qpdf is installed")
+}
+
+// optimizeCommand returns the command which writes the optimized version of in to out
+func (c OptimizeConfig) optimizeCommand(tool, in, out string) *exec.Cmd {
+	quality := c.Quality
+	if quality == "" {
+		quality = QUALITY_MEDIUM
+	}
+
+	switch tool {
+	case OPTIMIZE_OCRMYPDF:
+		level := map[string]string{QUALITY_LOW: "3", QUALITY_MEDIUM: "2", QUALITY_HIGH: "1"}[quality]
+		// a tesseract timeout of 0 skips ocr, so only the optimizer runs
+		return exec.Command("ocrmypdf", "-q", "--skip-text", "--tesseract-timeout", "0", "--optimize", level, "--output-type", "pdf", in, out)
+	case OPTIMIZE_GS:
+		settings := map[string]string{QUALITY_LOW: "/screen", QUALITY_MEDIUM: "/ebook", QUALITY_HIGH: "/printer"}[quality]
+		return exec.Command("gs", "-sDEVICE=pdfwrite", "-dCompatibilityLevel=1.5", "-dPDFSETTINGS="+settings,
+			"-dNOPAUSE", "-dQUIET", "-dBATCH", "-sOutputFile="+out, in)
+	default:
+		return exec.Command("qpdf", "--recompress-flate", "--compression-level=9", "--object-streams=generate", in, out)
+	}
+}
+
+// prefix of temporary files ding creates in inbound while optimizing; the inbound lists skip them
+const TEMP_FILE_PREFIX = ".ding-"
+
+// isTempFile reports whether the inbound file is a temporary file of ding
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, TEMP_FILE_PREFIX)
+}
+
+// OptimizePdf makes the given inbound pdf smaller with the configured tool and quality
+// the original is only replaced if the result is smaller and a valid pdf with the same number of pages
+// returns the size before and after; both are the same if the original was kept
+func OptimizePdf(name string) (before, after int64, err error) {
+	path := filepath.Join(Inbound, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not read %s: %s", name, err)
+	}
+	before, after = info.Size(), info.Size()
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		return before, after, fmt.Errorf("%s is no pdf", name)
+	}
+
+	tool, err := Conf.Optimize.optimizeTool()
+	if err != nil {
+		return before, after, fmt.Errorf("could not optimize: %s", err)
+	}
+
+	// hidden temporary file next to the original, so it can be renamed over it
+	tmp, err := os.CreateTemp(Inbound, TEMP_FILE_PREFIX+"optimize-*.pdf")
+	if err != nil {
+		return before, after, fmt.Errorf("could not optimize: %s", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	output, err := Conf.Optimize.optimizeCommand(tool, path, tmp.Name()).CombinedOutput()
+	if err != nil {
+		return before, after, fmt.Errorf("%s failed: %v %s", tool, err, strings.TrimSpace(string(output)))
+	}
+
+	optimized, err := os.Stat(tmp.Name())
+	if err != nil || optimized.Size() == 0 || optimized.Size() >= before {
+		// nothing gained, keep the original
+		return before, after, nil
+	}
+	if err := samePages(path, tmp.Name()); err != nil {
+		return before, after, fmt.Errorf("result of %s is not valid, kept the original: %s", tool, err)
+	}
+
+	// temporary files are only readable by the owner; the optimized file takes over the mode of the original
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return before, after, fmt.Errorf("could not replace original: %s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return before, after, fmt.Errorf("could not replace original: %s", err)
+	}
+	queuePreviewUpdate(name)
+
+	return before, optimized.Size(), nil
+}
+
+// samePages checks that the optimized pdf can be read and has as many pages as the original
+func samePages(original, optimized string) error {
+	doc, err := pdf.Open(optimized)
+	if err != nil {
+		return err
+	}
+	pages := doc.NumPages()
+	if pages == 0 {
+		return fmt.Errorf("no pages")
+	}
+	if orig, err := pdf.Open(original); err == nil && orig.NumPages() != pages {
+		return fmt.Errorf("%v instead of %v pages", pages, orig.NumPages())
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zmnpl/ding/pdf"
+)
+
+// WriteMetadataOnIngest enables writing name, directory and keywords into the metadata of pdfs
+// when they are moved into a directory
+var WriteMetadataOnIngest = false
+
+// GetTimestampFromFileName returns the time from the timestamp prefix of a file name
+func GetTimestampFromFileName(name string) (time.Time, bool) {
+	if !TimestampPrefixMatch.MatchString(name) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102-150405.000_", name[:len("20060102-150405.000_")], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// metadataDate returns the date which is written into the pdf metadata; the date found in the document
+// or, if there is none, the time it came into the inbound directory under the given name
+func metadataDate(sidecar Sidecar, name string) time.Time {
+	if date, err := time.ParseInLocation(SIDECAR_DATE_FORMAT, sidecar.DocumentDate, time.Local); err == nil {
+		return date
+	}
+	if date, ok := GetTimestampFromFileName(name); ok {
+		return date
+	}
+	return sidecar.Ingested
+}
+
+// withPdfMetadata returns the pdf with title, subject, keywords and date set; a creation date the pdf
+// already has is kept
+// non pdf files and pdfs which can not be updated are returned as they are
+func withPdfMetadata(data []byte, newName, directoryName string, keywords []string, date time.Time) []byte {
+	if !strings.EqualFold(filepath.Ext(newName), ".pdf") {
+		return data
+	}
+
+	title := RemoveTimeStampFilePrefix(newName)
+	title = strings.TrimSuffix(title, filepath.Ext(title))
+
+	updated, err := pdf.UpdateMetadata(data, pdf.Metadata{
+		Title:    title,
+		Subject:  directoryName,
+		Keywords: keywords,
+		Date:     date,
+	})
+	if err != nil {
+		// keep the original
+		return data
+	}
+	return updated
+}
@@ -0,0 +1,428 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	STEP_CONVERT  = "convert"
+	STEP_OCR      = "ocr"
+	STEP_OPTIMIZE = "optimize"
+	STEP_TAG      = "tag"
+	STEP_MOVE     = "move"
+	STEP_HOOK     = "hook"
+
+	ON_ERROR_FAIL = "fail"
+	ON_ERROR_SKIP = "skip"
+
+	STEP_STATUS_DONE    = "done"
+	STEP_STATUS_SKIPPED = "skipped"
+	STEP_STATUS_FAILED  = "failed"
+)
+
+var (
+	// images which the convert step turns into pdfs
+	CONVERTIBLE_IMAGES = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true}
+
+	steps = map[string]func(job *IngestJob, step PipelineStep) (string, error){
+		STEP_CONVERT:  convertStep,
+		STEP_OCR:      ocrStep,
+		STEP_OPTIMIZE: optimizeStep,
+		STEP_TAG:      tagStep,
+		STEP_MOVE:     moveStep,
+		STEP_HOOK:     hookStep,
+	}
+)
+
+// PipelineStep is one step of the ingest pipeline as it is configured
+type PipelineStep struct {
+	// convert, ocr, optimize, tag, move or hook
+	Step string `json:"step"`
+	// name the results are reported under, defaults to the step
+	Name string `json:"name,omitempty"`
+	// fail (default) stops the pipeline when the step fails, skip carries on with the next step
+	OnError string `json:"on_error,omitempty"`
+	// tags the tag step adds to the document
+	Tags []string `json:"tags,omitempty"`
+	// command the hook step runs; the document is described by the environment variables
+	// DING_SOURCE, DING_TARGET, DING_DIR and DING_NAME
+	Command []string `json:"command,omitempty"`
+	// let the ocr step redo documents which already have text
+	Force bool `json:"force,omitempty"`
+}
+
+func (s PipelineStep) validate() error {
+	if _, ok := steps[s.Step]; !ok {
+		return fmt.Errorf("unknown step %q", s.Step)
+	}
+	switch s.OnError {
+	case "", ON_ERROR_FAIL:
+	case ON_ERROR_SKIP:
+		if s.Step == STEP_MOVE {
+			return fmt.Errorf("the move step can not be skipped")
+		}
+	default:
+		return fmt.Errorf("unknown on_error %q", s.OnError)
+	}
+	if s.Step == STEP_TAG && len(s.Tags) == 0 {
+		return fmt.Errorf("the tag step needs tags")
+	}
+	if s.Step == STEP_HOOK && len(s.Command) == 0 {
+		return fmt.Errorf("the hook step needs a command")
+	}
+	return nil
+}
+
+// validatePipeline checks that the steps make sense in their order
+func validatePipeline(pipeline []PipelineStep) error {
+	moved := false
+	for i, step := range pipeline {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %v: %s", i+1, err)
+		}
+		switch step.Step {
+		case STEP_MOVE:
+			if moved {
+				return fmt.Errorf("step %v: the document can only be moved once", i+1)
+			}
+			moved = true
+		case STEP_HOOK:
+		default:
+			if moved {
+				return fmt.Errorf("step %v: %s has to come before the move step", i+1, step.Step)
+			}
+		}
+	}
+	if !moved {
+		return fmt.Errorf("there is no move step")
+	}
+	return nil
+}
+
+// Pipeline returns the configured ingest pipeline including the pre and post ingest hooks
+// without configuration documents are optimized if that is configured and then moved
+func Pipeline() []PipelineStep {
+	if len(Conf.Pipeline) > 0 {
+		return withHooks(Conf.Pipeline)
+	}
+	pipeline := make([]PipelineStep, 0, 2)
+	if Conf.Optimize.OnIngest {
+		// failing to optimize is no reason not to file
+		pipeline = append(pipeline, PipelineStep{Step: STEP_OPTIMIZE, OnError: ON_ERROR_SKIP})
+	}
+	return withHooks(append(pipeline, PipelineStep{Step: STEP_MOVE}))
+}
+
+// IngestJob is an inbound document on its way through the pipeline
+type IngestJob struct {
+	// name of the file in the inbound directory when the job was started
+	Original string
+	// current name in the inbound directory; converting changes it
+	Name string
+	// name and directory in the destination
+	NewName   string
+	Directory string
+	Tags      []string
+	// file the document even if it is archived already
+	Force bool
+	// path relative to the destination once the document is moved
+	Target string
+
+	hash      string
+	commitErr error
+	// inbound files the document was converted from; they are kept until it is filed
+	convertedFrom []string
+}
+
+// NewIngestJob returns a job to file the inbound file name as newName into directory
+func NewIngestJob(name, newName, directory string, tags []string, force bool) *IngestJob {
+	return &IngestJob{
+		Original:  name,
+		Name:      name,
+		NewName:   newName,
+		Directory: directory,
+		Tags:      tags,
+		Force:     force,
+	}
+}
+
+// StepResult tells what a step did with the document
+type StepResult struct {
+	Step    string `json:"step"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func (r StepResult) String() string {
+	if r.Message == "" {
+		return fmt.Sprintf("%s %s", r.Step, r.Status)
+	}
+	return fmt.Sprintf("%s %s: %s", r.Step, r.Status, r.Message)
+}
+
+// StepSummary describes in one line what the steps did besides moving the document
+func StepSummary(results []StepResult) string {
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.Step == STEP_MOVE && r.Status == STEP_STATUS_DONE {
+			continue
+		}
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// skipped is returned by steps which have nothing to do for the document
+type skipped string
+
+func (s skipped) Error() string {
+	return string(s)
+}
+
+// Ingest runs the job through the configured pipeline and reports the result of every step that ran.
+// If an identical document already exists anywhere in the destination and the job is not forced,
+// no step runs and a *DuplicateError is returned.
+func Ingest(job *IngestJob) ([]StepResult, error) {
+	return RunPipeline(Pipeline(), job)
+}
+
+// RunPipeline runs the job through the given steps
+func RunPipeline(pipeline []PipelineStep, job *IngestJob) ([]StepResult, error) {
+	results := make([]StepResult, 0, len(pipeline))
+	if err := job.prepare(); err != nil {
+		return results, err
+	}
+
+	for _, step := range pipeline {
+		run, ok := steps[step.Step]
+		if !ok {
+			return results, fmt.Errorf("unknown step %q", step.Step)
+		}
+
+		name := step.Name
+		if name == "" {
+			name = step.Step
+		}
+
+		message, err := run(job, step)
+		if reason, ok := err.(skipped); ok {
+			results = append(results, StepResult{Step: name, Status: STEP_STATUS_SKIPPED, Message: string(reason)})
+			continue
+		}
+		if err != nil {
+			results = append(results, StepResult{Step: name, Status: STEP_STATUS_FAILED, Message: err.Error()})
+			if step.OnError == ON_ERROR_SKIP {
+				continue
+			}
+			job.revertConversions()
+			return results, err
+		}
+		results = append(results, StepResult{Step: name, Status: STEP_STATUS_DONE, Message: message})
+	}
+
+	return results, nil
+}
+
+// convertedTo switches the job to the converted inbound file; the file it was made from stays in inbound
+// until the document is filed, so a failing pipeline can go back to it
+func (job *IngestJob) convertedTo(name string) {
+	job.convertedFrom = append(job.convertedFrom, job.Name)
+	job.Name = name
+}
+
+// revertConversions removes the converted files of a document which was not filed and goes back to the original
+func (job *IngestJob) revertConversions() {
+	if job.Target != "" || len(job.convertedFrom) == 0 {
+		return
+	}
+	for _, name := range append(job.convertedFrom[1:], job.Name) {
+		DeleteInboundFile(name)
+	}
+	job.Name = job.convertedFrom[0]
+	job.convertedFrom = nil
+}
+
+// prepare makes sure the job can be done before anything touches the document
+func (job *IngestJob) prepare() error {
+	// test if both source and destination can be written
+	// destination
+	destTest := filepath.Join(Dest, job.Directory, "docin.test")
+	err := ioutil.WriteFile(destTest, []byte("write test"), 0755)
+	if err != nil {
+		return fmt.Errorf("test to write destination failed: %s", err)
+	}
+	os.Remove(destTest)
+	// inbound
+	sourceTest := filepath.Join(Inbound, "docin.test")
+	err = ioutil.WriteFile(sourceTest, []byte("write test"), 0755)
+	if err != nil {
+		return fmt.Errorf("test to write inbound failed: %s", err)
+	}
+	os.Remove(sourceTest)
+
+	if IsEncryptedDirectory(job.Directory) {
+		if _, err := encryptionKey(); err != nil {
+			return fmt.Errorf("%s is encrypted: %s", job.Directory, err)
+		}
+	}
+
+	// the hash of the document as it was scanned is kept, the steps may change the file
+	job.hash, err = inboundFileHash(job.Name)
+	if err != nil {
+		return fmt.Errorf("could not hash original file; did not move it: %s", err)
+	}
+	if !job.Force {
+		copies, err := FindArchivedCopies(job.Name)
+		if err != nil {
+			return fmt.Errorf("could not check for duplicates; did not move it: %s", err)
+		}
+		if len(copies) > 0 {
+			return &DuplicateError{Name: job.Name, Copies: copies}
+		}
+	}
+
+	return nil
+}
+
+func (job *IngestJob) isPdf() bool {
+	return strings.EqualFold(filepath.Ext(job.Name), ".pdf")
+}
+
+// convertStep turns images into pdfs
+func convertStep(job *IngestJob, step PipelineStep) (string, error) {
+	if !IsImage(job.Name) {
+		return "", skipped("no image")
+	}
+	if missing := missingTools(backendTools(ImageConv)); len(missing) > 0 {
+		return "", fmt.Errorf("%s is not installed", strings.Join(missing, ", "))
+	}
+
+	name := strings.TrimSuffix(job.Name, filepath.Ext(job.Name)) + ".pdf"
+	target := filepath.Join(Inbound, name)
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("%s already exists", name)
+	}
+	if err := ImageConv.ToPdf(target, filepath.Join(Inbound, job.Name)); err != nil {
+		os.Remove(target)
+		return "", err
+	}
+
+	message := fmt.Sprintf("%s → %s", job.Name, name)
+	job.convertedTo(name)
+	return message, nil
+}
+
+// ocrStep adds a text layer to scans; images become searchable pdfs
+func ocrStep(job *IngestJob, step PipelineStep) (string, error) {
+	if IsImage(job.Name) {
+		name, err := imageToSearchablePdf(job.Name)
+		if err != nil {
+			return "", err
+		}
+		message := fmt.Sprintf("%s → %s", job.Name, name)
+		job.convertedTo(name)
+		return message, nil
+	}
+	if !job.isPdf() {
+		return "", skipped("no pdf")
+	}
+	if !step.Force {
+		if text, err := GetDocText(filepath.Join(Inbound, job.Name)); err == nil && text != "" {
+			return "", skipped("has text already")
+		}
+	}
+	if err := OcrPdf(job.Name); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// optimizeStep makes the document smaller
+func optimizeStep(job *IngestJob, step PipelineStep) (string, error) {
+	if !job.isPdf() {
+		return "", skipped("no pdf")
+	}
+	before, after, err := OptimizePdf(job.Name)
+	if err != nil {
+		return "", err
+	}
+	if after == before {
+		return "", skipped("could not make it smaller")
+	}
+	return fmt.Sprintf("%s → %s", FormatSize(before), FormatSize(after)), nil
+}
+
+// tagStep adds the configured tags
+func tagStep(job *IngestJob, step PipelineStep) (string, error) {
+	added := make([]string, 0, len(step.Tags))
+	for _, tag := range ParseTags(strings.Join(step.Tags, " ")) {
+		if !HasTag(job.Tags, tag) {
+			job.Tags = append(job.Tags, tag)
+			added = append(added, tag)
+		}
+	}
+	if len(added) == 0 {
+		return "", skipped("already tagged")
+	}
+	return "#" + strings.Join(added, " #"), nil
+}
+
+// moveStep files the document
+func moveStep(job *IngestJob, step PipelineStep) (string, error) {
+	if err := fileDocument(job); err != nil {
+		return "", err
+	}
+	// the document is filed, the files it was converted from are not needed anymore
+	for _, name := range job.convertedFrom {
+		if err := DeleteInboundFile(name); err != nil {
+			return fmt.Sprintf("%s (%s)", job.Target, err), nil
+		}
+	}
+	job.convertedFrom = nil
+	if job.commitErr != nil {
+		return fmt.Sprintf("%s (not committed: %s)", job.Target, job.commitErr), nil
+	}
+	return job.Target, nil
+}
+
+// hookStep runs a command of the user; the whole output goes to the hook log, the first line into the result
+func hookStep(job *IngestJob, step PipelineStep) (string, error) {
+	name := step.Name
+	if name == "" {
+		name = STEP_HOOK
+	}
+
+	cmd := exec.Command(step.Command[0], step.Command[1:]...)
+	cmd.Env = append(os.Environ(), job.environment()...)
+	output, err := cmd.CombinedOutput()
+	message := strings.TrimSpace(string(output))
+	logHook(name, step.Command, job, message, err)
+	if err != nil {
+		if message != "" {
+			return "", fmt.Errorf("%s: %v: %s", name, err, firstLine(message))
+		}
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return firstLine(message), nil
+}
+
+// environment describes the document for commands of the user
+// before the move DING_TARGET is where the document is going to be filed
+func (job *IngestJob) environment() []string {
+	name := checkFixExtension(job.Name, job.NewName)
+	target := filepath.Join(Dest, job.Directory, name)
+	if job.Target != "" {
+		target = filepath.Join(Dest, job.Target)
+	}
+	return []string{
+		"DING_SOURCE=" + filepath.Join(Inbound, job.Name),
+		"DING_TARGET=" + target,
+		"DING_DIR=" + job.Directory,
+		"DING_NAME=" + name,
+	}
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the fake ocr engine appends the recognised text behind this marker, the fake extractor reads it from there
+const fakeTextMarker = "\n%ding-test-text "
+
+type fakeExtractor struct{}
+
+func (fakeExtractor) PageText(path string, page int, layout bool) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	i := bytes.Index(data, []byte(fakeTextMarker))
+	if i < 0 {
+		return "", nil
+	}
+	return string(data[i+len(fakeTextMarker):]), nil
+}
+
+func (fakeExtractor) PageCount(path string) (int, error) {
+	return 1, nil
+}
+
+type fakeOCR struct {
+	text string
+	runs *int
+}
+
+func (o fakeOCR) Ocr(path, language string) error {
+	*o.runs++
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, fakeTextMarker+o.text...), 0644)
+}
+
+// setupIngest points inbound and destination into temporary directories and swaps in fake backends
+// which need no tools installed; everything is restored when the test is done
+func setupIngest(t *testing.T, directories ...string) {
+	t.Helper()
+	inbound, dest, conf := Inbound, Dest, Conf
+	extractor, ocr := Extractor, OCR
+	t.Cleanup(func() {
+		Inbound, Dest, Conf = inbound, dest, conf
+		Extractor, OCR = extractor, ocr
+	})
+	// cleanups run last to first, so previews queued by the test are done before the backends are restored
+	t.Cleanup(waitForPreviews)
+
+	Inbound = t.TempDir()
+	Dest = t.TempDir()
+	for _, d := range directories {
+		if err := os.Mkdir(filepath.Join(Dest, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Conf = Config{Backends: BackendConfig{Text: TEXT_NATIVE, OCR: OCR_TESSERACT}}
+	if err := Conf.Backends.validate(); err != nil {
+		t.Fatal(err)
+	}
+	Conf.Backends.apply()
+	Extractor = fakeExtractor{}
+}
+
+// waitForPreviews waits until the previews which are queued in the background are extracted
+func waitForPreviews() {
+	for {
+		previewsMu.Lock()
+		pending := len(previewPending)
+		previewsMu.Unlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func writeInboundFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(Inbound, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIngest(t *testing.T) {
+	setupIngest(t, "Invoices")
+	runs := 0
+	OCR = fakeOCR{text: "Stadtwerke\nRechnung vom 02.01.2026", runs: &runs}
+
+	scan := []byte("%PDF-1.4\n% scanned invoice without text\n")
+	writeInboundFile(t, "scan.pdf", scan)
+	hash := fmt.Sprintf("%x", sha256.Sum256(scan))
+
+	pipeline := []PipelineStep{{Step: STEP_OCR}, {Step: STEP_TAG, Tags: []string{"tax-2026"}}, {Step: STEP_MOVE}}
+	job := NewIngestJob("scan.pdf", "invoice.pdf", "Invoices", []string{"power"}, false)
+	results, err := RunPipeline(pipeline, job)
+	if err != nil {
+		t.Fatalf("ingest failed: %s (%v)", err, results)
+	}
+	if len(results) != 3 || results[0].Status != STEP_STATUS_DONE || results[2].Status != STEP_STATUS_DONE {
+		t.Errorf("unexpected results %v", results)
+	}
+	if runs != 1 {
+		t.Errorf("expected ocr to run once, ran %v times", runs)
+	}
+
+	// the document
+	if job.Target != filepath.Join("Invoices", "invoice.pdf") {
+		t.Errorf("unexpected target %s", job.Target)
+	}
+	filed, err := ioutil.ReadFile(filepath.Join(Dest, job.Target))
+	if err != nil {
+		t.Fatalf("document not filed: %s", err)
+	}
+	if !bytes.HasPrefix(filed, scan) || !bytes.Contains(filed, []byte("Rechnung vom")) {
+		t.Errorf("filed document is not the scan with text layer: %q", filed)
+	}
+	if _, err := os.Stat(filepath.Join(Inbound, "scan.pdf")); !os.IsNotExist(err) {
+		t.Errorf("inbound file has not been removed")
+	}
+
+	// the sidecar
+	sidecar, err := ReadSidecar(job.Target)
+	if err != nil {
+		t.Fatalf("no sidecar: %s", err)
+	}
+	if sidecar.OriginalName != "scan.pdf" || sidecar.Hash != hash || sidecar.OCR != OCR_STATUS_DONE {
+		t.Errorf("unexpected sidecar %+v", sidecar)
+	}
+	if sidecar.DocumentDate != "2026-01-02" {
+		t.Errorf("document date not detected, got %q", sidecar.DocumentDate)
+	}
+	if strings.Join(sidecar.Tags, ",") != "power,tax-2026" {
+		t.Errorf("unexpected tags %v", sidecar.Tags)
+	}
+
+	// the hash index knows the document by the hash of the scan, although ocr changed it
+	if copies := documentsWithHash(hash); len(copies) != 1 || copies[0] != job.Target {
+		t.Errorf("expected %s in the hash index, got %v", job.Target, copies)
+	}
+	writeInboundFile(t, "scan-again.pdf", scan)
+	_, err = RunPipeline(pipeline, NewIngestJob("scan-again.pdf", "invoice-again.pdf", "Invoices", nil, false))
+	if dupErr, ok := err.(*DuplicateError); !ok || len(dupErr.Copies) != 1 || dupErr.Copies[0] != job.Target {
+		t.Errorf("expected the second scan to be a duplicate of %s, got %v", job.Target, err)
+	}
+	if runs != 1 {
+		t.Errorf("no step may run for a duplicate")
+	}
+}
+
+func TestIngestSkipsOcrWithText(t *testing.T) {
+	setupIngest(t, "Letters")
+	runs := 0
+	OCR = fakeOCR{runs: &runs}
+
+	writeInboundFile(t, "letter.pdf", []byte("%PDF-1.4"+fakeTextMarker+"Dear customer"))
+	results, err := RunPipeline([]PipelineStep{{Step: STEP_OCR}, {Step: STEP_MOVE}}, NewIngestJob("letter.pdf", "letter.pdf", "Letters", nil, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 0 || results[0].Status != STEP_STATUS_SKIPPED {
+		t.Errorf("ocr has to be skipped for documents with text, got %v", results)
+	}
+	sidecar, err := ReadSidecar(filepath.Join("Letters", "letter.pdf"))
+	if err != nil || sidecar.OCR != OCR_STATUS_TEXT {
+		t.Errorf("unexpected sidecar %+v (%v)", sidecar, err)
+	}
+}
+
+func TestIngestKeepsImageUntilFiled(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline []PipelineStep
+		fail     bool
+	}{
+		{name: "filed", pipeline: []PipelineStep{{Step: STEP_CONVERT}, {Step: STEP_MOVE}}},
+		{name: "failing hook", pipeline: []PipelineStep{{Step: STEP_CONVERT}, {Step: STEP_HOOK, Command: []string{"false"}}, {Step: STEP_MOVE}}, fail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIngest(t, "Receipts")
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			converter := ImageConv
+			t.Cleanup(func() { ImageConv = converter })
+			ImageConv = fakeConverter{}
+			writeInboundFile(t, "receipt.png", []byte("receipt"))
+
+			job := NewIngestJob("receipt.png", "receipt.pdf", "Receipts", nil, false)
+			results, err := RunPipeline(tt.pipeline, job)
+			names := inboundNames(t)
+			if !tt.fail {
+				if err != nil {
+					t.Fatalf("ingest failed: %s (%v)", err, results)
+				}
+				if len(names) != 0 {
+					t.Errorf("expected inbound to be empty, got %v", names)
+				}
+				if _, err := os.Stat(filepath.Join(Dest, job.Target)); err != nil {
+					t.Errorf("document not filed: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected the pipeline to fail, got %v", results)
+			}
+			if len(names) != 1 || names[0] != "receipt.png" || job.Name != "receipt.png" {
+				t.Errorf("expected only the image to be left in inbound, got %v (job at %s)", names, job.Name)
+			}
+		})
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	target := filepath.Join(dir, "target")
+	if err := ioutil.WriteFile(source, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(target, []byte("old content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceFile(source, target); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(target); string(data) != "new" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0600 {
+		t.Errorf("mode has to be kept, got %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("temporary files are left over: %v", entries)
+	}
+
+	if err := replaceFile(source, filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("a missing target has to fail")
+	}
+}
+
+func TestJobEnvironment(t *testing.T) {
+	setupIngest(t, "Invoices")
+	job := NewIngestJob("scan.pdf", "invoice", "Invoices", nil, false)
+
+	want := "DING_TARGET=" + filepath.Join(Dest, "Invoices", "invoice.pdf")
+	if env := job.environment(); env[1] != want {
+		t.Errorf("before the move expected %s, got %s", want, env[1])
+	}
+	job.Target = filepath.Join("Invoices", "invoice-2.pdf")
+	want = "DING_TARGET=" + filepath.Join(Dest, job.Target)
+	if env := job.environment(); env[1] != want {
+		t.Errorf("after the move expected %s, got %s", want, env[1])
+	}
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmnpl/ding/pdf"
+)
+
+const (
+	PROTECTED_PREVIEW = "- pdf is password protected -"
+	// suffix of the unprotected copy of a password protected inbound pdf
+	UNPROTECTED_SUFFIX = "_unprotected"
+)
+
+// ErrWrongPassword is returned when the password does not open the pdf
+var ErrWrongPassword = errors.New("wrong password")
+
+var (
+	// password protection of inbound pdfs; checking it runs qpdf, so it is done once per version of a file
+	protectedCache map[string]protectedStatus
+	protectedMu    sync.Mutex
+)
+
+func init() {
+	protectedCache = make(map[string]protectedStatus)
+}
+
+type protectedStatus struct {
+	modTime   time.Time
+	protected bool
+}
+
+// KeyringEntry is a password of a sender who protects their pdfs
+type KeyringEntry struct {
+	Sender string `json:"sender"`
+	// glob pattern for inbound file names the password is tried on first, e.g. "Kontoauszug*"
+	Match    string `json:"match,omitempty"`
+	Password string `json:"password"`
+}
+
+// KeyringPath returns where the passwords for protected pdfs are kept, next to the config file
+func KeyringPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath()), "keyring.json")
+}
+
+// ReadKeyring returns the entries of the keyring; a missing keyring is empty
+// the keyring holds passwords in plain text, so it is refused if others can read it
+func ReadKeyring() ([]KeyringEntry, error) {
+	path := KeyringPath()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return []KeyringEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read keyring: %s", err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("keyring %s can be read by others, chmod 600 it", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keyring: %s", err)
+	}
+	var entries []KeyringEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse keyring %s: %s", path, err)
+	}
+	return entries, nil
+}
+
+// AddToKeyring stores the password of the sender; an existing password of the sender is replaced
+func AddToKeyring(entry KeyringEntry) error {
+	entries, err := ReadKeyring()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i, e := range entries {
+		if e.Sender == entry.Sender {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	data, _ := json.MarshalIndent(entries, "", "  ")
+	path := KeyringPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create keyring: %s", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("could not write keyring: %s", err)
+	}
+	return nil
+}
+
+// IsPasswordProtected reports whether the inbound pdf can only be read with a password
+// pdfs which are only restricted by an owner password can be read and are not reported
+// the result is cached until the file changes
+func IsPasswordProtected(name string) bool {
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		return false
+	}
+	path := filepath.Join(Inbound, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	protectedMu.Lock()
+	status, ok := protectedCache[name]
+	protectedMu.Unlock()
+	if ok && status.modTime.Equal(info.ModTime()) {
+		return status.protected
+	}
+
+	protected := requiresPassword(path)
+	protectedMu.Lock()
+	protectedCache[name] = protectedStatus{modTime: info.ModTime(), protected: protected}
+	protectedMu.Unlock()
+	return protected
+}
+
+// GetCachedPasswordProtected returns whether the inbound pdf is known to be password protected
+// it never runs qpdf, the preview warm-up finds out about the protection in the background
+func GetCachedPasswordProtected(name string) bool {
+	protectedMu.Lock()
+	defer protectedMu.Unlock()
+	return protectedCache[name].protected
+}
+
+func requiresPassword(path string) bool {
+	if checkDep("qpdf") {
+		// exit code 0: a password is required, 2: not encrypted, 3: encrypted without user password
+		err := exec.Command("qpdf", "--requires-password", path).Run()
+		if err == nil {
+			return true
+		}
+		if exitError, ok := err.(*exec.ExitError); ok && (exitError.ExitCode() == 2 || exitError.ExitCode() == 3) {
+			return false
+		}
+	}
+
+	// the built-in reader can not decrypt at all, so every encrypted pdf counts as protected
+	doc, err := pdf.Open(path)
+	if err != nil {
+		return false
+	}
+	return doc.Encrypted()
+}
+
+// RemovePdfPassword writes an unprotected copy of the inbound pdf next to it and returns the name of the copy
+// the original is kept, so nothing is lost if the copy turns out to be wrong
+func RemovePdfPassword(name, password string) (string, error) {
+	if !checkDep("qpdf") {
+		return "", fmt.Errorf("qpdf is needed to remove the password")
+	}
+
+	ext := filepath.Ext(name)
+	newName := strings.TrimSuffix(name, ext) + UNPROTECTED_SUFFIX + ext
+	target := filepath.Join(Inbound, newName)
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("%s exists already", newName)
+	}
+
+	// the password goes through stdin, so it does not show up in the process list
+	cmd := exec.Command("qpdf", "--password-file=-", "--decrypt", filepath.Join(Inbound, name), target)
+	cmd.Stdin = strings.NewReader(password + "\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// exit code 3 means warnings, the copy is written anyway
+		if exitError, ok := err.(*exec.ExitError); !ok || exitError.ExitCode() != 3 {
+			os.Remove(target)
+			if strings.Contains(string(output), "invalid password") {
+				return "", ErrWrongPassword
+			}
+			return "", fmt.Errorf("could not remove password: %v %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	queuePreviewUpdate(newName)
+	return newName, nil
+}
+
+// RemovePdfPasswordFromKeyring tries the passwords of the keyring on the inbound pdf,
+// those of senders whose pattern matches the name first; it returns the name of the copy and the sender
+func RemovePdfPasswordFromKeyring(name string) (string, string, error) {
+	entries, err := ReadKeyring()
+	if err != nil {
+		return "", "", err
+	}
+
+	matching := make([]KeyringEntry, 0, len(entries))
+	others := make([]KeyringEntry, 0, len(entries))
+	for _, e := range entries {
+		if ok, _ := filepath.Match(e.Match, name); ok && e.Match != "" {
+			matching = append(matching, e)
+			continue
+		}
+		others = append(others, e)
+	}
+
+	for _, e := range append(matching, others...) {
+		newName, err := RemovePdfPassword(name, e.Password)
+		if err == nil {
+			return newName, e.Sender, nil
+		}
+		if err != ErrWrongPassword {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("no password in the keyring fits %s", name)
+}
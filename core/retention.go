@@ -0,0 +1,164 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// the retention period starts at the ingest date, taken from the timestamp prefix of the file name
+	RETENTION_FROM_INGEST = "ingest"
+	// the retention period starts at the date of the document, as detected when it was filed
+	RETENTION_FROM_DOCUMENT = "document"
+)
+
+// RetentionRule says how long documents in a directory or with a tag have to be kept
+type RetentionRule struct {
+	Directory string `json:"directory,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Years     int    `json:"years,omitempty"`
+	Months    int    `json:"months,omitempty"`
+	// ingest (default) or document
+	From string `json:"from,omitempty"`
+	// the period starts at the end of the year, like for german tax documents
+	EndOfYear bool `json:"end_of_year,omitempty"`
+}
+
+// ExpiredDocument is a document which is past its retention period
+type ExpiredDocument struct {
+	Path    string        `json:"path"`
+	Rule    RetentionRule `json:"rule"`
+	Start   time.Time     `json:"start"`
+	Expired time.Time     `json:"expired"`
+}
+
+func (r RetentionRule) validate() error {
+	if r.Directory == "" && r.Tag == "" {
+		return fmt.Errorf("needs a directory or a tag")
+	}
+	if r.Years < 0 || r.Months < 0 || r.Years+r.Months == 0 {
+		return fmt.Errorf("needs a period in years or months")
+	}
+	if r.From != "" && r.From != RETENTION_FROM_INGEST && r.From != RETENTION_FROM_DOCUMENT {
+		return fmt.Errorf("from has to be %q or %q", RETENTION_FROM_INGEST, RETENTION_FROM_DOCUMENT)
+	}
+	return nil
+}
+
+// String describes the rule, e.g. "directory Steuern: 10 years"
+func (r RetentionRule) String() string {
+	scope := "directory " + r.Directory
+	if r.Tag != "" {
+		scope = "tag #" + r.Tag
+		if r.Directory != "" {
+			scope = fmt.Sprintf("directory %s with tag #%s", r.Directory, r.Tag)
+		}
+	}
+	period := make([]string, 0, 2)
+	if r.Years > 0 {
+		period = append(period, plural(r.Years, "year"))
+	}
+	if r.Months > 0 {
+		period = append(period, plural(r.Months, "month"))
+	}
+	return fmt.Sprintf("%s: %s", scope, strings.Join(period, " "))
+}
+
+// Matches reports whether the rule applies to the document at the given path relative to the destination
+func (r RetentionRule) Matches(rel string, tags []string) bool {
+	if r.Directory != "" {
+		dir := filepath.ToSlash(filepath.Clean(r.Directory))
+		if !strings.HasPrefix(filepath.ToSlash(rel), dir+"/") {
+			return false
+		}
+	}
+	if r.Tag != "" && !HasTag(tags, r.Tag) {
+		return false
+	}
+	return true
+}
+
+// Expiry returns when a document which is dated at the given time may be disposed of
+func (r RetentionRule) Expiry(start time.Time) time.Time {
+	if r.EndOfYear {
+		start = time.Date(start.Year()+1, time.January, 1, 0, 0, 0, 0, start.Location())
+	}
+	return start.AddDate(r.Years, r.Months, 0)
+}
+
+// documentDates returns the ingest date and the document date of a filed document
+// the ingest date comes from the timestamp prefix or the sidecar, the document date from the sidecar
+// without a known document date, the ingest date is used for both
+func documentDates(rel string) (ingested, dated time.Time, ok bool) {
+	sidecar, err := ReadSidecar(rel)
+	if t, found := GetTimestampFromFileName(filepath.Base(rel)); found {
+		ingested = t
+	} else if err == nil && !sidecar.Ingested.IsZero() {
+		ingested = sidecar.Ingested
+	} else {
+		return ingested, dated, false
+	}
+
+	dated = ingested
+	if err == nil && sidecar.DocumentDate != "" {
+		if t, err := time.ParseInLocation(SIDECAR_DATE_FORMAT, sidecar.DocumentDate, time.Local); err == nil {
+			dated = t
+		}
+	}
+	return ingested, dated, true
+}
+
+// FindExpiredDocuments returns all documents which are past their retention period at the given time
+// if several rules apply to a document, the one which keeps it longest counts
+// documents without any rule or without a date are kept forever
+func FindExpiredDocuments(now time.Time) ([]ExpiredDocument, error) {
+	if len(Conf.Retention) == 0 {
+		return nil, fmt.Errorf("no retention rules in %s", ConfigPath())
+	}
+
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]ExpiredDocument, 0)
+	for _, rel := range documents {
+		tags := GetTags(rel)
+		ingested, dated, ok := documentDates(rel)
+		if !ok {
+			continue
+		}
+
+		var latest *ExpiredDocument
+		for _, rule := range Conf.Retention {
+			if !rule.Matches(rel, tags) {
+				continue
+			}
+			start := ingested
+			if rule.From == RETENTION_FROM_DOCUMENT {
+				start = dated
+			}
+			expiry := rule.Expiry(start)
+			if latest == nil || expiry.After(latest.Expired) {
+				latest = &ExpiredDocument{Path: rel, Rule: rule, Start: start, Expired: expiry}
+			}
+		}
+		if latest != nil && !latest.Expired.After(now) {
+			expired = append(expired, *latest)
+		}
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].Expired.Before(expired[j].Expired) })
+	return expired, nil
+}
+
+// plural returns e.g. "1 year" or "10 years"
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%v %s", n, unit)
+	}
+	return fmt.Sprintf("%v %ss", n, unit)
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DEFAULT_SCAN_PROFILE    = "default"
+	DEFAULT_SCAN_RESOLUTION = 300
+	DEFAULT_SCAN_MODE       = "Gray"
+
+	// suffix of files in inbound which are still being written
+	PARTIAL_SUFFIX = ".part"
+)
+
+// ScanProfile holds the settings of the scanner for one kind of documents
+type ScanProfile struct {
+	// sane device name as listed by scanimage -L; the first scanner found if empty
+	Device string `json:"device,omitempty"`
+	// dpi, 300 by default
+	Resolution int `json:"resolution,omitempty"`
+	// Color, Gray (default) or Lineart; the names depend on the backend of the scanner
+	Mode string `json:"mode,omitempty"`
+	// e.g. Flatbed, ADF or ADF Duplex; the names depend on the backend of the scanner
+	Source string `json:"source,omitempty"`
+	// scan until the document feeder is empty instead of a single page
+	Batch bool `json:"batch,omitempty"`
+	// further options for scanimage, e.g. --swdeskew=yes
+	Options []string `json:"options,omitempty"`
+	// run ocr on the scan right away
+	OCR bool `json:"ocr,omitempty"`
+}
+
+// ScanConfig holds the scan profiles
+type ScanConfig struct {
+	// profile used if none is given
+	Default  string                 `json:"default,omitempty"`
+	Profiles map[string]ScanProfile `json:"profiles,omitempty"`
+}
+
+// ScanResult is what a scan put into the inbound directory
+type ScanResult struct {
+	Name  string `json:"name"`
+	Pages int    `json:"pages"`
+	// error of the ocr; the scan itself is kept
+	OCRError string `json:"ocr_error,omitempty"`
+}
+
+func (c ScanConfig) validate() error {
+	if _, ok := c.Profiles[c.Default]; c.Default != "" && !ok {
+		return fmt.Errorf("default profile %q does not exist", c.Default)
+	}
+	for name, p := range c.Profiles {
+		if p.Resolution < 0 {
+			return fmt.Errorf("profile %q: resolution must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ScanProfileNames returns the names of the configured profiles, sorted
+func ScanProfileNames() []string {
+	names := make([]string, 0, len(Conf.Scan.Profiles))
+	for name := range Conf.Scan.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetScanProfile returns the profile with the given name; an empty name means the default profile,
+// which is a single gray page at 300 dpi unless configured otherwise
+func GetScanProfile(name string) (ScanProfile, error) {
+	if name == "" {
+		name = Conf.Scan.Default
+	}
+	if name == "" || name == DEFAULT_SCAN_PROFILE {
+		if p, ok := Conf.Scan.Profiles[DEFAULT_SCAN_PROFILE]; ok {
+			return p, nil
+		}
+		return ScanProfile{}, nil
+	}
+	p, ok := Conf.Scan.Profiles[name]
+	if !ok {
+		return p, fmt.Errorf("there is no scan profile %q", name)
+	}
+	return p, nil
+}
+
+// args returns the arguments for scanimage
+func (p ScanProfile) args() []string {
+	resolution, mode := p.Resolution, p.Mode
+	if resolution == 0 {
+		resolution = DEFAULT_SCAN_RESOLUTION
+	}
+	if mode == "" {
+		mode = DEFAULT_SCAN_MODE
+	}
+
+	args := []string{"--format=png", fmt.Sprintf("--resolution=%v", resolution), "--mode=" + mode}
+	if p.Device != "" {
+		args = append(args, "--device-name="+p.Device)
+	}
+	if p.Source != "" {
+		args = append(args, "--source="+p.Source)
+	}
+	return append(args, p.Options...)
+}
+
+// Scan scans with the given profile and puts the pages as a pdf into the inbound directory
+// several pages are scanned with scanimage --batch, which feeds the adf like scanadf does
+func Scan(profile ScanProfile) (ScanResult, error) {
+	var result ScanResult
+	if !checkDep("scanimage") {
+		return result, fmt.Errorf("scanimage is not installed, it comes with sane")
+	}
+	if missing := missingTools(backendTools(ImageConv)); len(missing) > 0 {
+		return result, fmt.Errorf("%s is needed to turn the scan into a pdf", strings.Join(missing, ", "))
+	}
+
+	tmp, err := os.MkdirTemp("", "ding-scan-")
+	if err != nil {
+		return result, fmt.Errorf("could not create directory for scan: %s", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	pages, err := scanPages(profile, tmp)
+	if err != nil {
+		return result, err
+	}
+
+	scanned := filepath.Join(tmp, "scan.pdf")
+	if err := ImageConv.ToPdf(scanned, pages...); err != nil {
+		return result, fmt.Errorf("could not turn scan into pdf: %s", err)
+	}
+
+	result.Name = GetTimestampFilePrefix() + "scan.pdf"
+	result.Pages = len(pages)
+	if err := copyIntoInbound(scanned, result.Name); err != nil {
+		return result, err
+	}
+	queuePreviewUpdate(result.Name)
+
+	if profile.OCR {
+		if err := OcrPdf(result.Name); err != nil {
+			result.OCRError = err.Error()
+		}
+	}
+	return result, nil
+}
+
+// scanPages runs scanimage and returns the paths of the scanned pages in order
+func scanPages(profile ScanProfile, dir string) ([]string, error) {
+	args := profile.args()
+	if profile.Batch {
+		args = append(args, "--batch="+filepath.Join(dir, "page%04d.png"))
+		output, err := exec.Command("scanimage", args...).CombinedOutput()
+		pages, _ := filepath.Glob(filepath.Join(dir, "page*.png"))
+		// scanimage reports an error when the feeder runs empty, which is fine once pages are scanned
+		if len(pages) == 0 {
+			return nil, fmt.Errorf("no pages scanned: %v %s", err, strings.TrimSpace(string(output)))
+		}
+		sort.Strings(pages)
+		return pages, nil
+	}
+
+	page := filepath.Join(dir, "page0001.png")
+	f, err := os.Create(page)
+	if err != nil {
+		return nil, fmt.Errorf("could not create page: %s", err)
+	}
+	defer f.Close()
+	cmd := exec.Command("scanimage", args...)
+	cmd.Stdout = f
+	stderr := &strings.Builder{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("scan failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return []string{page}, nil
+}
+
+// isPartialFile reports whether the inbound file is still being written by ding
+func isPartialFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, PARTIAL_SUFFIX)
+}
+
+// copyIntoInbound copies the file into the inbound directory under the given name; the file appears at once
+func copyIntoInbound(path, name string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", path, err)
+	}
+	defer in.Close()
+	return writeIntoInbound(name, in)
+}
+
+// writeIntoInbound writes the content into the inbound directory under the given name; the file appears at once
+func writeIntoInbound(name string, content io.Reader) error {
+	// write to a hidden file first, so nobody picks up a half written file
+	partial := filepath.Join(Inbound, "."+name+PARTIAL_SUFFIX)
+	out, err := os.Create(partial)
+	if err != nil {
+		return fmt.Errorf("could not write into inbound: %s", err)
+	}
+	if _, err := io.Copy(out, content); err != nil {
+		out.Close()
+		os.Remove(partial)
+		return fmt.Errorf("could not write into inbound: %s", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("could not write into inbound: %s", err)
+	}
+	if err := os.Rename(partial, filepath.Join(Inbound, name)); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("could not write into inbound: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stub of scanimage which logs its arguments, one per line; with --batch it writes DING_TEST_PAGES pages,
+// otherwise a single page to stdout; DING_TEST_SCAN_FAIL makes it fail like a scanner which is not there
+const scanimageStub = `#!/bin/sh
+for arg in "$@"; do
+	echo "$arg" >> "$DING_TEST_SCAN_LOG"
+done
+if [ -n "$DING_TEST_SCAN_FAIL" ]; then
+	echo "scanimage: no SANE devices found" >&2
+	exit 1
+fi
+for arg in "$@"; do
+	case "$arg" in
+	--batch=*)
+		pattern="${arg#--batch=}"
+		i=1
+		while [ "$i" -le "$DING_TEST_PAGES" ]; do
+			printf "page $i" > "$(printf "$pattern" "$i")"
+			i=$((i + 1))
+		done
+		echo "scanimage: sane_start: Document feeder out of documents" >&2
+		exit 7
+		;;
+	esac
+done
+printf "page 1"
+`
+
+// fakeConverter writes the content of the images into the pdf, so the test sees which pages ended up in it
+type fakeConverter struct {
+	fail bool
+}
+
+func (c fakeConverter) ToPdf(pdf string, images ...string) error {
+	if c.fail {
+		return fmt.Errorf("could not convert")
+	}
+	content := make([]string, 0, len(images))
+	for _, image := range images {
+		data, err := ioutil.ReadFile(image)
+		if err != nil {
+			return err
+		}
+		content = append(content, string(data))
+	}
+	return ioutil.WriteFile(pdf, []byte("%PDF-1.4\n"+strings.Join(content, "\n")), 0644)
+}
+
+// setupScan puts the scanimage stub on the path, lets temporary directories go into a directory of the test
+// and returns where the stub logs its arguments
+func setupScan(t *testing.T) (string, string) {
+	t.Helper()
+	setupIngest(t)
+	converter := ImageConv
+	t.Cleanup(func() { ImageConv = converter })
+	ImageConv = fakeConverter{}
+
+	bin := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(bin, "scanimage"), []byte(scanimageStub), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	log := filepath.Join(t.TempDir(), "args")
+	t.Setenv("PATH", bin)
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("DING_TEST_SCAN_LOG", log)
+	return log, tmp
+}
+
+func scanimageArgs(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatalf("scanimage has not been run: %s", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// inboundNames returns all files in inbound, including partial and temporary ones
+func inboundNames(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(Inbound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ScanProfile
+		pages   int
+		args    []string
+	}{
+		{
+			name:  "default",
+			pages: 1,
+			args:  []string{"--format=png", "--resolution=300", "--mode=Gray"},
+		},
+		{
+			name:    "single page",
+			profile: ScanProfile{Device: "test:0", Resolution: 600, Mode: "Color", Options: []string{"--swdeskew=yes"}},
+			pages:   1,
+			args:    []string{"--format=png", "--resolution=600", "--mode=Color", "--device-name=test:0", "--swdeskew=yes"},
+		},
+		{
+			name:    "adf",
+			profile: ScanProfile{Source: "ADF Duplex", Batch: true},
+			pages:   3,
+			args:    []string{"--format=png", "--resolution=300", "--mode=Gray", "--source=ADF Duplex", "--batch=$TMPDIR/ding-scan-*/page%04d.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, tmp := setupScan(t)
+			t.Setenv("DING_TEST_PAGES", fmt.Sprint(tt.pages))
+
+			result, err := Scan(tt.profile)
+			if err != nil {
+				t.Fatalf("scan failed: %s", err)
+			}
+			if result.Pages != tt.pages {
+				t.Errorf("expected %v pages, got %v", tt.pages, result.Pages)
+			}
+
+			args := scanimageArgs(t, log)
+			if len(args) != len(tt.args) {
+				t.Fatalf("expected arguments %q, got %q", tt.args, args)
+			}
+			for i, want := range tt.args {
+				want = strings.Replace(want, "$TMPDIR", tmp, 1)
+				if ok, _ := filepath.Match(want, args[i]); !ok {
+					t.Errorf("expected argument %q, got %q", want, args[i])
+				}
+			}
+
+			if names := inboundNames(t); len(names) != 1 || names[0] != result.Name {
+				t.Fatalf("expected only %s in inbound, got %v", result.Name, names)
+			}
+			if !TimestampPrefixMatch.MatchString(result.Name) || !strings.HasSuffix(result.Name, ".pdf") {
+				t.Errorf("unexpected name %s", result.Name)
+			}
+			data, _ := ioutil.ReadFile(filepath.Join(Inbound, result.Name))
+			for page := 1; page <= tt.pages; page++ {
+				if !strings.Contains(string(data), fmt.Sprintf("page %v", page)) {
+					t.Errorf("page %v is missing in %q", page, data)
+				}
+			}
+		})
+	}
+}
+
+func TestScanFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ScanProfile
+		env     map[string]string
+		fail    bool
+		err     string
+	}{
+		{name: "no scanner", env: map[string]string{"DING_TEST_SCAN_FAIL": "1"}, err: "no SANE devices found"},
+		{name: "empty feeder", profile: ScanProfile{Batch: true}, env: map[string]string{"DING_TEST_PAGES": "0"}, err: "no pages scanned"},
+		{name: "conversion", fail: true, err: "could not turn scan into pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, tmp := setupScan(t)
+			ImageConv = fakeConverter{fail: tt.fail}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Scan(tt.profile)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if names := inboundNames(t); len(names) != 0 {
+				t.Errorf("a failed scan must not leave anything in inbound, got %v", names)
+			}
+			if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+				t.Errorf("scanned pages are left over: %v", entries)
+			}
+		})
+	}
+}
+
+// failingReader returns some data and then fails, like a scan which breaks off
+type failingReader struct {
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, fmt.Errorf("connection lost")
+	}
+	r.read = true
+	return copy(p, "%PDF-1.4"), nil
+}
+
+func TestWriteIntoInboundRemovesPartialFile(t *testing.T) {
+	setupIngest(t)
+
+	if err := writeIntoInbound("scan.pdf", &failingReader{}); err == nil {
+		t.Fatalf("expected the write to fail")
+	}
+	if names := inboundNames(t); len(names) != 0 {
+		t.Errorf("partial file is left over: %v", names)
+	}
+
+	if err := writeIntoInbound("scan.pdf", strings.NewReader("%PDF-1.4")); err != nil {
+		t.Fatal(err)
+	}
+	if names := inboundNames(t); len(names) != 1 || names[0] != "scan.pdf" {
+		t.Errorf("expected only scan.pdf in inbound, got %v", names)
+	}
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	searchIndex   map[string]indexedDoc
+	searchIndexMu sync.Mutex
+
+	// number of external text extractions which run in parallel while warming the index
+	searchIndexWorkers = 4
+)
+
+func init() {
+	searchIndex = make(map[string]indexedDoc)
+}
+
+type indexedDoc struct {
+	directory string
+	name      string
+	modTime   time.Time
+	text      string
+}
+
+// SearchResult is a single document matching a search query
+type SearchResult struct {
+	Directory string
+	Name      string
+	Path      string
+	Score     int
+}
+
+// WarmSearchIndex extracts the text of all documents in all directories (including sub directories) and puts it
+// into the search index; it covers the same documents as the hash index
+// documents which did not change since they have been indexed are skipped
+func WarmSearchIndex() error {
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan string)
+	seen := make(map[string]bool)
+
+	var wg sync.WaitGroup
+	for i := 0; i < searchIndexWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				updateSearchIndex(rel)
+			}
+		}()
+	}
+
+	for _, rel := range documents {
+		seen[filepath.Join(Dest, rel)] = true
+		jobs <- rel
+	}
+	close(jobs)
+	wg.Wait()
+
+	// forget about documents which are gone
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	for path := range searchIndex {
+		if !seen[path] {
+			delete(searchIndex, path)
+		}
+	}
+
+	return nil
+}
+
+// updateSearchIndex indexes the document at the given path relative to the destination
+// documents in sub directories belong to the directory at the top, which is the one the UIs list
+func updateSearchIndex(rel string) {
+	path := filepath.Join(Dest, rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	searchIndexMu.Lock()
+	doc, ok := searchIndex[path]
+	searchIndexMu.Unlock()
+	if ok && doc.modTime.Equal(info.ModTime()) {
+		return
+	}
+
+	// documents without text layer are still indexed; they can be found by name
+	text, err := GetDocText(path)
+	modTime := info.ModTime()
+	if err == ErrLocked {
+		// index the text once the document can be decrypted
+		modTime = time.Time{}
+	}
+
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	searchIndex[path] = indexedDoc{
+		directory: topDirectory(rel),
+		name:      filepath.Base(rel),
+		modTime:   modTime,
+		text:      text,
+	}
+}
+
+// GetCachedDocText returns the full text of the document at the given path from the search index
+func GetCachedDocText(path string) string {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	return searchIndex[path].text
+}
+
+// SearchQueryTerms splits a search query into lower case terms; tags like #tax-2026 are left out
+func SearchQueryTerms(query string) []string {
+	terms := make([]string, 0)
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !strings.HasPrefix(term, "#") {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// SearchQueryTags returns the tags of a search query; tags start with #
+func SearchQueryTags(query string) []string {
+	tags := make([]string, 0)
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "#") && len(term) > 1 {
+			tags = append(tags, ParseTags(term)...)
+		}
+	}
+	return tags
+}
+
+// SearchDocuments returns all indexed documents which contain every term of the query, either in their
+// name or their text; results are ranked by the number of hits, hits in the file name count more
+// terms starting with # are tags; only documents with all of these tags are returned
+func SearchDocuments(query string) []SearchResult {
+	terms := SearchQueryTerms(query)
+	tags := SearchQueryTags(query)
+	if len(terms) == 0 && len(tags) == 0 {
+		return nil
+	}
+
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+
+	results := make([]SearchResult, 0)
+	for path, doc := range searchIndex {
+		name := strings.ToLower(RemoveTimeStampFilePrefix(doc.name))
+		text := strings.ToLower(doc.text)
+
+		if len(tags) > 0 && !hasAllTags(path, tags) {
+			continue
+		}
+
+		// documents which only have to match tags are all ranked the same
+		score := 1
+		for _, term := range terms {
+			inName := strings.Count(name, term)
+			inText := strings.Count(text, term)
+			if inName+inText == 0 {
+				score = 0
+				break
+			}
+			score += inName*10 + inText
+		}
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Directory: doc.directory,
+			Name:      doc.name,
+			Path:      path,
+			Score:     score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+
+	return results
+}
+
+// hasAllTags reports whether the document at the given path carries all tags
+func hasAllTags(path string, tags []string) bool {
+	rel, err := filepath.Rel(Dest, path)
+	if err != nil {
+		return false
+	}
+	docTags := GetCachedTags(rel)
+	for _, tag := range tags {
+		if !HasTag(docTags, tag) {
+			return false
+		}
+	}
+	return true
+}
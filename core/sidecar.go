@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SIDECAR_SUFFIX = ".ding.json"
+
+	// ocr status of a document when it was filed
+	OCR_STATUS_NONE = "none" // no text layer
+	OCR_STATUS_TEXT = "text" // text layer was there already
+	OCR_STATUS_DONE = "ocr"  // text layer added by ding
+
+	SIDECAR_DATE_FORMAT = "2006-01-02"
+)
+
+var (
+	// sidecars of documents in the destination; key is the path relative to the destination
+	sidecarCache map[string]Sidecar
+	sidecarMu    sync.Mutex
+
+	// inbound files which got a text layer by ding
+	ocrDone   map[string]bool
+	ocrDoneMu sync.Mutex
+)
+
+func init() {
+	sidecarCache = make(map[string]Sidecar)
+	ocrDone = make(map[string]bool)
+}
+
+// Sidecar holds the metadata ding keeps next to a filed document in <file>.ding.json
+type Sidecar struct {
+	OriginalName string    `json:"original_name"`
+	Ingested     time.Time `json:"ingested"`
+	// sha256 of the document as it was in the inbound directory
+	Hash         string   `json:"hash"`
+	OCR          string   `json:"ocr,omitempty"`
+	DocumentDate string   `json:"document_date,omitempty"`
+	DueDate      string   `json:"due_date,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Notes        string   `json:"notes,omitempty"`
+}
+
+// SidecarPath returns the path of the sidecar for the document at the given path
+func SidecarPath(path string) string {
+	return path + SIDECAR_SUFFIX
+}
+
+// IsSidecar reports whether the file name belongs to a sidecar
+func IsSidecar(name string) bool {
+	return strings.HasSuffix(name, SIDECAR_SUFFIX)
+}
+
+// ReadSidecar reads the sidecar of the document at the given path relative to the destination
+// if there is none, the error satisfies os.IsNotExist; sidecars of encrypted documents return ErrLocked while locked
+func ReadSidecar(rel string) (Sidecar, error) {
+	var sidecar Sidecar
+	data, err := os.ReadFile(SidecarPath(filepath.Join(Dest, rel)))
+	if err != nil {
+		return sidecar, err
+	}
+	if bytes.HasPrefix(data, []byte(ENCRYPTION_MAGIC)) {
+		key, err := encryptionKey()
+		if err != nil {
+			return sidecar, err
+		}
+		if data, err = decrypt(key, data); err != nil {
+			return sidecar, fmt.Errorf("could not read sidecar of %s: %s", rel, err)
+		}
+	}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return sidecar, fmt.Errorf("could not read sidecar of %s: %s", rel, err)
+	}
+
+	sidecarMu.Lock()
+	sidecarCache[rel] = sidecar
+	sidecarMu.Unlock()
+	return sidecar, nil
+}
+
+// WriteSidecar writes the sidecar of the document at the given path relative to the destination
+// in encrypted directories the sidecar is encrypted like the document, it tells as much about it
+func WriteSidecar(rel string, sidecar Sidecar) error {
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode sidecar of %s: %s", rel, err)
+	}
+	data, err = encryptDocument(filepath.Dir(rel), append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("could not encrypt sidecar of %s: %s", rel, err)
+	}
+	if err := os.WriteFile(SidecarPath(filepath.Join(Dest, rel)), data, 0644); err != nil {
+		return fmt.Errorf("could not write sidecar of %s: %s", rel, err)
+	}
+
+	sidecarMu.Lock()
+	sidecarCache[rel] = sidecar
+	sidecarMu.Unlock()
+	return nil
+}
+
+// UpdateSidecar reads the sidecar of the given document, lets change do its changes and writes it back
+// a new sidecar is created if the document has none yet; with git versioning the change is committed
+func UpdateSidecar(rel string, change func(*Sidecar)) error {
+	sidecar, err := ReadSidecar(rel)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	change(&sidecar)
+	if err := WriteSidecar(rel, sidecar); err != nil {
+		return err
+	}
+	if err := commitDocuments("update "+rel, rel); err != nil {
+		return fmt.Errorf("sidecar of %s is written, but not committed: %s", rel, err)
+	}
+	return nil
+}
+
+// GetCachedSidecar returns the sidecar of the given document from the cache
+// sidecars get cached whenever the file list of their directory is updated
+func GetCachedSidecar(rel string) (Sidecar, bool) {
+	sidecarMu.Lock()
+	defer sidecarMu.Unlock()
+	sidecar, ok := sidecarCache[rel]
+	return sidecar, ok
+}
+
+// warmSidecarCache reads the sidecars and tags of all given files in the directory
+func warmSidecarCache(directory string, names []string) {
+	for _, name := range names {
+		rel := filepath.Join(directory, name)
+		sidecar, err := ReadSidecar(rel)
+		tags := sidecar.Tags
+		if err != nil {
+			sidecarMu.Lock()
+			delete(sidecarCache, rel)
+			sidecarMu.Unlock()
+			tags = getXattrTags(filepath.Join(Dest, rel))
+		}
+
+		tagMu.Lock()
+		tagCache[rel] = tags
+		tagMu.Unlock()
+	}
+}
+
+// topDirectory returns the first element of a path relative to the destination
+func topDirectory(rel string) string {
+	return strings.Split(filepath.ToSlash(rel), "/")[0]
+}
+
+// newSidecar collects the metadata for an inbound file which is about to be filed
+func newSidecar(name, hash string) Sidecar {
+	sidecar := Sidecar{
+		OriginalName: name,
+		Ingested:     time.Now(),
+		Hash:         hash,
+		OCR:          OCR_STATUS_NONE,
+	}
+
+	text, ok := GetCachedDocPagePreview(name, 1, false)
+	if !ok {
+		text = GetDocPreview(name)
+	}
+	if text != "" && text != NO_TEXT_PREVIEW && !strings.HasPrefix(text, "could not get preview") {
+		sidecar.OCR = OCR_STATUS_TEXT
+		if date, ok := DetectDocumentDate(text); ok {
+			sidecar.DocumentDate = date.Format(SIDECAR_DATE_FORMAT)
+		}
+	}
+
+	// deadlines are often further down the letter, so look at the whole text
+	if full, err := GetDocText(filepath.Join(Inbound, name)); err == nil {
+		text = full
+	}
+	if due, ok := DetectDueDate(text); ok {
+		sidecar.DueDate = due.Format(SIDECAR_DATE_FORMAT)
+	}
+
+	ocrDoneMu.Lock()
+	if ocrDone[name] {
+		sidecar.OCR = OCR_STATUS_DONE
+	}
+	ocrDoneMu.Unlock()
+
+	return sidecar
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DirectoryStats sums up the documents of a directory including its sub directories
+type DirectoryStats struct {
+	Name      string `json:"name"`
+	Documents int    `json:"documents"`
+	Size      int64  `json:"size"`
+}
+
+// MonthStats is the number of documents which were ingested in a month, e.g. 2026-10
+type MonthStats struct {
+	Month     string `json:"month"`
+	Documents int    `json:"documents"`
+}
+
+// FileStats is a single document and its size
+type FileStats struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Stats describes the archive and the inbound backlog
+type Stats struct {
+	Documents   int              `json:"documents"`
+	Size        int64            `json:"size"`
+	Directories []DirectoryStats `json:"directories"`
+	// only documents with timestamp prefix count
+	IngestsPerMonth []MonthStats `json:"ingests_per_month"`
+	Largest         []FileStats  `json:"largest"`
+	// documents where no text could be extracted, e.g. scans without ocr
+	WithoutText int `json:"without_text"`
+
+	InboundFiles  int       `json:"inbound_files"`
+	InboundSize   int64     `json:"inbound_size"`
+	OldestInbound time.Time `json:"oldest_inbound,omitempty"`
+}
+
+// WithoutTextShare returns the share of documents without text layer between 0 and 1
+func (s Stats) WithoutTextShare() float64 {
+	if s.Documents == 0 {
+		return 0
+	}
+	return float64(s.WithoutText) / float64(s.Documents)
+}
+
+// InboundAge returns how long the oldest inbound file is waiting already
+func (s Stats) InboundAge() time.Duration {
+	if s.OldestInbound.IsZero() {
+		return 0
+	}
+	return time.Since(s.OldestInbound)
+}
+
+// GetStats collects statistics about all documents and the inbound directory
+// largest is the number of biggest files which are listed
+// finding documents without text needs their text; it is taken from the sidecar, the search index or extracted
+func GetStats(largest int) (Stats, error) {
+	var stats Stats
+
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return stats, err
+	}
+	// extract texts in parallel upfront
+	if err := WarmSearchIndex(); err != nil {
+		return stats, err
+	}
+
+	directories := make(map[string]*DirectoryStats)
+	months := make(map[string]int)
+	files := make([]FileStats, 0, len(documents))
+
+	for _, rel := range documents {
+		info, err := os.Stat(filepath.Join(Dest, rel))
+		if err != nil {
+			continue
+		}
+		stats.Documents++
+		stats.Size += info.Size()
+
+		dir := topDirectory(rel)
+		if directories[dir] == nil {
+			directories[dir] = &DirectoryStats{Name: dir}
+		}
+		directories[dir].Documents++
+		directories[dir].Size += info.Size()
+
+		if t, ok := GetTimestampFromFileName(filepath.Base(rel)); ok {
+			months[t.Format("2006-01")]++
+		}
+
+		files = append(files, FileStats{Path: rel, Size: info.Size()})
+
+		if !hasText(rel) {
+			stats.WithoutText++
+		}
+	}
+
+	stats.Directories = make([]DirectoryStats, 0, len(directories))
+	for _, d := range directories {
+		stats.Directories = append(stats.Directories, *d)
+	}
+	sort.Slice(stats.Directories, func(i, j int) bool { return stats.Directories[i].Name < stats.Directories[j].Name })
+
+	stats.IngestsPerMonth = make([]MonthStats, 0, len(months))
+	for month, n := range months {
+		stats.IngestsPerMonth = append(stats.IngestsPerMonth, MonthStats{Month: month, Documents: n})
+	}
+	sort.Slice(stats.IngestsPerMonth, func(i, j int) bool { return stats.IngestsPerMonth[i].Month < stats.IngestsPerMonth[j].Month })
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if largest < 0 {
+		largest = 0
+	}
+	if len(files) > largest {
+		files = files[:largest]
+	}
+	stats.Largest = files
+
+	inbound, err := readInbound()
+	if err != nil {
+		return stats, err
+	}
+	for _, f := range inbound {
+		if f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		stats.InboundFiles++
+		stats.InboundSize += info.Size()
+		if stats.OldestInbound.IsZero() || info.ModTime().Before(stats.OldestInbound) {
+			stats.OldestInbound = info.ModTime()
+		}
+	}
+
+	return stats, nil
+}
+
+// hasText reports whether the document has a text layer
+func hasText(rel string) bool {
+	if sidecar, err := ReadSidecar(rel); err == nil && sidecar.OCR != "" {
+		return sidecar.OCR != OCR_STATUS_NONE
+	}
+	path := filepath.Join(Dest, rel)
+	searchIndexMu.Lock()
+	doc, ok := searchIndex[path]
+	searchIndexMu.Unlock()
+	if ok {
+		return doc.text != ""
+	}
+	text, err := GetDocText(path)
+	return err == nil && text != ""
+}
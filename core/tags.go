@@ -0,0 +1,155 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// XATTR_TAGS is the extended attribute for tags as proposed by freedesktop.org; file managers and
+// desktop search tools understand it
+const XATTR_TAGS = "user.xdg.tags"
+
+var (
+	// tags of documents in the destination; key is the path relative to the destination
+	tagCache map[string][]string
+	tagMu    sync.Mutex
+)
+
+func init() {
+	tagCache = make(map[string][]string)
+}
+
+// ParseTags splits user input into tags; tags are separated by commas or spaces, a leading # is dropped
+// tags are lower case and every tag is only returned once
+func ParseTags(input string) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		tag := strings.ToLower(strings.TrimLeft(field, "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// GetTags returns the tags of the document at the given path relative to the destination
+// tags are kept in the sidecar; documents without sidecar may still carry tags in the extended attribute
+func GetTags(rel string) []string {
+	var tags []string
+	if sidecar, err := ReadSidecar(rel); err == nil {
+		tags = sidecar.Tags
+	} else {
+		tags = getXattrTags(filepath.Join(Dest, rel))
+	}
+
+	tagMu.Lock()
+	tagCache[rel] = tags
+	tagMu.Unlock()
+	return tags
+}
+
+// HasTag reports whether the tag is in the list of tags
+func HasTag(tags []string, tag string) bool {
+	tag = strings.ToLower(strings.TrimLeft(tag, "#"))
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCachedTags returns the tags of the given document from the cache
+// tags get cached whenever the file list of their directory is updated
+func GetCachedTags(rel string) []string {
+	tagMu.Lock()
+	defer tagMu.Unlock()
+	return tagCache[rel]
+}
+
+// GetKnownTags returns all tags which are in use, most used first
+func GetKnownTags() []string {
+	counts := make(map[string]int)
+	tagMu.Lock()
+	for _, tags := range tagCache {
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+	tagMu.Unlock()
+
+	known := make([]string, 0, len(counts))
+	for tag := range counts {
+		known = append(known, tag)
+	}
+	sort.Slice(known, func(i, j int) bool {
+		if counts[known[i]] != counts[known[j]] {
+			return counts[known[i]] > counts[known[j]]
+		}
+		return known[i] < known[j]
+	})
+	return known
+}
+
+// CompleteTag returns the known tags which start with the given prefix, most used first
+func CompleteTag(prefix string) []string {
+	prefix = strings.ToLower(strings.TrimLeft(prefix, "#"))
+	matches := make([]string, 0)
+	for _, tag := range GetKnownTags() {
+		if strings.HasPrefix(tag, prefix) && tag != prefix {
+			matches = append(matches, tag)
+		}
+	}
+	return matches
+}
+
+// FindTaggedDocuments returns the paths relative to the destination of all documents with the given tag
+// it looks into all directories including sub directories
+func FindTaggedDocuments(tag string) ([]string, error) {
+	documents, err := GetAllDocuments()
+	if err != nil {
+		return nil, err
+	}
+	found := make([]string, 0)
+	for _, rel := range documents {
+		if HasTag(GetTags(rel), tag) {
+			found = append(found, rel)
+		}
+	}
+	return found, nil
+}
+
+// GetCachedTaggedDocuments returns the paths relative to the destination of all documents in the cache with the
+// given tag
+func GetCachedTaggedDocuments(tag string) []string {
+	found := make([]string, 0)
+	tagMu.Lock()
+	for rel, tags := range tagCache {
+		if HasTag(tags, tag) {
+			found = append(found, rel)
+		}
+	}
+	tagMu.Unlock()
+	sort.Strings(found)
+	return found
+}
+
+// forgetTags drops the document from the tag cache
+func forgetTags(rel string) {
+	tagMu.Lock()
+	defer tagMu.Unlock()
+	delete(tagCache, rel)
+}
+
+// tagsFromXattr parses the value of the tags attribute
+func tagsFromXattr(value []byte) []string {
+	if len(value) == 0 {
+		return nil
+	}
+	return ParseTags(strings.TrimRight(string(value), "\x00"))
+}
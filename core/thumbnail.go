@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// resolution the first page is rendered with; enough for a terminal, small enough to be fast
+	THUMBNAIL_DPI = 40
+)
+
+var (
+	thumbnailCache map[string]thumbnail
+	thumbnailsMu   sync.Mutex
+)
+
+func init() {
+	thumbnailCache = make(map[string]thumbnail)
+}
+
+type thumbnail struct {
+	modTime time.Time
+	img     image.Image
+}
+
+// GetDocThumbnail returns an image of the first page of the given inbound file
+// pdfs are rendered with the external command pdftoppm, images are decoded directly
+// thumbnails are cached as long as the file does not change
+func GetDocThumbnail(name string) (image.Image, error) {
+	path := filepath.Join(Inbound, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inbound file: %s", err)
+	}
+
+	thumbnailsMu.Lock()
+	cached, ok := thumbnailCache[name]
+	thumbnailsMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.img, nil
+	}
+
+	var img image.Image
+	if strings.ToLower(filepath.Ext(name)) == ".pdf" {
+		img, err = renderFirstPage(path)
+	} else {
+		img, err = decodeImageFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnailsMu.Lock()
+	defer thumbnailsMu.Unlock()
+	thumbnailCache[name] = thumbnail{modTime: info.ModTime(), img: img}
+	return img, nil
+}
+
+// GetCachedDocThumbnail returns the thumbnail of the given inbound file from the cache
+func GetCachedDocThumbnail(name string) (image.Image, bool) {
+	thumbnailsMu.Lock()
+	defer thumbnailsMu.Unlock()
+	cached, ok := thumbnailCache[name]
+	return cached.img, ok
+}
+
+// renderFirstPage runs pdftoppm to render the first page of a pdf as png
+func renderFirstPage(path string) (image.Image, error) {
+	cmd := exec.Command("pdftoppm", "-f", "1", "-l", "1", "-r", fmt.Sprint(THUMBNAIL_DPI), "-png", "-singlefile", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not render first page: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(output))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode rendered page: %s", err)
+	}
+	return img, nil
+}
+
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open image: %s", err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode image: %s", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// trashDirectory returns the users trash as specified by freedesktop.org
+func trashDirectory() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", fmt.Errorf("could not find home directory: %s", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// TrashFile moves the document at the given path relative to the destination into the users trash
+// the trash follows the freedesktop.org specification, so files can be restored with any file manager
+// the sidecar of the document goes along
+func TrashFile(rel string) error {
+	trash, err := trashDirectory()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(trash, "files"), 0700); err != nil {
+		return fmt.Errorf("could not create trash: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Join(trash, "info"), 0700); err != nil {
+		return fmt.Errorf("could not create trash: %s", err)
+	}
+
+	source := filepath.Join(Dest, rel)
+	if err := trashPath(trash, source); err != nil {
+		return fmt.Errorf("could not move %s to trash: %s", rel, err)
+	}
+	if _, err := os.Stat(SidecarPath(source)); err == nil {
+		if err := trashPath(trash, SidecarPath(source)); err != nil {
+			return fmt.Errorf("could not move sidecar of %s to trash: %s", rel, err)
+		}
+	}
+
+	hashIndexMu.Lock()
+	delete(hashIndex, rel)
+	hashIndexMu.Unlock()
+	sidecarMu.Lock()
+	delete(sidecarCache, rel)
+	sidecarMu.Unlock()
+	forgetTags(rel)
+	go UpdateDirectoryFilesCache(topDirectory(rel))
+
+	// the document is in the trash, so a failing commit only leaves the change uncommitted
+	commitDocuments("trash "+rel, rel)
+
+	return nil
+}
+
+// trashPath moves a single file into the given trash directory and writes its trash info
+func trashPath(trash, source string) error {
+	// find a name which is not used in the trash yet
+	base := filepath.Base(source)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(trash, "files", name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s (%v)%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: source}).EscapedPath(),
+		time.Now().Format("2006-01-02T15:04:05"))
+	infoPath := filepath.Join(trash, "info", name+".trashinfo")
+	if err := os.WriteFile(infoPath, []byte(info), 0600); err != nil {
+		return fmt.Errorf("could not write trash info: %s", err)
+	}
+
+	if err := moveFile(source, filepath.Join(trash, "files", name)); err != nil {
+		os.Remove(infoPath)
+		return err
+	}
+	return nil
+}
+
+// moveFile renames a file and falls back to copy and remove if source and target are on different devices
+func moveFile(source, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	return os.Remove(source)
+}
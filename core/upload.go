@@ -0,0 +1,220 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_UPLOAD_LISTEN = "127.0.0.1:8765"
+	// MiB
+	DEFAULT_UPLOAD_MAX_SIZE = 25
+	// form data beyond this goes into temporary files instead of memory
+	UPLOAD_MEMORY = 8 << 20
+)
+
+// UploadConfig configures the upload endpoint of ding serve
+type UploadConfig struct {
+	// address to listen on, 127.0.0.1:8765 by default; use the address in the lan to let phones upload
+	Listen string `json:"listen,omitempty"`
+	// secret every upload has to bring along; a random one is made up on start if empty
+	Token string `json:"token,omitempty"`
+	// largest upload in MiB, 25 by default
+	MaxSize int64 `json:"max_size,omitempty"`
+}
+
+// UploadResult is the answer to an upload
+type UploadResult struct {
+	Files []string `json:"files"`
+	Error string   `json:"error,omitempty"`
+}
+
+var uploadForm = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ding inbox</title>
+<style>body{font-family:sans-serif;max-width:30em;margin:2em auto;padding:0 1em}input,button{display:block;width:100%;margin:1em 0;font-size:1.2em}</style>
+</head>
+<body>
+<h1>ding inbox</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<form method="post" action="/inbox" enctype="multipart/form-data" onsubmit="this.action='/inbox?token='+encodeURIComponent(this.elements.token.value)">
+<input type="password" id="token" placeholder="token" value="{{.Token}}">
+<input type="file" name="file" accept="application/pdf,image/*" capture="environment" multiple>
+<button type="submit">Upload</button>
+</form>
+</body>
+</html>
+`))
+
+type uploadPage struct {
+	Token   string
+	Message string
+}
+
+// NewUploadToken returns a random token for uploads
+func NewUploadToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not create token: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// withDefaults fills in what is not configured
+func (c UploadConfig) withDefaults() UploadConfig {
+	if c.Listen == "" {
+		c.Listen = DEFAULT_UPLOAD_LISTEN
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = DEFAULT_UPLOAD_MAX_SIZE
+	}
+	return c
+}
+
+// NewUploadServer returns a server with an upload form at / and the endpoint POST /inbox, which writes
+// pdfs and images into inbound; the token is taken from the header Authorization: Bearer or the query parameter token
+func NewUploadServer(conf UploadConfig) (*http.Server, error) {
+	conf = conf.withDefaults()
+	if conf.Token == "" {
+		return nil, fmt.Errorf("uploads need a token")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		// the token may come with the link, so it does not have to be typed on the phone
+		page := uploadPage{}
+		if token := r.URL.Query().Get("token"); validUploadToken(conf, token) {
+			page.Token = token
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		uploadForm.Execute(w, page)
+	})
+	mux.HandleFunc("/inbox", func(w http.ResponseWriter, r *http.Request) {
+		handleUpload(conf, w, r)
+	})
+
+	return &http.Server{
+		Addr:              conf.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}, nil
+}
+
+func validUploadToken(conf UploadConfig, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(conf.Token)) == 1
+}
+
+func handleUpload(conf UploadConfig, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeUploadResult(w, r, http.StatusMethodNotAllowed, UploadResult{Error: "only POST is allowed"}, conf)
+		return
+	}
+
+	// nothing of the upload is read without the right token; the form sends it in the query
+	token := r.URL.Query().Get("token")
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	if !validUploadToken(conf, token) {
+		writeUploadResult(w, r, http.StatusUnauthorized, UploadResult{Error: "wrong token"}, conf)
+		return
+	}
+
+	maxSize := conf.MaxSize << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	if err := r.ParseMultipartForm(UPLOAD_MEMORY); err != nil {
+		writeUploadResult(w, r, http.StatusRequestEntityTooLarge, UploadResult{Error: fmt.Sprintf("the upload is larger than %v MiB or broken", conf.MaxSize)}, conf)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		writeUploadResult(w, r, http.StatusBadRequest, UploadResult{Error: "no file in the form field file"}, conf)
+		return
+	}
+	for _, header := range headers {
+		if !isUploadable(header.Filename) {
+			writeUploadResult(w, r, http.StatusUnsupportedMediaType, UploadResult{Error: fmt.Sprintf("%s is neither a pdf nor an image", header.Filename)}, conf)
+			return
+		}
+	}
+
+	result := UploadResult{Files: []string{}}
+	for _, header := range headers {
+		f, err := header.Open()
+		if err != nil {
+			result.Error = fmt.Sprintf("could not read %s: %s", header.Filename, err)
+			writeUploadResult(w, r, http.StatusBadRequest, result, conf)
+			return
+		}
+		name := freeInboundName(uploadName(header.Filename))
+		err = writeIntoInbound(name, f)
+		f.Close()
+		if err != nil {
+			result.Error = err.Error()
+			writeUploadResult(w, r, http.StatusInternalServerError, result, conf)
+			return
+		}
+		queuePreviewUpdate(name)
+		result.Files = append(result.Files, name)
+	}
+	writeUploadResult(w, r, http.StatusCreated, result, conf)
+}
+
+// isUploadable reports whether ding can do something with the uploaded file
+func isUploadable(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".pdf") || IsImage(name)
+}
+
+// uploadName makes a safe inbound name of the name the client sent
+func uploadName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimLeft(name, ".")
+	if strings.TrimSuffix(name, filepath.Ext(name)) == "" {
+		name = "upload" + strings.ToLower(filepath.Ext(name))
+	}
+	return GetTimestampFilePrefix() + name
+}
+
+// writeUploadResult answers with json for scripts and with the form again for browsers
+func writeUploadResult(w http.ResponseWriter, r *http.Request, status int, result UploadResult, conf UploadConfig) {
+	if result.Files == nil {
+		result.Files = []string{}
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	page := uploadPage{Message: result.Error}
+	if result.Error == "" {
+		page.Message = fmt.Sprintf("Uploaded %s", strings.Join(result.Files, ", "))
+	}
+	// keep the token in the form, so the next letter can be sent right away
+	if token := r.URL.Query().Get("token"); status != http.StatusUnauthorized && validUploadToken(conf, token) {
+		page.Token = token
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	uploadForm.Execute(w, page)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// watchedBody tells whether the handler read anything of the request body
+type watchedBody struct {
+	io.Reader
+	read bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.Reader.Read(p)
+}
+
+func uploadRequest(t *testing.T, target string) (*http.Request, *watchedBody) {
+	t.Helper()
+	var data bytes.Buffer
+	form := multipart.NewWriter(&data)
+	part, err := form.CreateFormFile("file", "letter.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("%PDF-1.4"))
+	form.Close()
+
+	body := &watchedBody{Reader: &data}
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r, body
+}
+
+func TestHandleUpload(t *testing.T) {
+	conf := UploadConfig{Token: "secret"}.withDefaults()
+
+	tests := []struct {
+		name   string
+		target string
+		bearer string
+		status int
+	}{
+		{name: "bearer", target: "/inbox", bearer: "secret", status: http.StatusCreated},
+		{name: "query", target: "/inbox?token=secret", status: http.StatusCreated},
+		{name: "no token", target: "/inbox", status: http.StatusUnauthorized},
+		{name: "wrong bearer", target: "/inbox?token=secret", bearer: "guess", status: http.StatusUnauthorized},
+		{name: "wrong query", target: "/inbox?token=guess", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupIngest(t)
+			r, body := uploadRequest(t, tt.target)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			handleUpload(conf, w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %v, got %v: %s", tt.status, w.Code, w.Body)
+			}
+			var result UploadResult
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.status == http.StatusUnauthorized {
+				if body.read {
+					t.Errorf("the upload has been read without the right token")
+				}
+				if names := inboundNames(t); len(names) != 0 {
+					t.Errorf("expected nothing in inbound, got %v", names)
+				}
+				return
+			}
+			if len(result.Files) != 1 || !strings.HasSuffix(result.Files[0], "_letter.pdf") {
+				t.Fatalf("unexpected result %+v", result)
+			}
+			if names := inboundNames(t); len(names) != 1 || names[0] != result.Files[0] {
+				t.Errorf("expected %s in inbound, got %v", result.Files[0], names)
+			}
+		})
+	}
+}
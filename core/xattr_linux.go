@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package core
+
+import (
+	"strings"
+	"syscall"
+)
+
+// getXattrTags reads the tags from the extended attribute of the file
+func getXattrTags(path string) []string {
+	size, err := syscall.Getxattr(path, XATTR_TAGS, nil)
+	if err != nil || size <= 0 {
+		return nil
+	}
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, XATTR_TAGS, value)
+	if err != nil {
+		return nil
+	}
+	return tagsFromXattr(value[:size])
+}
+
+// setXattrTags writes the tags into the extended attribute of the file; no tags remove the attribute
+func setXattrTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		err := syscall.Removexattr(path, XATTR_TAGS)
+		if err == syscall.ENODATA {
+			return nil
+		}
+		return err
+	}
+	return syscall.Setxattr(path, XATTR_TAGS, []byte(strings.Join(tags, ",")), 0)
+}
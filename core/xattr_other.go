@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package core
+
+// getXattrTags is not supported on this platform; tags live in the sidecar only
+func getXattrTags(path string) []string {
+	return nil
+}
+
+// setXattrTags is not supported on this platform; tags live in the sidecar only
+func setXattrTags(path string, tags []string) error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runDeadlines(args []string) error {
+	flags := flag.NewFlagSet("deadlines", flag.ExitOnError)
+	all := flags.Bool("all", false, "Include deadlines which are over")
+	days := flags.Int("days", 0, "Only list deadlines within this many days; 0 lists all upcoming")
+	ics := flags.String("ics", "", "Export the deadlines as iCalendar file; - writes to stdout")
+	asJSON := flags.Bool("json", false, "Print the list as json")
+	detect := flags.Bool("detect", false, "Look for due dates in documents which have none yet, e.g. filed by older versions")
+	flags.Parse(args)
+
+	if *detect {
+		found, err := core.DetectMissingDueDates()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Found %v new deadlines.\n", found)
+	}
+
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	from := today
+	if *all {
+		from = time.Time{}
+	}
+	deadlines, err := core.FindDeadlines(from)
+	if err != nil {
+		return err
+	}
+	if *days > 0 {
+		until := today.AddDate(0, 0, *days)
+		within := make([]core.Deadline, 0, len(deadlines))
+		for _, d := range deadlines {
+			if !d.Due.After(until) {
+				within = append(within, d)
+			}
+		}
+		deadlines = within
+	}
+
+	if *ics != "" {
+		if *ics == "-" {
+			return core.WriteICS(os.Stdout, deadlines)
+		}
+		f, err := os.Create(*ics)
+		if err != nil {
+			return fmt.Errorf("could not create calendar file: %s", err)
+		}
+		defer f.Close()
+		if err := core.WriteICS(f, deadlines); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %v deadlines to %s\n", len(deadlines), *ics)
+		return nil
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(deadlines)
+	}
+
+	if len(deadlines) == 0 {
+		fmt.Println("No deadlines found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DUE\tIN\tFILE")
+	for _, d := range deadlines {
+		fmt.Fprintf(w, "%s\t%v days\t%s\n", d.Due.Format("2006-01-02"), math.Round(d.Due.Sub(today).Hours()/24), d.Path)
+	}
+	return w.Flush()
+}
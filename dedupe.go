@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/bubl"
+	"github.com/zmnpl/ding/core"
+)
+
+func runDedupe(args []string) error {
+	flags := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the report as json")
+	interactive := flags.Bool("interactive", false, "Review the duplicates and move unwanted copies to the trash")
+	threshold := flags.Float64("threshold", 0.7, "Minimum text similarity (0..1) of near-duplicates")
+	flags.Parse(args)
+
+	groups, err := core.FindDuplicateGroups(*threshold)
+	if err != nil {
+		return err
+	}
+
+	if *interactive {
+		return bubl.RunDedupe(groups)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(groups)
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No duplicates found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tKIND\tSIMILARITY\tFILE")
+	for i, g := range groups {
+		for _, f := range g.Files {
+			fmt.Fprintf(w, "%v\t%s\t%.0f%%\t%s\n", i+1, g.Kind, g.Similarity*100, f)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the report as json")
+	flags.Parse(args)
+
+	report := core.RunDoctor()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tCHECK\tDETAIL")
+		category := ""
+		for _, c := range report.Checks {
+			if c.Category != category && category != "" {
+				fmt.Fprintln(w, "\t\t")
+			}
+			category = c.Category
+			name := c.Category + " " + c.Name
+			if c.Required {
+				name += " (required)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Status, name, c.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !report.Healthy() {
+		return fmt.Errorf("ding can not work as configured, see the failed checks")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runImportMail(args []string) error {
+	flags := flag.NewFlagSet("import-mail", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the imported messages as json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding import-mail [flags] [maildir|mbox|file.eml...]\n\nPuts pdf and image attachments into the inbound directory. Without arguments the .eml files\nin inbound are imported and removed. Imported messages are recorded in %s\nand skipped the next time.\n\nFlags:\n", core.MailRecordPath())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	imports := make([]core.MailImport, 0)
+	if flags.NArg() == 0 {
+		imported, err := core.ImportInboundMails()
+		if err != nil {
+			return err
+		}
+		imports = imported
+	}
+	for _, source := range flags.Args() {
+		imported, err := core.ImportMail(source)
+		if err != nil {
+			return err
+		}
+		imports = append(imports, imported...)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(imports)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FROM\tSUBJECT\tFILES")
+	files, skipped := 0, 0
+	for _, i := range imports {
+		result := strings.Join(i.Files, ", ")
+		switch {
+		case i.Error != "":
+			result = "error: " + i.Error
+		case i.Skipped:
+			skipped++
+			continue
+		case len(i.Files) == 0:
+			result = "-"
+		}
+		files += len(i.Files)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.From, i.Subject, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%v file(s) from %v message(s), %v imported before\n", files, len(imports)-skipped, skipped)
+	return nil
+}
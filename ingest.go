@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+// ingestedDocument is the outcome of the ingest command for one file
+type ingestedDocument struct {
+	File   string            `json:"file"`
+	Target string            `json:"target,omitempty"`
+	Steps  []core.StepResult `json:"steps"`
+	Error  string            `json:"error,omitempty"`
+}
+
+func runIngest(args []string) error {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	directory := flags.String("dir", "", "Directory the files are filed into")
+	name := flags.String("name", "", "New name of the file; only with a single file, defaults to the inbound name")
+	tags := flags.String("tags", "", "Tags for the documents, separated by spaces or commas")
+	force := flags.Bool("force", false, "File documents even if they are archived already")
+	asJSON := flags.Bool("json", false, "Print the results as json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding ingest -dir directory [flags] file...\n\nFiles are taken from the inbound directory and go through the configured pipeline.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *directory == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *name != "" && flags.NArg() > 1 {
+		return fmt.Errorf("-name can only be used with a single file")
+	}
+	if info, err := os.Stat(filepath.Join(core.Dest, *directory)); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is no directory in %s", *directory, core.Dest)
+	}
+
+	documents := make([]ingestedDocument, 0, flags.NArg())
+	failed := 0
+	for _, file := range flags.Args() {
+		file = filepath.Base(file)
+		newName := file
+		if *name != "" {
+			newName = *name
+		}
+
+		job := core.NewIngestJob(file, core.GetTimestampFilePrefix()+newName, *directory, core.ParseTags(*tags), *force)
+		results, err := core.Ingest(job)
+
+		document := ingestedDocument{File: file, Target: job.Target, Steps: results}
+		if err != nil {
+			document.Error = err.Error()
+			failed++
+		}
+		documents = append(documents, document)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(documents); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tSTEP\tSTATUS\tMESSAGE")
+		for _, d := range documents {
+			for _, r := range d.Steps {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.File, r.Step, r.Status, r.Message)
+			}
+			if d.Error != "" && len(d.Steps) == 0 {
+				fmt.Fprintf(w, "%s\t-\t%s\t%s\n", d.File, core.STEP_STATUS_FAILED, d.Error)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v files were not filed", failed, len(documents))
+	}
+	return nil
+}
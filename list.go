@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+// listedDocument is a line of the list command
+type listedDocument struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+func runList(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	tag := flags.String("tag", "", "Only list documents with this tag")
+	asJSON := flags.Bool("json", false, "Print the list as json")
+	flags.Parse(args)
+
+	var paths []string
+	var err error
+	if *tag != "" {
+		paths, err = core.FindTaggedDocuments(*tag)
+	} else {
+		paths, err = core.GetAllDocuments()
+	}
+	if err != nil {
+		return err
+	}
+
+	documents := make([]listedDocument, len(paths))
+	for i, p := range paths {
+		tags := core.GetTags(p)
+		if tags == nil {
+			tags = []string{}
+		}
+		documents[i] = listedDocument{Path: p, Tags: tags}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(documents)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tTAGS")
+	for _, d := range documents {
+		fmt.Fprintf(w, "%s\t%s\n", d.Path, strings.Join(d.Tags, ", "))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runLog(args []string) error {
+	flags := flag.NewFlagSet("log", flag.ExitOnError)
+	limit := flags.Int("n", 20, "Number of commits to show; 0 shows all")
+	files := flags.Bool("files", false, "List the documents each commit touched")
+	asJSON := flags.Bool("json", false, "Print the history as json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding log [flags] [path]\n\nShows the git history of the documents directory, optionally of a single directory or document.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	entries, err := core.GitLog(*limit, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tCOMMIT\tMESSAGE")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Date.Format("2006-01-02 15:04"), e.Hash[:7], e.Message)
+		if *files && len(e.Files) > 0 {
+			fmt.Fprintf(w, "\t\t  %s\n", strings.Join(e.Files, "\n\t\t  "))
+		}
+	}
+	return w.Flush()
+}
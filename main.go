@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -15,10 +16,24 @@ func main() {
 	tview := flag.Bool("ui", false, "Run with tview UI")
 	out := flag.String("out", core.Dest, "Root path of your documents directory; where the documents should go")
 	in := flag.String("in", core.Inbound, "Path where your scans / inbound documents land")
+	config := flag.String("config", core.ConfigPath(), "Path of the config file")
+	writeMetadata := flag.Bool("writeMetadata", core.WriteMetadataOnIngest, "Write name, directory and date into the metadata of pdfs when filing them")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command [command flags]]\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+		printCommands()
+	}
 	flag.Parse()
 
-	if _, err := os.Stat(*out); !os.IsNotExist(err) {
+	if err := core.LoadConfig(*config); err != nil {
+		log.Fatal(err)
+	}
+
+	// the doctor reports missing directories itself
+	doctor := flag.Arg(0) == "doctor"
+
+	if _, err := os.Stat(*out); !os.IsNotExist(err) || doctor {
 		if *out != core.Dest {
 			core.Dest = *out
 		}
@@ -26,7 +41,7 @@ func main() {
 		log.Fatal("The given documentPath does not exist")
 	}
 
-	if _, err := os.Stat(*in); !os.IsNotExist(err) {
+	if _, err := os.Stat(*in); !os.IsNotExist(err) || doctor {
 		if *in != core.Inbound {
 			core.Inbound = *in
 		}
@@ -34,11 +49,26 @@ func main() {
 		log.Fatal("The given inboundPath does not exist")
 	}
 
+	core.WriteMetadataOnIngest = *writeMetadata
+	core.CleanupDecrypted()
+
 	if *checkDeps {
 		core.PrintCheckDeps()
 		os.Exit(0)
 	}
 
+	if flag.NArg() > 0 {
+		cmd, ok := commands[flag.Arg(0)]
+		if !ok {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err := cmd.run(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	if *tview {
 		tui.Start()
 		os.Exit(0)
@@ -0,0 +1,750 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrEncrypted is returned when content of an encrypted pdf is requested
+	ErrEncrypted = errors.New("pdf is encrypted")
+
+	objHeaderMatch = regexp.MustCompile(`(?m)(\d+)\s+(\d+)\s+obj\b`)
+)
+
+type xrefEntry struct {
+	offset    int64
+	inStream  bool
+	streamNum int
+	index     int
+}
+
+// Document is a parsed pdf file
+type Document struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	trailer Dict
+	objects map[int]Object
+	pages   []Dict
+	// offset of the last cross reference section; needed to append updates
+	startXref int64
+	// the cross reference was damaged and rebuilt by scanning the file
+	reconstructed bool
+}
+
+// Open reads and parses the pdf file at the given path
+func Open(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pdf: %s", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a pdf from memory
+func Parse(data []byte) (*Document, error) {
+	if bytes.Index(data[:min(len(data), 1024)], []byte("%PDF-")) < 0 {
+		return nil, fmt.Errorf("not a pdf file")
+	}
+
+	d := &Document{
+		data:    data,
+		xref:    make(map[int]xrefEntry),
+		objects: make(map[int]Object),
+	}
+
+	if err := d.readXrefChain(); err != nil || d.trailer["Root"] == nil {
+		// damaged cross reference; rebuild it by scanning for objects
+		if err := d.reconstructXref(); err != nil {
+			return nil, err
+		}
+		d.reconstructed = true
+	}
+
+	if _, ok := d.Resolve(d.trailer["Root"]).(Dict); !ok {
+		return nil, fmt.Errorf("pdf has no document catalog")
+	}
+
+	return d, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Trailer returns the trailer dictionary; for files with several updates it is the newest one
+func (d *Document) Trailer() Dict {
+	return d.trailer
+}
+
+// Data returns the raw bytes of the file
+func (d *Document) Data() []byte {
+	return d.data
+}
+
+// StartXref returns the offset of the newest cross reference section
+func (d *Document) StartXref() int64 {
+	return d.startXref
+}
+
+// MaxObjectNumber returns the highest object number in use
+func (d *Document) MaxObjectNumber() int {
+	max := 0
+	for num := range d.xref {
+		if num > max {
+			max = num
+		}
+	}
+	if size, ok := d.trailer["Size"].(int64); ok && int(size)-1 > max {
+		max = int(size) - 1
+	}
+	return max
+}
+
+// Encrypted reports whether the document uses the pdf standard security handler or any other encryption
+func (d *Document) Encrypted() bool {
+	return d.trailer["Encrypt"] != nil
+}
+
+// Catalog returns the document catalog
+func (d *Document) Catalog() Dict {
+	catalog, _ := d.Resolve(d.trailer["Root"]).(Dict)
+	return catalog
+}
+
+// Info returns the document information dictionary decoded to strings, e.g. Title, Author or CreationDate
+func (d *Document) Info() map[string]string {
+	info := make(map[string]string)
+	dict, ok := d.Resolve(d.trailer["Info"]).(Dict)
+	if !ok {
+		return info
+	}
+	for key, value := range dict {
+		if s, ok := d.Resolve(value).(String); ok && !d.Encrypted() {
+			info[string(key)] = TextString(s)
+		}
+	}
+	return info
+}
+
+// Resolve follows references until it reaches a direct object
+func (d *Document) Resolve(obj Object) Object {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj
+		}
+		obj = d.object(ref.Num)
+	}
+	return nil
+}
+
+func (d *Document) object(num int) Object {
+	if obj, ok := d.objects[num]; ok {
+		return obj
+	}
+	// guard against reference loops while loading
+	d.objects[num] = nil
+
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil
+	}
+
+	var obj Object
+	if entry.inStream {
+		obj = d.objectFromStream(entry.streamNum, entry.index)
+	} else {
+		obj, _, _ = d.readIndirectObject(entry.offset)
+	}
+	d.objects[num] = obj
+	return obj
+}
+
+// readIndirectObject reads "num gen obj ... endobj" at the given offset
+func (d *Document) readIndirectObject(offset int64) (Object, int, error) {
+	if offset < 0 || offset >= int64(len(d.data)) {
+		return nil, 0, fmt.Errorf("object offset out of range")
+	}
+	l := &lexer{data: d.data, pos: int(offset)}
+
+	numObj, err := l.readObject()
+	if err != nil {
+		return nil, 0, err
+	}
+	num, ok := numObj.(int64)
+	if !ok {
+		return nil, 0, fmt.Errorf("no object at offset %v", offset)
+	}
+	if _, err := l.readObject(); err != nil {
+		return nil, 0, err
+	}
+	if kw, err := l.readObject(); err != nil || kw != keyword("obj") {
+		return nil, 0, fmt.Errorf("no object at offset %v", offset)
+	}
+
+	obj, err := l.readObject()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dict, ok := obj.(Dict)
+	if !ok {
+		return obj, int(num), nil
+	}
+
+	// a dictionary might be followed by stream data
+	save := l.pos
+	l.skipSpace()
+	if l.readWord() != "stream" {
+		l.pos = save
+		return dict, int(num), nil
+	}
+	// the keyword is followed by CRLF or LF
+	if l.pos < len(d.data) && d.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(d.data) && d.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	length := -1
+	if n, ok := number(d.lengthOf(dict, int(num))); ok {
+		length = int(n)
+	}
+	end := start + length
+	if length < 0 || end > len(d.data) || !bytes.HasPrefix(bytes.TrimLeft(d.data[end:min(end+32, len(d.data))], " \r\n\t\x00"), []byte("endstream")) {
+		// wrong length; look for the end marker instead
+		i := bytes.Index(d.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, 0, fmt.Errorf("unterminated stream")
+		}
+		end = start + i
+		for end > start && (d.data[end-1] == '\n' || d.data[end-1] == '\r') {
+			end--
+		}
+	}
+
+	return &Stream{Dict: dict, Raw: d.data[start:end]}, int(num), nil
+}
+
+// lengthOf returns the stream length; an indirect length must not point back to the stream itself
+func (d *Document) lengthOf(dict Dict, num int) Object {
+	if ref, ok := dict["Length"].(Ref); ok {
+		if ref.Num == num {
+			return nil
+		}
+		return d.Resolve(ref)
+	}
+	return dict["Length"]
+}
+
+func (d *Document) objectFromStream(streamNum, index int) Object {
+	stream, ok := d.Resolve(Ref{Num: streamNum}).(*Stream)
+	if !ok {
+		return nil
+	}
+	data, err := d.Decode(stream)
+	if err != nil {
+		return nil
+	}
+
+	n, _ := d.Resolve(stream.Dict["N"]).(int64)
+	first, _ := d.Resolve(stream.Dict["First"]).(int64)
+
+	// header: pairs of object number and offset relative to first
+	l := &lexer{data: data}
+	for i := 0; i < int(n); i++ {
+		if _, err := l.readObject(); err != nil {
+			return nil
+		}
+		off, err := l.readObject()
+		if err != nil {
+			return nil
+		}
+		if i == index {
+			offset, ok := off.(int64)
+			if !ok || int(first+offset) >= len(data) {
+				return nil
+			}
+			ol := &lexer{data: data, pos: int(first + offset)}
+			obj, err := ol.readObject()
+			if err != nil {
+				return nil
+			}
+			return obj
+		}
+	}
+	return nil
+}
+
+// readXrefChain reads the newest cross reference section and all previous ones
+func (d *Document) readXrefChain() error {
+	tail := d.data[len(d.data)-min(len(d.data), 2048):]
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return fmt.Errorf("startxref not found")
+	}
+	l := &lexer{data: tail, pos: i + len("startxref")}
+	offObj, err := l.readObject()
+	if err != nil {
+		return err
+	}
+	offset, ok := offObj.(int64)
+	if !ok {
+		return fmt.Errorf("invalid startxref")
+	}
+	d.startXref = offset
+
+	seen := make(map[int64]bool)
+	for offset > 0 && !seen[offset] {
+		seen[offset] = true
+		trailer, err := d.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+		// hybrid files have an additional cross reference stream
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := d.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	return nil
+}
+
+// readXrefSection reads a cross reference table or stream at the given offset and returns its trailer
+// entries which are already known from newer sections are kept
+func (d *Document) readXrefSection(offset int64) (Dict, error) {
+	if offset < 0 || offset >= int64(len(d.data)) {
+		return nil, fmt.Errorf("xref offset out of range")
+	}
+	l := &lexer{data: d.data, pos: int(offset)}
+	l.skipSpace()
+	if bytes.HasPrefix(d.data[l.pos:], []byte("xref")) {
+		l.pos += 4
+		return d.readXrefTable(l)
+	}
+
+	obj, _, err := d.readIndirectObject(offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok || stream.Dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("no cross reference at offset %v", offset)
+	}
+	return stream.Dict, d.readXrefStream(stream)
+}
+
+func (d *Document) readXrefTable(l *lexer) (Dict, error) {
+	for {
+		obj, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		if obj == keyword("trailer") {
+			trailerObj, err := l.readObject()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := trailerObj.(Dict)
+			if !ok {
+				return nil, fmt.Errorf("invalid trailer")
+			}
+			return trailer, nil
+		}
+
+		start, ok := obj.(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid cross reference table")
+		}
+		countObj, err := l.readObject()
+		if err != nil {
+			return nil, err
+		}
+		count, ok := countObj.(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid cross reference table")
+		}
+
+		for i := int64(0); i < count; i++ {
+			l.skipSpace()
+			off, err1 := strconv.ParseInt(l.readWord(), 10, 64)
+			l.skipSpace()
+			_, err2 := strconv.Atoi(l.readWord())
+			l.skipSpace()
+			kind := l.readWord()
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid cross reference entry")
+			}
+			num := int(start + i)
+			if _, known := d.xref[num]; known {
+				continue
+			}
+			if kind == "n" {
+				d.xref[num] = xrefEntry{offset: off}
+			} else {
+				// remember free entries too, so older sections do not revive deleted objects
+				d.xref[num] = xrefEntry{offset: -1}
+			}
+		}
+	}
+}
+
+func (d *Document) readXrefStream(stream *Stream) error {
+	data, err := d.Decode(stream)
+	if err != nil {
+		return fmt.Errorf("could not decode cross reference stream: %s", err)
+	}
+
+	wArr, ok := stream.Dict["W"].(Array)
+	if !ok || len(wArr) < 3 {
+		return fmt.Errorf("invalid cross reference stream")
+	}
+	w := make([]int, 3)
+	for i := range w {
+		v, _ := wArr[i].(int64)
+		w[i] = int(v)
+	}
+	size, _ := stream.Dict["Size"].(int64)
+
+	index := []int64{0, size}
+	if idx, ok := stream.Dict["Index"].(Array); ok {
+		index = index[:0]
+		for _, v := range idx {
+			n, _ := v.(int64)
+			index = append(index, n)
+		}
+	}
+
+	field := func(b []byte) int64 {
+		var v int64
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		return v
+	}
+
+	entryLen := w[0] + w[1] + w[2]
+	pos := 0
+	for s := 0; s+1 < len(index); s += 2 {
+		for i := int64(0); i < index[s+1]; i++ {
+			if pos+entryLen > len(data) {
+				return nil
+			}
+			typ := int64(1)
+			if w[0] > 0 {
+				typ = field(data[pos : pos+w[0]])
+			}
+			f2 := field(data[pos+w[0] : pos+w[0]+w[1]])
+			f3 := field(data[pos+w[0]+w[1] : pos+entryLen])
+			pos += entryLen
+
+			num := int(index[s] + i)
+			if _, known := d.xref[num]; known {
+				continue
+			}
+			switch typ {
+			case 0:
+				d.xref[num] = xrefEntry{offset: -1}
+			case 1:
+				d.xref[num] = xrefEntry{offset: f2}
+			case 2:
+				d.xref[num] = xrefEntry{inStream: true, streamNum: int(f2), index: int(f3)}
+			}
+		}
+	}
+	return nil
+}
+
+// reconstructXref scans the whole file for objects, for files with broken cross references
+func (d *Document) reconstructXref() error {
+	d.xref = make(map[int]xrefEntry)
+	d.objects = make(map[int]Object)
+	d.trailer = nil
+
+	for _, m := range objHeaderMatch.FindAllSubmatchIndex(d.data, -1) {
+		num, _ := strconv.Atoi(string(d.data[m[2]:m[3]]))
+		// later definitions win, just like incremental updates
+		d.xref[num] = xrefEntry{offset: int64(m[0])}
+	}
+	if len(d.xref) == 0 {
+		return fmt.Errorf("no objects found in pdf")
+	}
+
+	// objects in object streams
+	nums := make([]int, 0, len(d.xref))
+	for num := range d.xref {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		stream, ok := d.Resolve(Ref{Num: num}).(*Stream)
+		if !ok || stream.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		data, err := d.Decode(stream)
+		if err != nil {
+			continue
+		}
+		n, _ := stream.Dict["N"].(int64)
+		l := &lexer{data: data}
+		for i := 0; i < int(n); i++ {
+			objNum, err := l.readObject()
+			if err != nil {
+				break
+			}
+			if _, err := l.readObject(); err != nil {
+				break
+			}
+			if on, ok := objNum.(int64); ok {
+				if _, known := d.xref[int(on)]; !known {
+					d.xref[int(on)] = xrefEntry{inStream: true, streamNum: num, index: i}
+				}
+			}
+		}
+	}
+
+	// the trailer is either a trailer dictionary or the dictionary of a cross reference stream
+	if i := bytes.LastIndex(d.data, []byte("trailer")); i >= 0 {
+		l := &lexer{data: d.data, pos: i + len("trailer")}
+		if obj, err := l.readObject(); err == nil {
+			d.trailer, _ = obj.(Dict)
+		}
+	}
+	if d.trailer == nil || d.trailer["Root"] == nil {
+		for _, num := range nums {
+			if stream, ok := d.Resolve(Ref{Num: num}).(*Stream); ok && stream.Dict["Type"] == Name("XRef") {
+				d.trailer = stream.Dict
+			}
+		}
+	}
+	if d.trailer == nil || d.trailer["Root"] == nil {
+		// last resort; find the catalog
+		for num := range d.xref {
+			if dict, ok := d.Resolve(Ref{Num: num}).(Dict); ok && dict["Type"] == Name("Catalog") {
+				d.trailer = Dict{"Root": Ref{Num: num}}
+				break
+			}
+		}
+	}
+	if d.trailer == nil {
+		return fmt.Errorf("could not find pdf trailer")
+	}
+	return nil
+}
+
+// Decode returns the decoded data of a stream
+func (d *Document) Decode(stream *Stream) ([]byte, error) {
+	if d.Encrypted() && stream.Dict["Type"] != Name("XRef") {
+		return nil, ErrEncrypted
+	}
+
+	filters := make([]Name, 0)
+	parms := make([]Dict, 0)
+	switch f := d.Resolve(stream.Dict["Filter"]).(type) {
+	case Name:
+		filters = append(filters, f)
+	case Array:
+		for _, v := range f {
+			if n, ok := d.Resolve(v).(Name); ok {
+				filters = append(filters, n)
+			}
+		}
+	}
+	switch p := d.Resolve(stream.Dict["DecodeParms"]).(type) {
+	case Dict:
+		parms = append(parms, p)
+	case Array:
+		for _, v := range p {
+			dp, _ := d.Resolve(v).(Dict)
+			parms = append(parms, dp)
+		}
+	}
+
+	data := stream.Raw
+	for i, f := range filters {
+		var parm Dict
+		if i < len(parms) {
+			parm = parms[i]
+		}
+		var err error
+		switch f {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+			if err == nil {
+				data, err = unpredict(data, parm)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("unsupported filter %s", f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not inflate stream: %s", err)
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("could not inflate stream: %s", err)
+	}
+	// truncated streams are common; keep what could be read
+	return out, nil
+}
+
+// unpredict reverses png predictors, as used in cross reference streams
+func unpredict(data []byte, parm Dict) ([]byte, error) {
+	predictor, _ := parm["Predictor"].(int64)
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, fmt.Errorf("tiff predictor is not supported")
+		}
+		return data, nil
+	}
+
+	columns := int64(1)
+	if c, ok := parm["Columns"].(int64); ok {
+		columns = c
+	}
+	colors := int64(1)
+	if c, ok := parm["Colors"].(int64); ok {
+		colors = c
+	}
+	bpc := int64(8)
+	if b, ok := parm["BitsPerComponent"].(int64); ok {
+		bpc = b
+	}
+	bpp := int((colors*bpc + 7) / 8)
+	rowLen := int((columns*colors*bpc + 7) / 8)
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+rowLen < len(data)+1 && pos < len(data); pos += rowLen + 1 {
+		typ := data[pos]
+		end := pos + 1 + rowLen
+		if end > len(data) {
+			end = len(data)
+		}
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:end])
+
+		for i := 0; i < rowLen; i++ {
+			var left, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up := prev[i]
+			switch typ {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func asciiHexDecode(data []byte) []byte {
+	l := &lexer{data: append(append([]byte{}, data...), '>')}
+	return l.readHexString()
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	var group [5]byte
+	n := 0
+	for _, c := range data {
+		if c == '~' {
+			break
+		}
+		if isSpace(c) {
+			continue
+		}
+		if c == 'z' && n == 0 {
+			out = append(out, 0, 0, 0, 0)
+			continue
+		}
+		if c < '!' || c > 'u' {
+			return nil, fmt.Errorf("invalid ascii85 data")
+		}
+		group[n] = c - '!'
+		n++
+		if n == 5 {
+			v := uint32(0)
+			for _, g := range group {
+				v = v*85 + uint32(g)
+			}
+			out = append(out, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			n = 0
+		}
+	}
+	if n > 1 {
+		for i := n; i < 5; i++ {
+			group[i] = 84
+		}
+		v := uint32(0)
+		for _, g := range group {
+			v = v*85 + uint32(g)
+		}
+		out = append(out, []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}[:n-1]...)
+	}
+	return out, nil
+}
@@ -0,0 +1,294 @@
+package pdf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestdata(t *testing.T, name string) *Document {
+	t.Helper()
+	doc, err := Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("could not open %s: %s", name, err)
+	}
+	return doc
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file          string
+		pages         int
+		reconstructed bool
+		encrypted     bool
+		err           string
+	}{
+		{file: "simple.pdf", pages: 2},
+		{file: "objstm.pdf", pages: 1},
+		{file: "broken-xref.pdf", pages: 2, reconstructed: true},
+		{file: "broken-xref-objstm.pdf", pages: 1, reconstructed: true},
+		{file: "truncated.pdf", pages: 2, reconstructed: true},
+		{file: "truncated-stream.pdf", pages: 1},
+		{file: "encrypted.pdf", pages: 1, encrypted: true},
+		{file: "no-objects.pdf", err: "no objects found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc, err := Open(filepath.Join("testdata", tt.file))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := doc.NumPages(); got != tt.pages {
+				t.Errorf("expected %v pages, got %v", tt.pages, got)
+			}
+			if doc.reconstructed != tt.reconstructed {
+				t.Errorf("expected reconstructed %v, got %v", tt.reconstructed, doc.reconstructed)
+			}
+			if doc.Encrypted() != tt.encrypted {
+				t.Errorf("expected encrypted %v, got %v", tt.encrypted, doc.Encrypted())
+			}
+		})
+	}
+}
+
+func TestParseNoPdf(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("hello"), []byte("PK\x03\x04 zip file")} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestPageText(t *testing.T) {
+	tests := []struct {
+		file string
+		page int
+		// the text has to contain these, in order
+		want []string
+		err  string
+	}{
+		{file: "simple.pdf", page: 1, want: []string{"Hello World", "Second line"}},
+		{file: "simple.pdf", page: 2, want: []string{"Page two"}},
+		{file: "simple.pdf", page: 3, err: "out of range"},
+		{file: "simple.pdf", page: 0, err: "out of range"},
+		{file: "objstm.pdf", page: 1, want: []string{"Compressed objects"}},
+		{file: "broken-xref.pdf", page: 2, want: []string{"Page two"}},
+		{file: "broken-xref-objstm.pdf", page: 1, want: []string{"Compressed objects"}},
+		// the first page is complete, the second one is cut off
+		{file: "truncated.pdf", page: 1, want: []string{"Hello World"}},
+		{file: "truncated.pdf", page: 2, want: []string{}},
+		// what could be inflated is kept
+		{file: "truncated-stream.pdf", page: 1, want: []string{"Readable start", "filler"}},
+		{file: "encrypted.pdf", page: 1, err: ErrEncrypted.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			doc := openTestdata(t, tt.file)
+			text, err := doc.PageText(tt.page)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			rest := text
+			for _, w := range tt.want {
+				i := strings.Index(rest, w)
+				if i < 0 {
+					t.Fatalf("expected %q in page text %q", w, text)
+				}
+				rest = rest[i+len(w):]
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	text, err := openTestdata(t, "simple.pdf").Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := strings.Split(text, "\f")
+	if len(pages) != 2 || !strings.Contains(pages[1], "Page two") {
+		t.Errorf("expected two pages separated by form feed, got %q", text)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	info := openTestdata(t, "simple.pdf").Info()
+	if info["Title"] != "Simple" || info["Author"] != "ding" {
+		t.Errorf("unexpected info %v", info)
+	}
+	if len(openTestdata(t, "encrypted.pdf").Info()) != 0 {
+		t.Errorf("encrypted strings must not be returned")
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	date := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	meta := Metadata{
+		Title:    "Rechnung Oktober",
+		Subject:  "Stadtwerke – Strom",
+		Keywords: []string{"strom", "rechnung"},
+		Date:     date,
+	}
+
+	tests := []struct {
+		file string
+		// the update is written as cross reference stream
+		xrefStream bool
+		created    time.Time
+		err        string
+	}{
+		// the creation date of the pdf is kept
+		{file: "simple.pdf", created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{file: "objstm.pdf", xrefStream: true, created: date},
+		{file: "broken-xref.pdf", err: "damaged"},
+		{file: "encrypted.pdf", err: ErrEncrypted.Error()},
+		{file: "no-objects.pdf", err: "no objects found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data := openTestdataBytes(t, tt.file)
+			out, err := UpdateMetadata(data, meta)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.HasPrefix(out, data) {
+				t.Errorf("the original bytes have to stay untouched")
+			}
+
+			doc, err := Parse(out)
+			if err != nil {
+				t.Fatalf("could not parse updated pdf: %s", err)
+			}
+			if doc.reconstructed {
+				t.Errorf("cross reference of the update is broken")
+			}
+			if doc.usesXrefStream() != tt.xrefStream {
+				t.Errorf("expected cross reference stream %v", tt.xrefStream)
+			}
+			info := doc.Info()
+			if info["Title"] != meta.Title || info["Subject"] != meta.Subject || info["Keywords"] != "strom, rechnung" {
+				t.Errorf("unexpected info %v", info)
+			}
+			if created, err := ParseDate(info["CreationDate"]); err != nil || !created.Equal(tt.created) {
+				t.Errorf("unexpected creation date %q", info["CreationDate"])
+			}
+
+			xmp, ok := doc.Resolve(doc.Catalog()["Metadata"]).(*Stream)
+			if !ok {
+				t.Fatalf("no xmp metadata")
+			}
+			packet, _ := doc.Decode(xmp)
+			if !bytes.Contains(packet, []byte("Rechnung Oktober")) {
+				t.Errorf("title missing in xmp %s", packet)
+			}
+			if !bytes.Contains(packet, []byte(tt.created.Format("2006-01-02T15:04:05"))) {
+				t.Errorf("creation date missing in xmp %s", packet)
+			}
+
+			original := openTestdata(t, tt.file)
+			for page := 1; page <= original.NumPages(); page++ {
+				before, _ := original.PageText(page)
+				after, _ := doc.PageText(page)
+				if before != after {
+					t.Errorf("text of page %v changed from %q to %q", page, before, after)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateMetadataKeepsInfo(t *testing.T) {
+	out, err := UpdateMetadata(openTestdataBytes(t, "simple.pdf"), Metadata{Title: "New"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := Parse(out)
+	info := doc.Info()
+	if info["Author"] != "ding" {
+		t.Errorf("existing entries have to be kept, got %v", info)
+	}
+	if info["CreationDate"] != "D:20200102030405Z" {
+		t.Errorf("creation date has to be kept without a date, got %q", info["CreationDate"])
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		err  bool
+	}{
+		{in: "D:20261018120000Z", want: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{in: "D:20261018120000+02'00'", want: time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)},
+		{in: "D:20261018", want: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{in: "yesterday", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.in, tt.want, got, err)
+		}
+		if back, _ := ParseDate(pdfDate(got)); !back.Equal(got) {
+			t.Errorf("%s: formatting and parsing again gives %s", tt.in, back)
+		}
+	}
+}
+
+func TestPdfDate(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want string
+	}{
+		{in: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), want: "D:20261018120000Z"},
+		{in: time.Date(2026, 10, 18, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), want: "D:20261018120000+02'00'"},
+		{in: time.Date(2026, 10, 18, 12, 0, 0, 0, time.FixedZone("IST", 5*60*60+30*60)), want: "D:20261018120000+05'30'"},
+		{in: time.Date(2026, 10, 18, 12, 0, 0, 0, time.FixedZone("NDT", -(2*60*60+30*60))), want: "D:20261018120000-02'30'"},
+	}
+	for _, tt := range tests {
+		if got := pdfDate(tt.in); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.in, tt.want, got)
+		}
+		if back, _ := ParseDate(pdfDate(tt.in)); !back.Equal(tt.in) {
+			t.Errorf("%s: formatting and parsing again gives %s", tt.in, back)
+		}
+	}
+}
+
+func openTestdataBytes(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
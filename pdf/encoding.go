@@ -0,0 +1,150 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	pdfDocEncoding  [256]rune
+	winAnsiEncoding [256]rune
+	macRomanEnc     [256]rune
+	standardEnc     [256]rune
+)
+
+func init() {
+	for i := range pdfDocEncoding {
+		pdfDocEncoding[i] = rune(i)
+		winAnsiEncoding[i] = rune(i)
+		macRomanEnc[i] = rune(i)
+		standardEnc[i] = rune(i)
+	}
+
+	// PDFDocEncoding differs from latin-1 in 0x18-0x1f and 0x80-0xa0
+	for i, r := range []rune{0x02d8, 0x02c7, 0x02c6, 0x02d9, 0x02dd, 0x02db, 0x02da, 0x02dc} {
+		pdfDocEncoding[0x18+i] = r
+	}
+	for i, r := range []rune{
+		0x2022, 0x2020, 0x2021, 0x2026, 0x2014, 0x2013, 0x0192, 0x2044, 0x2039, 0x203a, 0x2212, 0x2030, 0x201e, 0x201c, 0x201d, 0x2018,
+		0x2019, 0x201a, 0x2122, 0xfb01, 0xfb02, 0x0141, 0x0152, 0x0160, 0x0178, 0x017d, 0x0131, 0x0142, 0x0153, 0x0161, 0x017e, 0xfffd,
+		0x20ac,
+	} {
+		pdfDocEncoding[0x80+i] = r
+	}
+
+	// WinAnsiEncoding is windows code page 1252
+	for i, r := range []rune{
+		0x20ac, 0xfffd, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021, 0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0xfffd, 0x017d, 0xfffd,
+		0xfffd, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014, 0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0xfffd, 0x017e, 0x0178,
+	} {
+		winAnsiEncoding[0x80+i] = r
+	}
+
+	for i, r := range []rune{
+		0x00c4, 0x00c5, 0x00c7, 0x00c9, 0x00d1, 0x00d6, 0x00dc, 0x00e1, 0x00e0, 0x00e2, 0x00e4, 0x00e3, 0x00e5, 0x00e7, 0x00e9, 0x00e8,
+		0x00ea, 0x00eb, 0x00ed, 0x00ec, 0x00ee, 0x00ef, 0x00f1, 0x00f3, 0x00f2, 0x00f4, 0x00f6, 0x00f5, 0x00fa, 0x00f9, 0x00fb, 0x00fc,
+		0x2020, 0x00b0, 0x00a2, 0x00a3, 0x00a7, 0x2022, 0x00b6, 0x00df, 0x00ae, 0x00a9, 0x2122, 0x00b4, 0x00a8, 0x2260, 0x00c6, 0x00d8,
+		0x221e, 0x00b1, 0x2264, 0x2265, 0x00a5, 0x00b5, 0x2202, 0x2211, 0x220f, 0x03c0, 0x222b, 0x00aa, 0x00ba, 0x03a9, 0x00e6, 0x00f8,
+		0x00bf, 0x00a1, 0x00ac, 0x221a, 0x0192, 0x2248, 0x2206, 0x00ab, 0x00bb, 0x2026, 0x00a0, 0x00c0, 0x00c3, 0x00d5, 0x0152, 0x0153,
+		0x2013, 0x2014, 0x201c, 0x201d, 0x2018, 0x2019, 0x00f7, 0x25ca, 0x00ff, 0x0178, 0x2044, 0x20ac, 0x2039, 0x203a, 0xfb01, 0xfb02,
+		0x2021, 0x00b7, 0x201a, 0x201e, 0x2030, 0x00c2, 0x00ca, 0x00c1, 0x00cb, 0x00c8, 0x00cd, 0x00ce, 0x00cf, 0x00cc, 0x00d3, 0x00d4,
+		0xf8ff, 0x00d2, 0x00da, 0x00db, 0x00d9, 0x0131, 0x02c6, 0x02dc, 0x00af, 0x02d8, 0x02d9, 0x02da, 0x00b8, 0x02dd, 0x02db, 0x02c7,
+	} {
+		macRomanEnc[0x80+i] = r
+	}
+
+	// StandardEncoding; only the differences to ascii and the most common upper half glyphs
+	standardEnc[0x27] = 0x2019
+	standardEnc[0x60] = 0x2018
+	for i := 0x80; i < 0x100; i++ {
+		standardEnc[i] = 0xfffd
+	}
+	for code, r := range map[int]rune{
+		0xa1: 0x00a1, 0xa2: 0x00a2, 0xa3: 0x00a3, 0xa4: 0x2044, 0xa5: 0x00a5, 0xa6: 0x0192, 0xa7: 0x00a7, 0xa8: 0x00a4,
+		0xa9: 0x0027, 0xaa: 0x201c, 0xab: 0x00ab, 0xac: 0x2039, 0xad: 0x203a, 0xae: 0xfb01, 0xaf: 0xfb02, 0xb1: 0x2013,
+		0xb2: 0x2020, 0xb3: 0x2021, 0xb4: 0x00b7, 0xb6: 0x00b6, 0xb7: 0x2022, 0xb8: 0x201a, 0xb9: 0x201e, 0xba: 0x201d,
+		0xbb: 0x00bb, 0xbc: 0x2026, 0xbd: 0x2030, 0xbf: 0x00bf, 0xc1: 0x0060, 0xc2: 0x00b4, 0xc3: 0x02c6, 0xc4: 0x02dc,
+		0xc5: 0x00af, 0xc6: 0x02d8, 0xc7: 0x02d9, 0xc8: 0x00a8, 0xca: 0x02da, 0xcb: 0x00b8, 0xcd: 0x02dd, 0xce: 0x02db,
+		0xcf: 0x02c7, 0xd0: 0x2014, 0xe1: 0x00c6, 0xe3: 0x00aa, 0xe8: 0x0141, 0xe9: 0x00d8, 0xea: 0x0152, 0xeb: 0x00ba,
+		0xf1: 0x00e6, 0xf5: 0x0131, 0xf8: 0x0142, 0xf9: 0x00f8, 0xfa: 0x0153, 0xfb: 0x00df,
+	} {
+		standardEnc[code] = r
+	}
+}
+
+// baseEncoding returns the encoding table for a named pdf encoding
+func baseEncoding(name Name) [256]rune {
+	switch name {
+	case "WinAnsiEncoding":
+		return winAnsiEncoding
+	case "MacRomanEncoding":
+		return macRomanEnc
+	case "PDFDocEncoding":
+		return pdfDocEncoding
+	}
+	return standardEnc
+}
+
+// glyphNames maps adobe glyph names, which are used in /Differences arrays, to unicode
+// single letters and uniXXXX names are handled in glyphRune
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%', "ampersand": '&',
+	"quotesingle": '\'', "quoteright": 0x2019, "quoteleft": 0x2018, "parenleft": '(', "parenright": ')', "asterisk": '*',
+	"plus": '+', "comma": ',', "hyphen": '-', "minus": 0x2212, "period": '.', "slash": '/', "colon": ':', "semicolon": ';',
+	"less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@', "bracketleft": '[', "backslash": '\\',
+	"bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`', "braceleft": '{', "bar": '|',
+	"braceright": '}', "asciitilde": '~', "zero": '0', "one": '1', "two": '2', "three": '3', "four": '4', "five": '5',
+	"six": '6', "seven": '7', "eight": '8', "nine": '9', "Adieresis": 'Ä', "Odieresis": 'Ö', "Udieresis": 'Ü',
+	"adieresis": 'ä', "odieresis": 'ö', "udieresis": 'ü', "germandbls": 'ß', "eacute": 'é', "egrave": 'è',
+	"ecircumflex": 'ê', "agrave": 'à', "aacute": 'á', "acircumflex": 'â', "ccedilla": 'ç', "Eacute": 'É',
+	"oacute": 'ó', "uacute": 'ú', "iacute": 'í', "ntilde": 'ñ', "Euro": '€', "euro": '€', "section": '§',
+	"degree": '°', "endash": 0x2013, "emdash": 0x2014, "bullet": 0x2022, "ellipsis": 0x2026, "quotedblleft": 0x201c,
+	"quotedblright": 0x201d, "quotedblbase": 0x201e, "quotesinglbase": 0x201a, "guillemotleft": '«',
+	"guillemotright": '»', "fi": 0xfb01, "fl": 0xfb02, "ff": 0xfb00, "ffi": 0xfb03, "ffl": 0xfb04, "copyright": '©',
+	"registered": '®', "trademark": 0x2122, "nbspace": 0xa0, "periodcentered": 0xb7, "multiply": '×', "divide": '÷',
+	"plusminus": '±', "mu": 'µ', "paragraph": '¶', "sterling": '£', "yen": '¥', "cent": '¢', "dagger": 0x2020,
+	"daggerdbl": 0x2021, "perthousand": 0x2030, "onehalf": '½', "onequarter": '¼', "threequarters": '¾',
+	"twosuperior": '²', "threesuperior": '³', "dotlessi": 0x131, "exclamdown": '¡', "questiondown": '¿',
+}
+
+// glyphRune returns the unicode value for a glyph name
+func glyphRune(name string) (rune, bool) {
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	// uniXXXX and uXXXX[XX]
+	for _, prefix := range []string{"uni", "u"} {
+		if strings.HasPrefix(name, prefix) {
+			hex := strings.TrimPrefix(name, prefix)
+			if len(hex) >= 4 && len(hex) <= 6 {
+				if v, err := strconv.ParseUint(hex[:4], 16, 32); err == nil && prefix == "uni" {
+					return rune(v), true
+				}
+				if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+					return rune(v), true
+				}
+			}
+		}
+	}
+	// names like a.sc or A_small
+	if i := strings.IndexAny(name, "._"); i > 0 {
+		return glyphRune(name[:i])
+	}
+	return 0, false
+}
+
+func decodeUTF16(b []byte, littleEndian bool) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if littleEndian {
+			units = append(units, uint16(b[i+1])<<8|uint16(b[i]))
+		} else {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,216 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// font holds what is needed to map character codes of a font to unicode
+type font struct {
+	// bytes per character code
+	codeLen   int
+	toUnicode map[uint32]string
+	encoding  [256]rune
+	composite bool
+}
+
+func (d *Document) newFont(dict Dict) *font {
+	f := &font{codeLen: 1, encoding: standardEnc}
+	if dict == nil {
+		return f
+	}
+
+	subtype, _ := dict["Subtype"].(Name)
+	if subtype == "Type0" {
+		f.composite = true
+		f.codeLen = 2
+		// embedded cmaps define their code length themselves
+		if cmap, ok := d.Resolve(dict["Encoding"]).(*Stream); ok {
+			if data, err := d.Decode(cmap); err == nil {
+				if n := codespaceLength(data); n > 0 {
+					f.codeLen = n
+				}
+			}
+		}
+	} else {
+		f.encoding = d.simpleEncoding(dict, subtype)
+	}
+
+	if stream, ok := d.Resolve(dict["ToUnicode"]).(*Stream); ok {
+		if data, err := d.Decode(stream); err == nil {
+			f.toUnicode = parseToUnicode(data)
+		}
+	}
+	return f
+}
+
+// simpleEncoding builds the code to unicode table of a single byte font
+func (d *Document) simpleEncoding(dict Dict, subtype Name) [256]rune {
+	enc := standardEnc
+	if subtype == "TrueType" {
+		enc = winAnsiEncoding
+	}
+
+	switch e := d.Resolve(dict["Encoding"]).(type) {
+	case Name:
+		enc = baseEncoding(e)
+	case Dict:
+		if base, ok := d.Resolve(e["BaseEncoding"]).(Name); ok {
+			enc = baseEncoding(base)
+		}
+		if diffs, ok := d.Resolve(e["Differences"]).(Array); ok {
+			code := 0
+			for _, item := range diffs {
+				switch v := d.Resolve(item).(type) {
+				case int64:
+					code = int(v)
+				case Name:
+					if code >= 0 && code < 256 {
+						if r, ok := glyphRune(string(v)); ok {
+							enc[code] = r
+						}
+					}
+					code++
+				}
+			}
+		}
+	}
+	return enc
+}
+
+func (f *font) decode(s String) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		n := f.codeLen
+		if i+n > len(s) {
+			n = len(s) - i
+		}
+		var code uint32
+		for _, c := range s[i : i+n] {
+			code = code<<8 | uint32(c)
+		}
+		i += n
+
+		if u, ok := f.toUnicode[code]; ok {
+			b.WriteString(u)
+			continue
+		}
+		if f.composite {
+			// without a ToUnicode map the codes of composite fonts are glyph ids; nothing to read
+			continue
+		}
+		if r := f.encoding[code&0xff]; r != 0xfffd {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// codespaceLength returns the byte length of codes as defined by the codespace ranges of a cmap
+func codespaceLength(data []byte) int {
+	l := &lexer{data: data}
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			return 0
+		}
+		if obj == keyword("begincodespacerange") {
+			lo, err := l.readObject()
+			if err != nil {
+				return 0
+			}
+			if s, ok := lo.(String); ok {
+				return len(s)
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode cmap
+func parseToUnicode(data []byte) map[uint32]string {
+	m := make(map[uint32]string)
+	l := &lexer{data: data}
+
+	code := func(s String) uint32 {
+		var c uint32
+		for _, b := range s {
+			c = c<<8 | uint32(b)
+		}
+		return c
+	}
+	unicode := func(s String) string {
+		u := decodeUTF16(s, false)
+		if !utf8.ValidString(u) {
+			return ""
+		}
+		return u
+	}
+
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			return m
+		}
+		switch obj {
+		case keyword("beginbfchar"):
+			for {
+				src, err := l.readObject()
+				if err != nil || src == keyword("endbfchar") {
+					break
+				}
+				dst, err := l.readObject()
+				if err != nil {
+					return m
+				}
+				s, ok1 := src.(String)
+				dstStr, ok2 := dst.(String)
+				if ok1 && ok2 {
+					m[code(s)] = unicode(dstStr)
+				}
+			}
+		case keyword("beginbfrange"):
+			for {
+				lo, err := l.readObject()
+				if err != nil || lo == keyword("endbfrange") {
+					break
+				}
+				hi, err1 := l.readObject()
+				dst, err2 := l.readObject()
+				if err1 != nil || err2 != nil {
+					return m
+				}
+				loStr, ok1 := lo.(String)
+				hiStr, ok2 := hi.(String)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := code(loStr), code(hiStr)
+				if end < start || end-start > 0xffff {
+					continue
+				}
+				switch d := dst.(type) {
+				case String:
+					// consecutive codes map to consecutive unicode values; the last code unit is incremented
+					base := []rune(unicode(d))
+					if len(base) == 0 {
+						continue
+					}
+					for c := start; c <= end; c++ {
+						r := append([]rune{}, base...)
+						r[len(r)-1] += rune(c - start)
+						m[c] = string(r)
+					}
+				case Array:
+					for i, item := range d {
+						if s, ok := item.(String); ok && start+uint32(i) <= end {
+							m[start+uint32(i)] = unicode(s)
+						}
+					}
+				}
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,364 @@
+// Package pdf reads the structure, text and metadata of pdf files without external tools
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Object is any pdf object: nil, bool, int64, float64, Name, String, Array, Dict, Ref or *Stream
+type Object interface{}
+
+// Name is a pdf name object like /Type
+type Name string
+
+// String is a pdf string object; it holds the raw bytes, see TextString for decoding
+type String []byte
+
+// Array is a pdf array object
+type Array []Object
+
+// Dict is a pdf dictionary object
+type Dict map[Name]Object
+
+// Ref is an indirect reference to an object, like 12 0 R
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Stream is a pdf stream object; Raw holds the still encoded data
+type Stream struct {
+	Dict Dict
+	Raw  []byte
+}
+
+// keyword is a bare word; either true, false, null, obj, stream... or an operator in content streams
+type keyword string
+
+// lexer reads pdf objects from a byte slice
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *lexer) eof() bool {
+	return l.pos >= len(l.data)
+}
+
+// skipSpace skips white space and comments
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isSpace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// readWord reads a sequence of regular characters
+func (l *lexer) readWord() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// readObject reads the next object; references like 12 0 R are resolved to Ref
+// bare words which are no objects are returned as keyword
+func (l *lexer) readObject() (Object, error) {
+	l.skipSpace()
+	if l.eof() {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return l.readName(), nil
+	case c == '(':
+		l.pos++
+		return l.readLiteralString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.readDict()
+		}
+		l.pos++
+		return l.readHexString(), nil
+	case c == '[':
+		l.pos++
+		return l.readArray()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return keyword(string(c)), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.readNumberOrRef()
+	}
+
+	word := l.readWord()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		// stray delimiter
+		l.pos++
+		return keyword(string(c)), nil
+	}
+	return keyword(word), nil
+}
+
+func (l *lexer) readName() Name {
+	var b bytes.Buffer
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return Name(b.String())
+}
+
+func (l *lexer) readLiteralString() String {
+	var b bytes.Buffer
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b.Bytes())
+			}
+		case '\r':
+			// end of line markers are read as \n
+			if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+				l.pos++
+			}
+			c = '\n'
+		case '\\':
+			if l.eof() {
+				return String(b.Bytes())
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// escaped line break is no line break at all
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b.WriteByte(c)
+	}
+	return String(b.Bytes())
+}
+
+func (l *lexer) readHexString() String {
+	var b bytes.Buffer
+	var hi byte
+	half := false
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			continue
+		}
+		if half {
+			b.WriteByte(hi<<4 | v)
+		} else {
+			hi = v
+		}
+		half = !half
+	}
+	if half {
+		b.WriteByte(hi << 4)
+	}
+	return String(b.Bytes())
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func (l *lexer) readArray() (Array, error) {
+	arr := make(Array, 0)
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return arr, fmt.Errorf("unterminated array")
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return arr, nil
+		}
+		obj, err := l.readObject()
+		if err != nil {
+			return arr, err
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (l *lexer) readDict() (Dict, error) {
+	dict := make(Dict)
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return dict, fmt.Errorf("unterminated dictionary")
+		}
+		if l.data[l.pos] == '>' {
+			l.pos++
+			if !l.eof() && l.data[l.pos] == '>' {
+				l.pos++
+			}
+			return dict, nil
+		}
+
+		key, err := l.readObject()
+		if err != nil {
+			return dict, err
+		}
+		name, ok := key.(Name)
+		if !ok {
+			// broken key; skip it
+			continue
+		}
+		value, err := l.readObject()
+		if err != nil {
+			return dict, err
+		}
+		if _, ok := value.(keyword); ok {
+			continue
+		}
+		dict[name] = value
+	}
+}
+
+func (l *lexer) readNumberOrRef() (Object, error) {
+	word := l.readWord()
+	if i, err := strconv.ParseInt(word, 10, 64); err == nil {
+		// might be a reference: num gen R
+		save := l.pos
+		l.skipSpace()
+		start := l.pos
+		genWord := l.readWord()
+		if gen, err := strconv.Atoi(genWord); err == nil && l.pos > start && i >= 0 {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' && (l.pos+1 >= len(l.data) || isSpace(l.data[l.pos+1]) || isDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return Ref{Num: int(i), Gen: gen}, nil
+			}
+		}
+		l.pos = save
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		// pdfs in the wild contain things like "--1"; read as zero like other readers do
+		return int64(0), nil
+	}
+	return f, nil
+}
+
+// number returns the numeric value of an object
+func number(obj Object) (float64, bool) {
+	switch v := obj.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// TextString decodes a pdf text string; either UTF-16 with byte order mark or PDFDocEncoding
+func TextString(s String) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		return decodeUTF16(s[2:], false)
+	}
+	if len(s) >= 2 && s[0] == 0xff && s[1] == 0xfe {
+		return decodeUTF16(s[2:], true)
+	}
+	if len(s) >= 3 && s[0] == 0xef && s[1] == 0xbb && s[2] == 0xbf {
+		return string(s[3:])
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = pdfDocEncoding[c]
+	}
+	return string(runes)
+}
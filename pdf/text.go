@@ -0,0 +1,352 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// TJ offsets (thousandths of text space) which are wider than this are read as space between words
+	wordGapThreshold = 200
+	// maximum nesting of form xobjects
+	maxFormDepth = 8
+)
+
+// NumPages returns the number of pages
+func (d *Document) NumPages() int {
+	return len(d.Pages())
+}
+
+// Pages returns the page dictionaries in order; inheritable attributes like Resources are copied into each page
+func (d *Document) Pages() []Dict {
+	if d.pages != nil {
+		return d.pages
+	}
+	d.pages = make([]Dict, 0)
+	root, _ := d.Resolve(d.Catalog()["Pages"]).(Dict)
+	d.collectPages(root, Dict{}, make(map[int]bool), 0)
+	return d.pages
+}
+
+func (d *Document) collectPages(node Dict, inherited Dict, seen map[int]bool, depth int) {
+	if node == nil || depth > 64 {
+		return
+	}
+
+	attrs := Dict{}
+	for k, v := range inherited {
+		attrs[k] = v
+	}
+	for _, k := range []Name{"Resources", "MediaBox", "CropBox", "Rotate"} {
+		if v, ok := node[k]; ok {
+			attrs[k] = v
+		}
+	}
+
+	kids, isTree := d.Resolve(node["Kids"]).(Array)
+	if node["Type"] == Name("Page") || !isTree {
+		page := Dict{}
+		for k, v := range node {
+			page[k] = v
+		}
+		for k, v := range attrs {
+			if _, ok := page[k]; !ok {
+				page[k] = v
+			}
+		}
+		d.pages = append(d.pages, page)
+		return
+	}
+
+	for _, kid := range kids {
+		// broken files may have loops in the page tree
+		if ref, ok := kid.(Ref); ok {
+			if seen[ref.Num] {
+				continue
+			}
+			seen[ref.Num] = true
+		}
+		if kidDict, ok := d.Resolve(kid).(Dict); ok {
+			d.collectPages(kidDict, attrs, seen, depth+1)
+		}
+	}
+}
+
+// Text returns the text of all pages, separated by form feeds like pdftotext does
+func (d *Document) Text() (string, error) {
+	pages := make([]string, 0, d.NumPages())
+	for i := 1; i <= d.NumPages(); i++ {
+		text, err := d.PageText(i)
+		if err != nil {
+			return "", err
+		}
+		pages = append(pages, text)
+	}
+	return strings.Join(pages, "\n\f"), nil
+}
+
+// PageText returns the text of the given page; pages are counted from 1
+func (d *Document) PageText(page int) (string, error) {
+	if d.Encrypted() {
+		return "", ErrEncrypted
+	}
+	pages := d.Pages()
+	if page < 1 || page > len(pages) {
+		return "", fmt.Errorf("page %v out of range, document has %v pages", page, len(pages))
+	}
+	p := pages[page-1]
+
+	content, err := d.pageContent(p)
+	if err != nil {
+		return "", err
+	}
+	resources, _ := d.Resolve(p["Resources"]).(Dict)
+
+	e := &textExtractor{doc: d, fonts: make(map[Name]*font)}
+	e.run(content, resources, 0)
+	return strings.TrimSpace(e.out.String()), nil
+}
+
+// pageContent returns the decoded content streams of a page concatenated
+func (d *Document) pageContent(page Dict) ([]byte, error) {
+	var streams []Object
+	switch c := d.Resolve(page["Contents"]).(type) {
+	case *Stream:
+		streams = []Object{c}
+	case Array:
+		streams = c
+	}
+
+	content := make([]byte, 0)
+	for _, s := range streams {
+		stream, ok := d.Resolve(s).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := d.Decode(stream)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode page content: %s", err)
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+	return content, nil
+}
+
+// textExtractor interprets the text operators of content streams
+type textExtractor struct {
+	doc   *Document
+	fonts map[Name]*font
+	font  *font
+	out   strings.Builder
+
+	// current line and position as far as needed to find line breaks and word gaps
+	lineY    float64
+	lineX    float64
+	hasLine  bool
+	fontSize float64
+}
+
+func (e *textExtractor) write(s string) {
+	e.out.WriteString(s)
+}
+
+func (e *textExtractor) space() {
+	s := e.out.String()
+	if len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		e.out.WriteByte(' ')
+	}
+}
+
+func (e *textExtractor) newline() {
+	s := e.out.String()
+	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+		e.out.WriteByte('\n')
+	}
+}
+
+// moveTo handles positioning; a change of the baseline is a new line, a jump to the right a new word
+func (e *textExtractor) moveTo(x, y float64) {
+	tolerance := math.Max(e.fontSize, 1) * 0.5
+	if e.hasLine && math.Abs(y-e.lineY) > tolerance {
+		e.newline()
+	} else if e.hasLine && x > e.lineX {
+		e.space()
+	}
+	e.lineX, e.lineY, e.hasLine = x, y, true
+}
+
+func (e *textExtractor) showString(s String) {
+	if e.font == nil {
+		e.write(string(s))
+		return
+	}
+	e.write(e.font.decode(s))
+}
+
+func (e *textExtractor) run(content []byte, resources Dict, depth int) {
+	l := &lexer{data: content}
+	operands := make([]Object, 0, 8)
+
+	// text line matrix; only the translation is tracked
+	var tx, ty, leading float64
+
+	for {
+		l.skipSpace()
+		if l.eof() {
+			return
+		}
+		obj, err := l.readObject()
+		if err != nil {
+			return
+		}
+		op, isOp := obj.(keyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+
+		num := func(i int) float64 {
+			if i < len(operands) {
+				v, _ := number(operands[i])
+				return v
+			}
+			return 0
+		}
+
+		switch op {
+		case "BI":
+			// skip inline image data
+			skipInlineImage(l)
+		case "BT":
+			tx, ty = 0, 0
+		case "ET":
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(Name); ok {
+					e.font = e.loadFont(resources, name)
+				}
+				e.fontSize = math.Abs(num(1))
+			}
+		case "TL":
+			leading = num(0)
+		case "Td":
+			tx, ty = tx+num(0), ty+num(1)
+			e.moveTo(tx, ty)
+		case "TD":
+			leading = -num(1)
+			tx, ty = tx+num(0), ty+num(1)
+			e.moveTo(tx, ty)
+		case "Tm":
+			if len(operands) >= 6 {
+				tx, ty = num(4), num(5)
+				if scale := math.Abs(num(3)); scale > 0 && e.fontSize > 0 {
+					// font size is often 1 and scaled by the matrix
+					e.fontSize = math.Max(e.fontSize, scale)
+				}
+				e.moveTo(tx, ty)
+			}
+		case "T*":
+			ty -= leading
+			e.newline()
+			e.lineX, e.lineY = tx, ty
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(String); ok {
+					e.showString(s)
+				}
+			}
+		case "'", "\"":
+			ty -= leading
+			e.newline()
+			e.lineX, e.lineY = tx, ty
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(String); ok {
+					e.showString(s)
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if arr, ok := operands[len(operands)-1].(Array); ok {
+					for _, item := range arr {
+						switch v := item.(type) {
+						case String:
+							e.showString(v)
+						default:
+							if gap, ok := number(v); ok && -gap > wordGapThreshold {
+								e.space()
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) > 0 && depth < maxFormDepth {
+				if name, ok := operands[0].(Name); ok {
+					e.runForm(resources, name, depth)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// runForm extracts the text of a form xobject
+func (e *textExtractor) runForm(resources Dict, name Name, depth int) {
+	xobjects, _ := e.doc.Resolve(resources["XObject"]).(Dict)
+	form, ok := e.doc.Resolve(xobjects[name]).(*Stream)
+	if !ok || form.Dict["Subtype"] != Name("Form") {
+		return
+	}
+	data, err := e.doc.Decode(form)
+	if err != nil {
+		return
+	}
+	formResources, ok := e.doc.Resolve(form.Dict["Resources"]).(Dict)
+	if !ok {
+		formResources = resources
+	}
+
+	// fonts of forms are looked up in their own resources
+	saved, savedFont := e.fonts, e.font
+	e.fonts = make(map[Name]*font)
+	e.run(data, formResources, depth+1)
+	e.fonts, e.font = saved, savedFont
+}
+
+func (e *textExtractor) loadFont(resources Dict, name Name) *font {
+	if f, ok := e.fonts[name]; ok {
+		return f
+	}
+	fonts, _ := e.doc.Resolve(resources["Font"]).(Dict)
+	dict, _ := e.doc.Resolve(fonts[name]).(Dict)
+	f := e.doc.newFont(dict)
+	e.fonts[name] = f
+	return f
+}
+
+// skipInlineImage moves the lexer behind the EI of an inline image
+func skipInlineImage(l *lexer) {
+	// the image dictionary runs until ID
+	for !l.eof() {
+		obj, err := l.readObject()
+		if err != nil {
+			return
+		}
+		if obj == keyword("ID") {
+			break
+		}
+	}
+	l.pos++
+	for l.pos+2 <= len(l.data) {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' &&
+			l.pos > 0 && isSpace(l.data[l.pos-1]) &&
+			(l.pos+2 == len(l.data) || isSpace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
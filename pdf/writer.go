@@ -0,0 +1,344 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var pdfaPartMatch = regexp.MustCompile(`pdfaid:part(?:="|>)(\d)`)
+var pdfaConformanceMatch = regexp.MustCompile(`pdfaid:conformance(?:="|>)([A-Za-z])`)
+
+// Metadata is what UpdateMetadata writes into a pdf
+type Metadata struct {
+	Title    string
+	Subject  string
+	Keywords []string
+	// date of the document; written as creation date unless the pdf has one already, not written if zero
+	Date time.Time
+}
+
+// UpdateMetadata appends an incremental update to the pdf which sets title, subject, keywords and, if missing,
+// the creation date in the document information dictionary and in the xmp metadata; the original bytes stay untouched
+// the result is parsed again to make sure it is valid
+func UpdateMetadata(data []byte, meta Metadata) ([]byte, error) {
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Encrypted() {
+		return nil, ErrEncrypted
+	}
+	if doc.reconstructed {
+		// an update needs a valid previous cross reference section
+		return nil, fmt.Errorf("cross reference of pdf is damaged")
+	}
+	rootRef, ok := doc.trailer["Root"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("document catalog is no indirect object")
+	}
+
+	now := time.Now()
+	keywords := strings.Join(meta.Keywords, ", ")
+
+	// information dictionary; keep what is there already
+	info := Dict{}
+	if old, ok := doc.Resolve(doc.trailer["Info"]).(Dict); ok {
+		for k, v := range old {
+			info[k] = v
+		}
+	}
+	info["Title"] = textString(meta.Title)
+	info["Subject"] = textString(meta.Subject)
+	if keywords != "" {
+		info["Keywords"] = textString(keywords)
+	}
+	info["ModDate"] = String(pdfDate(now))
+	// the creation date belongs to the document, it is never replaced
+	created, _ := ParseDate(doc.Info()["CreationDate"])
+	if _, ok := info["CreationDate"]; !ok && !meta.Date.IsZero() {
+		created = meta.Date
+		info["CreationDate"] = String(pdfDate(created))
+	}
+
+	// pdf/a files have to keep their identification in the new xmp packet
+	pdfaPart, pdfaConformance := "", ""
+	catalog := doc.Catalog()
+	if old, ok := doc.Resolve(catalog["Metadata"]).(*Stream); ok {
+		if xmp, err := doc.Decode(old); err == nil {
+			if m := pdfaPartMatch.FindSubmatch(xmp); m != nil {
+				pdfaPart = string(m[1])
+			}
+			if m := pdfaConformanceMatch.FindSubmatch(xmp); m != nil {
+				pdfaConformance = string(m[1])
+			}
+		}
+	}
+
+	xmp := xmpPacket(meta, keywords, created, now, pdfaPart, pdfaConformance)
+
+	infoNum := doc.MaxObjectNumber() + 1
+	xmpNum := infoNum + 1
+
+	newCatalog := Dict{}
+	for k, v := range catalog {
+		newCatalog[k] = v
+	}
+	newCatalog["Metadata"] = Ref{Num: xmpNum}
+
+	objects := map[int]Object{
+		rootRef.Num: newCatalog,
+		infoNum:     info,
+		xmpNum:      &Stream{Dict: Dict{"Type": Name("Metadata"), "Subtype": Name("XML")}, Raw: xmp},
+	}
+	gens := map[int]int{rootRef.Num: rootRef.Gen}
+
+	trailer := Dict{
+		"Root": rootRef,
+		"Info": Ref{Num: infoNum},
+		"Size": int64(xmpNum + 1),
+		"Prev": doc.startXref,
+	}
+	if id, ok := doc.trailer["ID"]; ok {
+		trailer["ID"] = id
+	}
+
+	out := appendUpdate(data, objects, gens, trailer, doc.usesXrefStream())
+
+	// make sure the result can be read again
+	check, err := Parse(out)
+	if err != nil {
+		return nil, fmt.Errorf("updated pdf is invalid: %s", err)
+	}
+	if check.Info()["Title"] != meta.Title || check.NumPages() != doc.NumPages() {
+		return nil, fmt.Errorf("updated pdf does not contain the new metadata")
+	}
+
+	return out, nil
+}
+
+// usesXrefStream reports whether the newest cross reference section is a stream
+func (d *Document) usesXrefStream() bool {
+	l := &lexer{data: d.data, pos: int(d.startXref)}
+	l.skipSpace()
+	return !bytes.HasPrefix(d.data[l.pos:], []byte("xref"))
+}
+
+// appendUpdate writes the given objects and a new cross reference section behind the original data
+func appendUpdate(data []byte, objects map[int]Object, gens map[int]int, trailer Dict, xrefStream bool) []byte {
+	var buf bytes.Buffer
+	buf.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	offsets := make(map[int]int)
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%v %v obj\n", num, gens[num])
+		writeObject(&buf, objects[num])
+		buf.WriteString("\nendobj\n")
+	}
+
+	xrefOffset := buf.Len()
+	if xrefStream {
+		// the cross reference stream is an object itself and lists itself
+		xrefNum := 0
+		for _, num := range nums {
+			if num >= xrefNum {
+				xrefNum = num + 1
+			}
+		}
+		if size, ok := trailer["Size"].(int64); ok && int(size) > xrefNum {
+			xrefNum = int(size)
+		}
+		offsets[xrefNum] = xrefOffset
+		nums = append(nums, xrefNum)
+
+		var rows bytes.Buffer
+		index := Array{}
+		for _, num := range nums {
+			index = append(index, int64(num), int64(1))
+			rows.WriteByte(1)
+			binary.Write(&rows, binary.BigEndian, uint32(offsets[num]))
+			binary.Write(&rows, binary.BigEndian, uint16(gens[num]))
+		}
+
+		dict := Dict{}
+		for k, v := range trailer {
+			dict[k] = v
+		}
+		dict["Type"] = Name("XRef")
+		dict["Size"] = int64(xrefNum + 1)
+		dict["W"] = Array{int64(1), int64(4), int64(2)}
+		dict["Index"] = index
+
+		fmt.Fprintf(&buf, "%v 0 obj\n", xrefNum)
+		writeObject(&buf, &Stream{Dict: dict, Raw: rows.Bytes()})
+		buf.WriteString("\nendobj\n")
+	} else {
+		buf.WriteString("xref\n")
+		for _, num := range nums {
+			fmt.Fprintf(&buf, "%v 1\n%010d %05d n \n", num, offsets[num], gens[num])
+		}
+		buf.WriteString("trailer\n")
+		writeObject(&buf, trailer)
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(&buf, "startxref\n%v\n%%%%EOF\n", xrefOffset)
+
+	return buf.Bytes()
+}
+
+// writeObject serializes an object
+func writeObject(buf *bytes.Buffer, obj Object) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		fmt.Fprint(buf, v)
+	case int64:
+		fmt.Fprint(buf, v)
+	case float64:
+		buf.WriteString(strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.5f", v), "0"), "."))
+	case Name:
+		buf.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c <= ' ' || c > '~' || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(buf, "#%02x", c)
+				continue
+			}
+			buf.WriteByte(c)
+		}
+	case String:
+		fmt.Fprintf(buf, "<%x>", []byte(v))
+	case Ref:
+		fmt.Fprintf(buf, "%v %v R", v.Num, v.Gen)
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writeObject(buf, Name(k))
+			buf.WriteByte(' ')
+			writeObject(buf, v[Name(k)])
+		}
+		buf.WriteString(">>")
+	case *Stream:
+		dict := Dict{}
+		for k, val := range v.Dict {
+			dict[k] = val
+		}
+		dict["Length"] = int64(len(v.Raw))
+		writeObject(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.Raw)
+		buf.WriteString("\nendstream")
+	}
+}
+
+// textString encodes a string as pdf text string; ascii as is, everything else as UTF-16 with byte order mark
+func textString(s string) String {
+	ascii := true
+	for _, r := range s {
+		if r > 0x7e || (r < 0x20 && r != '\n' && r != '\t') {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return String(s)
+	}
+	out := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return String(out)
+}
+
+// pdfDate formats a time as pdf date string
+func pdfDate(t time.Time) string {
+	// the minutes of the offset can not be put into the layout, the 00 between the quotes would be taken literally
+	zone := t.Format("-07'") + t.Format("-0700")[3:] + "'"
+	if zone == "+00'00'" {
+		zone = "Z"
+	}
+	return "D:" + t.Format("20060102150405") + zone
+}
+
+// ParseDate parses a pdf date string like D:20261018120000+02'00'
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	s = strings.Replace(s, "'", "", -1)
+	for _, layout := range []string{"20060102150405-0700", "20060102150405Z", "20060102150405", "200601021504", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid pdf date %q", s)
+}
+
+// xmpPacket creates the xmp metadata which mirrors the information dictionary
+func xmpPacket(meta Metadata, keywords string, created, modified time.Time, pdfaPart, pdfaConformance string) []byte {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"")
+	if pdfaPart != "" {
+		b.WriteString(" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\"")
+	}
+	b.WriteString(">\n")
+	fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(meta.Title))
+	fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", esc(meta.Subject))
+	if len(meta.Keywords) > 0 {
+		b.WriteString("<dc:subject><rdf:Bag>")
+		for _, k := range meta.Keywords {
+			fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", esc(k))
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	if keywords != "" {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", esc(keywords))
+	}
+	if !created.IsZero() {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", created.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n", modified.Format(time.RFC3339))
+	fmt.Fprintf(&b, "<xmp:MetadataDate>%s</xmp:MetadataDate>\n", modified.Format(time.RFC3339))
+	if pdfaPart != "" {
+		fmt.Fprintf(&b, "<pdfaid:part>%s</pdfaid:part>\n", pdfaPart)
+		if pdfaConformance != "" {
+			fmt.Fprintf(&b, "<pdfaid:conformance>%s</pdfaid:conformance>\n", pdfaConformance)
+		}
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	// padding allows in place edits by other tools
+	b.WriteString(strings.Repeat(strings.Repeat(" ", 99)+"\n", 20))
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return []byte(b.String())
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zmnpl/ding/bubl"
+	"github.com/zmnpl/ding/core"
+)
+
+func runRetention(args []string) error {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the report as json")
+	interactive := flags.Bool("interactive", false, "Review the expired documents and move them to the trash")
+	flags.Parse(args)
+
+	expired, err := core.FindExpiredDocuments(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if *interactive {
+		return bubl.RunRetention(expired)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(expired)
+	}
+
+	if len(expired) == 0 {
+		fmt.Println("No documents past their retention period.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tDATED\tEXPIRED\tRULE")
+	for _, e := range expired {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Path, e.Start.Format("2006-01-02"), e.Expired.Format("2006-01-02"), e.Rule)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runScan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	profile := flags.String("profile", "", "Scan profile from the config; the default profile if empty")
+	ocr := flags.Bool("ocr", false, "Run ocr on the scan, even if the profile does not")
+	list := flags.Bool("list", false, "List the configured scan profiles")
+	asJSON := flags.Bool("json", false, "Print the result as json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding scan [flags]\n\nScans with scanimage and puts the pages as a pdf into the inbound directory.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tDEVICE\tRESOLUTION\tMODE\tSOURCE\tBATCH\tOCR")
+		for _, name := range core.ScanProfileNames() {
+			p := core.Conf.Scan.Profiles[name]
+			if name == core.Conf.Scan.Default {
+				name += " (default)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%v\t%v\n", name, p.Device, p.Resolution, p.Mode, p.Source, p.Batch, p.OCR)
+		}
+		return w.Flush()
+	}
+
+	p, err := core.GetScanProfile(*profile)
+	if err != nil {
+		return err
+	}
+	if *ocr {
+		p.OCR = true
+	}
+
+	if !*asJSON {
+		fmt.Fprintln(os.Stderr, "scanning ...")
+	}
+	result, err := core.Scan(p)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	fmt.Printf("scanned %v page(s) into %s\n", result.Pages, result.Name)
+	if result.OCRError != "" {
+		fmt.Printf("ocr failed: %s\n", result.OCRError)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runServe(args []string) error {
+	conf := core.Conf.Upload
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", conf.Listen, "Address to listen on; 127.0.0.1:8765 if empty. Use the address in your lan to let phones upload")
+	// the configured token is not the default, so it does not show up in the usage
+	token := flags.String("token", "", "Token every upload has to bring along; taken from the config or DING_UPLOAD_TOKEN if empty, made up if none is set")
+	maxSize := flags.Int64("max-size", conf.MaxSize, "Largest upload in MiB; 25 if not set")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding serve [flags]\n\nServes an upload form at / and takes pdfs and images at POST /inbox into the inbound directory,\ne.g. curl -H 'Authorization: Bearer TOKEN' -F file=@letter.jpg http://HOST/inbox\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	conf.Listen, conf.MaxSize = *listen, *maxSize
+	if *token != "" {
+		conf.Token = *token
+	}
+	if conf.Token == "" {
+		conf.Token = os.Getenv("DING_UPLOAD_TOKEN")
+	}
+	if conf.Token == "" {
+		generated, err := core.NewUploadToken()
+		if err != nil {
+			return err
+		}
+		conf.Token = generated
+	}
+
+	server, err := core.NewUploadServer(conf)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %s", server.Addr, err)
+	}
+
+	fmt.Printf("accepting uploads into %s at http://%s/?token=%s\n", core.Inbound, listener.Addr(), conf.Token)
+	return server.Serve(listener)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runStats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the statistics as json")
+	largest := flags.Int("largest", 10, "Number of largest files to list")
+	flags.Parse(args)
+	if *largest < 0 {
+		return fmt.Errorf("-largest must not be negative")
+	}
+
+	stats, err := core.GetStats(*largest)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Documents\t%v\t%s\n", stats.Documents, core.FormatSize(stats.Size))
+	fmt.Fprintf(w, "Without text layer\t%v\t%.0f%%\n", stats.WithoutText, stats.WithoutTextShare()*100)
+	backlog := "-"
+	if stats.InboundFiles > 0 {
+		backlog = "oldest waits " + core.FormatAge(stats.InboundAge())
+	}
+	fmt.Fprintf(w, "Inbound backlog\t%v\t%s\n", stats.InboundFiles, backlog)
+
+	fmt.Fprintf(w, "\nDIRECTORY\tDOCUMENTS\tSIZE\n")
+	for _, d := range stats.Directories {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", d.Name, d.Documents, core.FormatSize(d.Size))
+	}
+
+	fmt.Fprintf(w, "\nMONTH\tINGESTS\t\n")
+	max := 0
+	for _, m := range stats.IngestsPerMonth {
+		if m.Documents > max {
+			max = m.Documents
+		}
+	}
+	for _, m := range stats.IngestsPerMonth {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", m.Month, m.Documents, strings.Repeat("#", m.Documents*30/max))
+	}
+
+	fmt.Fprintf(w, "\nLARGEST\tSIZE\t\n")
+	for _, f := range stats.Largest {
+		fmt.Fprintf(w, "%s\t%s\t\n", f.Path, core.FormatSize(f.Size))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,219 @@
+// Package termimg renders images for the terminal; either as unicode half blocks which work everywhere
+// or with the kitty and sixel graphics protocols for terminals which support them
+package termimg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+)
+
+const (
+	PROTOCOL_NONE  = "none"
+	PROTOCOL_KITTY = "kitty"
+	PROTOCOL_SIXEL = "sixel"
+
+	// chunk size of base64 payload per kitty escape sequence
+	kittyChunkSize = 4096
+
+	// KITTY_CLEAR removes all images shown with the kitty graphics protocol
+	KITTY_CLEAR = "\x1b_Ga=d\x1b\\"
+)
+
+// Protocol guesses which graphics protocol the terminal supports from the environment
+// the guess can be overruled by setting DING_GRAPHICS to kitty, sixel or none
+func Protocol() string {
+	switch strings.ToLower(os.Getenv("DING_GRAPHICS")) {
+	case PROTOCOL_KITTY:
+		return PROTOCOL_KITTY
+	case PROTOCOL_SIXEL:
+		return PROTOCOL_SIXEL
+	case PROTOCOL_NONE:
+		return PROTOCOL_NONE
+	}
+
+	term := os.Getenv("TERM")
+	if os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || os.Getenv("TERM_PROGRAM") == "WezTerm" {
+		return PROTOCOL_KITTY
+	}
+	if strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || term == "mlterm" || term == "yaft-256color" {
+		return PROTOCOL_SIXEL
+	}
+	return PROTOCOL_NONE
+}
+
+// Scale resizes the image to the given size by averaging the pixels which fall into each target pixel
+func Scale(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	if b.Dx() == 0 || b.Dy() == 0 || width == 0 || height == 0 {
+		return out
+	}
+
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, _ := img.At(sx, sy).RGBA()
+					r, g, bl, n = r+pr, g+pg, bl+pb, n+1
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), 0xff})
+		}
+	}
+
+	return out
+}
+
+// HalfBlocks scales the image to the given number of terminal columns and calls cell for every character cell
+// every cell shows two pixels; the upper one as foreground of "▀" and the lower one as background
+// the returned lines can directly be joined with line breaks
+func HalfBlocks(img image.Image, columns int, cell func(top, bottom color.RGBA) string) []string {
+	b := img.Bounds()
+	if b.Dx() == 0 || columns <= 0 {
+		return nil
+	}
+	// terminal cells are about twice as high as wide, so every cell row holds two pixel rows
+	rows := b.Dy() * columns / b.Dx()
+	if rows%2 == 1 {
+		rows++
+	}
+	scaled := Scale(img, columns, rows)
+
+	lines := make([]string, 0, rows/2)
+	for y := 0; y < rows; y += 2 {
+		var line strings.Builder
+		for x := 0; x < columns; x++ {
+			line.WriteString(cell(scaled.RGBAAt(x, y), scaled.RGBAAt(x, y+1)))
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// Encode encodes the image only for the given protocol
+func Encode(img image.Image, protocol string) (string, error) {
+	switch protocol {
+	case PROTOCOL_KITTY:
+		return Kitty(img)
+	case PROTOCOL_SIXEL:
+		return Sixel(img), nil
+	}
+	return "", fmt.Errorf("no graphics protocol to show the image with")
+}
+
+// Kitty encodes the image as escape sequence of the kitty graphics protocol
+func Kitty(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("could not encode image: %s", err)
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var out strings.Builder
+	for i := 0; i < len(payload); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(payload) {
+			end = len(payload)
+			more = 0
+		}
+		if i == 0 {
+			fmt.Fprintf(&out, "\x1b_Gf=100,a=T,m=%v;%s\x1b\\", more, payload[i:end])
+		} else {
+			fmt.Fprintf(&out, "\x1b_Gm=%v;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	return out.String(), nil
+}
+
+// Sixel encodes the image as sixel escape sequence; colors are reduced to a fixed 216 color palette
+func Sixel(img image.Image) string {
+	b := img.Bounds()
+	pal := palette.WebSafe
+	indexed := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), pal)
+	draw.FloydSteinberg.Draw(indexed, indexed.Bounds(), img, b.Min)
+
+	var out strings.Builder
+	out.WriteString("\x1bPq")
+	fmt.Fprintf(&out, "\"1;1;%v;%v", b.Dx(), b.Dy())
+	for i, c := range pal {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&out, "#%v;2;%v;%v;%v", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	width, height := b.Dx(), b.Dy()
+	for band := 0; band < height; band += 6 {
+		// collect which colors are used in this band of six pixel rows
+		used := make(map[uint8]bool)
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[indexed.ColorIndexAt(x, y)] = true
+			}
+		}
+
+		first := true
+		for ci := 0; ci < len(pal); ci++ {
+			if !used[uint8(ci)] {
+				continue
+			}
+			if !first {
+				out.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&out, "#%v", ci)
+
+			// run length encoded sixel characters
+			var last byte
+			run := 0
+			flush := func() {
+				switch {
+				case run == 0:
+				case run > 3:
+					fmt.Fprintf(&out, "!%v%c", run, last)
+				default:
+					out.WriteString(strings.Repeat(string(last), run))
+				}
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && band+dy < height; dy++ {
+					if indexed.ColorIndexAt(x, band+dy) == uint8(ci) {
+						bits |= 1 << uint(dy)
+					}
+				}
+				c := 63 + bits
+				if run > 0 && c == last {
+					run++
+					continue
+				}
+				flush()
+				last, run = c, 1
+			}
+			flush()
+		}
+		out.WriteByte('-')
+	}
+
+	out.WriteString("\x1b\\")
+	return out.String()
+}
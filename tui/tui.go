@@ -1,17 +1,21 @@
 package tui
 
 import (
+	"bufio"
 	"fmt"
+	"image/color"
 	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/zmnpl/ding/core"
+	"github.com/zmnpl/ding/termimg"
 )
 
 // TODO
@@ -32,7 +36,8 @@ var (
 	borderColor      = tcell.ColorGrey
 	borderTitleColor = tcell.ColorGrey
 
-	app *tview.Application
+	app   *tview.Application
+	pages *tview.Pages
 
 	contextKeyMap             *tview.TextView
 	mainfunctionKeyMap        *tview.TextView
@@ -40,7 +45,12 @@ var (
 	deactivatedKeymapTemplate = deactivatedColorString + "%s %s"
 	keymapSep                 = "[white] • "
 
+	tagInputWidth = 40
+
 	documentView   *tview.TextView
+	previewPage    = 1
+	previewLayout  = false
+	previewImage   = false
 	fileListHeader *tview.TextView
 	fileList       *tview.List
 
@@ -51,19 +61,61 @@ var (
 	newNameFlex                   *tview.Flex
 	newNamePrefixInput            *tview.InputField
 	newNameInput                  *tview.InputField
+	tagInput                      *tview.InputField
 	autocompleteSelectedDirectory func(pathText string) (entries []string)
 
 	statusLine *tview.TextView
+
+	// keys which need external tools
+	gatedKeys = map[tcell.Key]string{
+		tcell.KeyF1:  core.FEATURE_OPEN,
+		tcell.KeyF5:  core.FEATURE_OCR,
+		tcell.KeyF6:  core.FEATURE_THUMBNAIL,
+		tcell.KeyF7:  core.FEATURE_THUMBNAIL,
+		tcell.KeyF9:  core.FEATURE_OPTIMIZE,
+		tcell.KeyF10: core.FEATURE_UNPROTECT,
+	}
 )
 
+// featureKeymap greys out keys whose tools are missing
+func featureKeymap(key, description, feature string) string {
+	if !core.FeatureAvailable(feature) {
+		return fmt.Sprintf(deactivatedKeymapTemplate, key, description)
+	}
+	return fmt.Sprintf(keymapTemplate, key, description)
+}
+
+// keyIsGated tells what to install if the key needs tools which are missing
+func keyIsGated(k tcell.Key) bool {
+	feature, ok := gatedKeys[k]
+	if !ok || core.FeatureAvailable(feature) {
+		return false
+	}
+	statusLine.SetText(core.MissingFeatureHint(feature))
+	return true
+}
+
 func reset() {
 	app.SetFocus(fileList)
 
 	newNameInput.SetText("")
 	newNamePrefixInput.SetText("")
+	tagInput.SetText("")
 
 	newNameFlex.Clear()
-	newNameFlex.AddItem(newNamePrefixInput, 0, 0, false).AddItem(newNameInput, 0, 1, false)
+	newNameFlex.AddItem(newNamePrefixInput, 0, 0, false).AddItem(newNameInput, 0, 1, false).AddItem(tagInput, tagInputWidth, 0, false)
+}
+
+// autocompleteTags completes the last tag of the text with tags which are in use already
+func autocompleteTags(text string) (entries []string) {
+	start := strings.LastIndexAny(text, ", ") + 1
+	if text[start:] == "" {
+		return nil
+	}
+	for _, tag := range core.CompleteTag(text[start:]) {
+		entries = append(entries, text[:start]+tag)
+	}
+	return entries
 }
 
 var autocompleteDirectoryMaker = func(path *tview.InputField, extensionFilter map[string]bool) func(pathText string) (entries []string) {
@@ -139,6 +191,8 @@ var autocompleteDirectoryMaker = func(path *tview.InputField, extensionFilter ma
 }
 
 func Start() {
+	core.CheckFeatures()
+
 	contextKeyMap = tview.NewTextView().
 		SetDynamicColors(true).
 		SetRegions(true).
@@ -151,7 +205,7 @@ func Start() {
 
 	mft := fmt.Sprintf(keymapTemplate, "ctrl+c", "exit") +
 		keymapSep +
-		fmt.Sprintf(keymapTemplate, "f5", "ocr inbound")
+		featureKeymap("f5", "ocr inbound", core.FEATURE_OCR)
 	mainfunctionKeyMap.SetText(mft)
 
 	documentView = tview.NewTextView().
@@ -169,6 +223,7 @@ func Start() {
 
 	newNamePrefixInput = tview.NewInputField()
 	newNameInput = tview.NewInputField().SetPlaceholder("type new name")
+	tagInput = tview.NewInputField().SetLabel("tags ").SetPlaceholder("tax-2026, car")
 	directoryFileList = tview.NewList().SetSelectedFocusOnly(true)
 
 	statusLine = tview.NewTextView().
@@ -179,6 +234,7 @@ func Start() {
 	setupInboundFileList()
 	setupDirectoryList()
 	setupNewName()
+	setupTags()
 
 	// set up main grid layout
 	layout := tview.NewGrid()
@@ -218,7 +274,7 @@ func Start() {
 	layout.AddItem(bh, 1, 2, 1, 2, 0, infoPanelThreshold, false)
 	layout.AddItem(directoryList, 2, 2, 1, 1, 0, infoPanelThreshold, false)
 
-	newNameFlex = tview.NewFlex().AddItem(newNamePrefixInput, 0, 0, false).AddItem(newNameInput, 0, 1, false)
+	newNameFlex = tview.NewFlex().AddItem(newNamePrefixInput, 0, 0, false).AddItem(newNameInput, 0, 1, false).AddItem(tagInput, tagInputWidth, 0, false)
 	layout.AddItem(newNameFlex, 3, 0, 1, 4, 0, 0, false)
 	layout.AddItem(newNameFlex, 3, 1, 1, 2, 0, infoPanelThreshold, false)
 	// fill empty cells with dummy to draw background
@@ -242,13 +298,26 @@ func Start() {
 		// 	return nil
 		// }
 		if event.Key() == tcell.KeyF5 {
+			if keyIsGated(event.Key()) {
+				return nil
+			}
 			runOcr()
 			return nil
 		}
 
 		return event
 	})
-	app.SetRoot(layout, true)
+	pages = tview.NewPages().AddPage("main", layout, true, true)
+	app.SetRoot(pages, true)
+
+	// mark inbound files which are already archived as soon as the archive is hashed
+	go func() {
+		if err := core.WarmHashIndex(); err == nil {
+			app.QueueUpdateDraw(markArchivedInboundFiles)
+		}
+	}()
+	go watchInbound()
+
 	if err := app.Run(); err != nil {
 		panic(err)
 	}
@@ -264,7 +333,11 @@ func runOcr() {
 	progress := make(chan float32)
 	currentfile := make(chan string)
 	o, _ := core.GetOcrInboundFunc(progress, currentfile)
-	go o()
+	go func() {
+		o()
+		// images have been turned into pdfs
+		app.QueueUpdateDraw(setupInboundFileList)
+	}()
 
 	go func() {
 		for p := range progress {
@@ -282,7 +355,19 @@ func setupInboundFileList() {
 			keymapSep +
 			fmt.Sprintf(keymapTemplate, "enter", "select") +
 			keymapSep +
-			fmt.Sprintf(keymapTemplate, "f1", "open in external viewer")
+			featureKeymap("f1", "open in external viewer", core.FEATURE_OPEN) +
+			keymapSep +
+			fmt.Sprintf(keymapTemplate, "[ ]", "turn page") +
+			keymapSep +
+			fmt.Sprintf(keymapTemplate, "f4", "raw/layout") +
+			keymapSep +
+			featureKeymap("f6", "thumbnail", core.FEATURE_THUMBNAIL) +
+			keymapSep +
+			featureKeymap("f7", "full screen graphic", core.FEATURE_THUMBNAIL) +
+			keymapSep +
+			featureKeymap("f9", "optimize", core.FEATURE_OPTIMIZE) +
+			keymapSep +
+			featureKeymap("f10", "password", core.FEATURE_UNPROTECT)
 
 		contextKeyMap.SetText(text)
 	})
@@ -298,21 +383,82 @@ func setupInboundFileList() {
 
 	for i, f := range inboundFiles {
 		if i == 0 {
-			populateDocPreview(core.GetCachedDocPreview(f.Name()))
+			previewPage, previewLayout, previewImage = 1, false, false
+			populateDocPreview(previewHeader(f.Name()) + core.GetCachedDocPreview(f.Name()))
 		}
-		inf, _ := f.Info()
-		sizeMiBs := math.Round(float64(inf.Size())*100/1048576) / 100
-		fileList.AddItem(f.Name(), fmt.Sprintf("%v MiB", sizeMiBs), 0, func() {
+		fileList.AddItem(f.Name(), inboundFileDescription(f), 0, func() {
 			app.SetFocus(directoryList)
 		})
 	}
 
 	fileList.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
-		populateDocPreview(core.GetCachedDocPreview(mainText))
+		previewPage, previewLayout = 1, false
+		if previewImage {
+			showThumbnail(mainText)
+			return
+		}
+		populateDocPreview(previewHeader(mainText) + core.GetCachedDocPreview(mainText))
 	})
 
 	fileList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		k := event.Key()
+		if k == tcell.KeyRune && (event.Rune() == '[' || event.Rune() == ']') {
+			page := previewPage + 1
+			if event.Rune() == '[' {
+				page = previewPage - 1
+			}
+			turnPreviewPage(page, previewLayout)
+			return nil
+		}
+		if k == tcell.KeyF4 {
+			turnPreviewPage(previewPage, !previewLayout)
+			return nil
+		}
+		if keyIsGated(k) {
+			return nil
+		}
+		if k == tcell.KeyF6 {
+			previewImage = !previewImage
+			name, _ := fileList.GetItemText(fileList.GetCurrentItem())
+			if previewImage {
+				showThumbnail(name)
+			} else {
+				turnPreviewPage(1, false)
+			}
+			return nil
+		}
+		if k == tcell.KeyF7 {
+			name, _ := fileList.GetItemText(fileList.GetCurrentItem())
+			showGraphic(name)
+			return nil
+		}
+		if k == tcell.KeyF10 {
+			name, _ := fileList.GetItemText(fileList.GetCurrentItem())
+			unprotectPdf(name)
+			return nil
+		}
+		if k == tcell.KeyF9 {
+			index := fileList.GetCurrentItem()
+			name, _ := fileList.GetItemText(index)
+			statusLine.SetText(fmt.Sprintf("optimizing %s ...", name))
+			go func() {
+				before, after, err := core.OptimizePdf(name)
+				app.QueueUpdateDraw(func() {
+					switch {
+					case err != nil:
+						statusLine.SetText(fmt.Sprintf("[red]%s", err))
+					case after == before:
+						statusLine.SetText(fmt.Sprintf("%s is already as small as it gets (%s)", name, core.FormatSize(before)))
+					default:
+						statusLine.SetText(fmt.Sprintf("optimized %s: %s → %s", name, core.FormatSize(before), core.FormatSize(after)))
+						if current, _ := fileList.GetItemText(index); current == name {
+							fileList.SetItemText(index, name, core.FormatSize(after))
+						}
+					}
+				})
+			}()
+			return nil
+		}
 		if k == tcell.KeyF1 {
 			filename, _ := fileList.GetItemText(fileList.GetCurrentItem())
 			err := core.OpenDocExternal(filepath.Join(core.Inbound, filename))
@@ -325,12 +471,75 @@ func setupInboundFileList() {
 	})
 }
 
+func inboundFileDescription(f fs.DirEntry) string {
+	inf, _ := f.Info()
+	sizeMiBs := math.Round(float64(inf.Size())*100/1048576) / 100
+	if core.GetCachedPasswordProtected(f.Name()) {
+		return fmt.Sprintf("%v MiB [red]password protected", sizeMiBs)
+	}
+	return fmt.Sprintf("%v MiB", sizeMiBs)
+}
+
+// markArchivedInboundFiles adds a hint to all inbound files which already exist in the destination
+func markArchivedInboundFiles() {
+	inboundFiles, err := core.GetInboundFiles()
+	if err != nil {
+		return
+	}
+	for _, f := range inboundFiles {
+		copies := core.GetArchivedCopies(f.Name())
+		if len(copies) == 0 {
+			continue
+		}
+		if i, ok := findInboundItem(f.Name()); ok {
+			fileList.SetItemText(i, f.Name(), inboundFileDescription(f)+" "+deactivatedColorString+"already archived as "+strings.Join(copies, ", "))
+		}
+	}
+}
+
+// findInboundItem returns the index of the inbound file with exactly the given name
+// FindItems is no help, with an empty secondary text it matches every item that has a description
+func findInboundItem(name string) (int, bool) {
+	for i := 0; i < fileList.GetItemCount(); i++ {
+		if main, _ := fileList.GetItemText(i); main == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// watchInbound reloads the inbound list when files come or go, e.g. uploads from ding serve
+// it waits while the user is busy with a file, so the list does not change under their hands
+func watchInbound() {
+	last, _ := core.InboundFingerprint()
+	for range time.Tick(core.INBOUND_POLL_INTERVAL) {
+		fingerprint, err := core.InboundFingerprint()
+		if err != nil || fingerprint == last || app.GetFocus() != fileList {
+			continue
+		}
+		last = fingerprint
+		app.QueueUpdateDraw(refreshInboundFileList)
+	}
+}
+
+// refreshInboundFileList reloads the inbound list and keeps the selected file selected
+func refreshInboundFileList() {
+	selected, _ := fileList.GetItemText(fileList.GetCurrentItem())
+	setupInboundFileList()
+	if i, ok := findInboundItem(selected); ok {
+		fileList.SetCurrentItem(i)
+	}
+	markArchivedInboundFiles()
+}
+
 func setupDirectoryList() {
 	directoryList.SetFocusFunc(func() {
 		text := fmt.Sprintf(keymapTemplate, "🠕🠗", "navigate") +
 			keymapSep +
 			fmt.Sprintf(keymapTemplate, "enter", "select directory") +
 			keymapSep +
+			fmt.Sprintf(keymapTemplate, "#", "filter files by tag") +
+			keymapSep +
 			fmt.Sprintf(keymapTemplate, "shift+tab", "back")
 		contextKeyMap.SetText(text)
 	})
@@ -354,7 +563,7 @@ func setupDirectoryList() {
 			if err != nil {
 				// TODO
 			}
-			populateDirectoryFileList(directoryFiles)
+			populateDirectoryFileList(mainText, directoryFiles)
 		})
 	}
 
@@ -364,6 +573,10 @@ func setupDirectoryList() {
 			app.SetFocus(fileList)
 			return nil
 		}
+		if k == tcell.KeyRune && event.Rune() == '#' {
+			askForTagFilter()
+			return nil
+		}
 
 		return event
 	})
@@ -371,7 +584,7 @@ func setupDirectoryList() {
 	// init directory files
 	directoryName, _ := directoryList.GetItemText(directoryList.GetCurrentItem())
 	directoryFiles, _ := core.GetCachedDirectoryFiles(directoryName)
-	populateDirectoryFileList(directoryFiles)
+	populateDirectoryFileList(directoryName, directoryFiles)
 }
 
 func updateSelectedDirectory() {
@@ -383,6 +596,8 @@ func updateSelectedDirectory() {
 func setupNewName() {
 	newNameInput.SetFocusFunc(func() {
 		keymap := fmt.Sprintf(keymapTemplate, "enter", "ingest file") +
+			keymapSep +
+			fmt.Sprintf(keymapTemplate, "tab", "tags") +
 			keymapSep +
 			fmt.Sprintf(keymapTemplate, "f1", "original name") +
 			keymapSep +
@@ -393,22 +608,10 @@ func setupNewName() {
 
 	newNameInput.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
-			fileName, _ := fileList.GetItemText(fileList.GetCurrentItem())
-			directoryName, _ := directoryList.GetItemText(directoryList.GetCurrentItem())
-			newFileName := newNamePrefixInput.GetText() + newNameInput.GetText()
-
-			statusLine.SetText(fmt.Sprintf("Wait a second, moving %s to %s", fileName, filepath.Join(core.Dest, directoryName, newFileName)))
-
-			// actual move, blocking
-			core.MoveFileToDirectory(fileName, newFileName, directoryName)
-			// newly setup ui to reflect changes
-			setupInboundFileList()
-			//setupDirectoryList()
-			updateSelectedDirectory()
-
-			reset()
-
-			statusLine.SetText(fmt.Sprintf("Moved "+titleColorString+"%s[white] to "+subtileColorString+"%s[white]", fileName, filepath.Join(core.Dest, directoryName, newFileName)))
+			ingestSelectedFile(false)
+		}
+		if key == tcell.KeyTab {
+			app.SetFocus(tagInput)
 		}
 	})
 
@@ -429,25 +632,329 @@ func setupNewName() {
 	})
 }
 
+func setupTags() {
+	tagInput.SetFocusFunc(func() {
+		keymap := fmt.Sprintf(keymapTemplate, "enter", "ingest file") +
+			keymapSep +
+			fmt.Sprintf(keymapTemplate, ",", "separate tags") +
+			keymapSep +
+			fmt.Sprintf(keymapTemplate, "tab", "complete / name")
+
+		contextKeyMap.SetText(keymap)
+	})
+
+	tagInput.SetAutocompleteFunc(autocompleteTags)
+
+	tagInput.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			ingestSelectedFile(false)
+		case tcell.KeyTab, tcell.KeyBacktab:
+			app.SetFocus(newNameInput)
+		}
+	})
+}
+
+// askForTagFilter lets the user pick a tag; the directory file list then shows the documents with this tag
+// from all directories
+func askForTagFilter() {
+	input := tview.NewInputField().
+		SetLabel("#").
+		SetPlaceholder("tag").
+		SetFieldWidth(30).
+		SetAutocompleteFunc(autocompleteTags)
+	input.SetBorder(true).SetTitle("Filter by tag")
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		pages.RemovePage("tagfilter")
+		app.SetFocus(directoryList)
+		tags := core.ParseTags(input.GetText())
+		if key != tcell.KeyEnter || len(tags) == 0 {
+			return
+		}
+
+		directoryFileList.Clear()
+		documents := core.GetCachedTaggedDocuments(tags[0])
+		for _, rel := range documents {
+			directoryFileList.AddItem(rel, deactivatedColorString+strings.Join(core.GetCachedTags(rel), ", "), 0, nil)
+		}
+		statusLine.SetText(fmt.Sprintf("%v documents tagged "+titleColorString+"#%s", len(documents), tags[0]))
+	})
+
+	modal := tview.NewGrid().SetColumns(0, 36, 0).SetRows(0, 3, 0).AddItem(input, 1, 1, 1, 1, 0, 0, true)
+	pages.AddPage("tagfilter", modal, true, true)
+	app.SetFocus(input)
+}
+
+// unprotectPdf removes the password of a protected inbound pdf, trying the keyring before asking the user
+func unprotectPdf(name string) {
+	if !core.IsPasswordProtected(name) {
+		statusLine.SetText(fmt.Sprintf(titleColorString+"%s[white] is not password protected", name))
+		return
+	}
+
+	newName, sender, err := core.RemovePdfPasswordFromKeyring(name)
+	if err == nil {
+		setupInboundFileList()
+		statusLine.SetText(fmt.Sprintf("Removed the password of "+titleColorString+"%s[white] into "+subtileColorString+"%s[white] with the password of %s", name, newName, sender))
+		return
+	}
+	askForPdfPassword(name, tview.Escape(err.Error()))
+}
+
+// askForPdfPassword lets the user type the password of a protected inbound pdf
+// with a sender to remember it for, a password which works is stored in the keyring
+func askForPdfPassword(name, reason string) {
+	input := tview.NewInputField().
+		SetLabel("Password    ").
+		SetMaskCharacter('*').
+		SetFieldWidth(30)
+	remember := tview.NewInputField().
+		SetLabel("Remember as ").
+		SetPlaceholder("sender, optional").
+		SetFieldWidth(30)
+	form := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(remember, 1, 0, false)
+	form.SetBorder(true).SetTitle("Password of " + tview.Escape(name))
+	statusLine.SetText(reason + " - enter the password, tab remembers it for a sender, esc cancels")
+
+	done := func(key tcell.Key) {
+		switch key {
+		case tcell.KeyTab, tcell.KeyBacktab:
+			if input.HasFocus() {
+				app.SetFocus(remember)
+			} else {
+				app.SetFocus(input)
+			}
+			return
+		}
+
+		pages.RemovePage("password")
+		app.SetFocus(fileList)
+		if key != tcell.KeyEnter {
+			return
+		}
+
+		password := input.GetText()
+		newName, err := core.RemovePdfPassword(name, password)
+		if err == core.ErrWrongPassword {
+			askForPdfPassword(name, "[red]wrong password[white]")
+			return
+		}
+		if err != nil {
+			statusLine.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+			return
+		}
+		setupInboundFileList()
+		status := fmt.Sprintf("Removed the password of "+titleColorString+"%s[white] into "+subtileColorString+"%s[white]", name, newName)
+		if sender := strings.TrimSpace(remember.GetText()); sender != "" {
+			if err := core.AddToKeyring(core.KeyringEntry{Sender: sender, Password: password}); err != nil {
+				status += fmt.Sprintf(", but could not remember the password: [red]%s", tview.Escape(err.Error()))
+			} else {
+				status += ", remembered the password for " + tview.Escape(sender)
+			}
+		}
+		statusLine.SetText(status)
+	}
+	input.SetDoneFunc(done)
+	remember.SetDoneFunc(done)
+
+	modal := tview.NewGrid().SetColumns(0, 46, 0).SetRows(0, 4, 0).AddItem(form, 1, 1, 1, 1, 0, 0, true)
+	pages.AddPage("password", modal, true, true)
+	app.SetFocus(input)
+}
+
+// ingestSelectedFile moves the selected inbound file into the selected directory
+// unless forced, the user is asked what to do if the file is already archived
+func ingestSelectedFile(force bool) {
+	fileName, _ := fileList.GetItemText(fileList.GetCurrentItem())
+	directoryName, _ := directoryList.GetItemText(directoryList.GetCurrentItem())
+	newFileName := newNamePrefixInput.GetText() + newNameInput.GetText()
+
+	statusLine.SetText(fmt.Sprintf("Wait a second, moving %s to %s", fileName, filepath.Join(core.Dest, directoryName, newFileName)))
+
+	// actual move, blocking
+	job := core.NewIngestJob(fileName, newFileName, directoryName, core.ParseTags(tagInput.GetText()), force)
+	results, err := core.Ingest(job)
+	if dupErr, ok := err.(*core.DuplicateError); ok {
+		askAboutDuplicate(dupErr)
+		return
+	}
+	if err != nil {
+		if job.Name != job.Original {
+			setupInboundFileList()
+		}
+		statusLine.SetText(fmt.Sprintf("[red]could not move %s: %s", fileName, err))
+		return
+	}
+	newFileName = job.NewName
+
+	// newly setup ui to reflect changes
+	setupInboundFileList()
+	//setupDirectoryList()
+	updateSelectedDirectory()
+
+	reset()
+
+	message := fmt.Sprintf("Moved "+titleColorString+"%s[white] to "+subtileColorString+"%s[white]", fileName, filepath.Join(core.Dest, directoryName, newFileName))
+	if summary := core.StepSummary(results); summary != "" {
+		message += " " + deactivatedColorString + tview.Escape(summary)
+	}
+	statusLine.SetText(message)
+}
+
+// askAboutDuplicate lets the user decide what happens with an inbound file which is already archived
+func askAboutDuplicate(dupErr *core.DuplicateError) {
+	const (
+		skip     = "Skip"
+		remove   = "Delete inbound file"
+		fileAnyw = "File anyway"
+	)
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("%s is already archived as\n\n%s", dupErr.Name, strings.Join(dupErr.Copies, "\n"))).
+		AddButtons([]string{skip, remove, fileAnyw}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			pages.RemovePage("duplicate")
+			switch buttonLabel {
+			case remove:
+				if err := core.DeleteInboundFile(dupErr.Name); err != nil {
+					statusLine.SetText(fmt.Sprintf("[red]%s", err))
+				} else {
+					statusLine.SetText(fmt.Sprintf("Deleted "+titleColorString+"%s", dupErr.Name))
+				}
+				setupInboundFileList()
+				reset()
+			case fileAnyw:
+				ingestSelectedFile(true)
+			default:
+				statusLine.SetText(fmt.Sprintf("Skipped "+titleColorString+"%s", dupErr.Name))
+				reset()
+			}
+		})
+
+	pages.AddPage("duplicate", modal, true, true)
+	app.SetFocus(modal)
+}
+
 func populateNewName() {
 	prefix := core.GetTimestampFilePrefix()
 	newNamePrefixInput.SetText(prefix)
 
 	newNameFlex.Clear()
-	newNameFlex.AddItem(newNamePrefixInput, len(prefix), 0, false).AddItem(newNameInput, 0, 1, false)
+	newNameFlex.AddItem(newNamePrefixInput, len(prefix), 0, false).AddItem(newNameInput, 0, 1, false).AddItem(tagInput, tagInputWidth, 0, false)
 
 	autocompleteSelectedDirectory = autocompleteDirectoryMaker(newNameInput, map[string]bool{".pdf": true})
 	newNameInput.SetAutocompleteFunc(autocompleteSelectedDirectory)
 }
 
 // display only controls
-func populateDirectoryFileList(directoryFiles []fs.DirEntry) {
+func populateDirectoryFileList(directoryName string, directoryFiles []fs.DirEntry) {
 	directoryFileList.Clear()
 	for _, file := range directoryFiles {
 		inf, _ := file.Info()
 		sizeMiBs := math.Round(float64(inf.Size())*100/1048576) / 100
-		directoryFileList.AddItem(file.Name(), fmt.Sprintf("%v Mib", sizeMiBs), 0, nil)
+		description := fmt.Sprintf("%v Mib", sizeMiBs)
+		if tags := core.GetCachedTags(filepath.Join(directoryName, file.Name())); len(tags) > 0 {
+			description += " " + deactivatedColorString + "#" + strings.Join(tags, " #")
+		}
+		directoryFileList.AddItem(file.Name(), description, 0, nil)
+	}
+}
+
+// turnPreviewPage shows the given page of the selected inbound file; the page text is extracted async
+func turnPreviewPage(page int, layout bool) {
+	if fileList.GetItemCount() == 0 || page < 1 {
+		return
+	}
+	name, _ := fileList.GetItemText(fileList.GetCurrentItem())
+	if count, err := core.GetCachedDocPageCount(name); err == nil && page > count {
+		return
+	}
+
+	previewPage, previewLayout, previewImage = page, layout, false
+	populateDocPreview(previewHeader(name) + "loading ...")
+
+	go func() {
+		text := core.GetDocPagePreview(name, page, layout)
+		app.QueueUpdateDraw(func() {
+			current, _ := fileList.GetItemText(fileList.GetCurrentItem())
+			if current == name && previewPage == page && previewLayout == layout {
+				populateDocPreview(previewHeader(name) + text)
+			}
+		})
+	}()
+}
+
+// showThumbnail renders the first page of the given inbound file with half blocks into the document view
+func showThumbnail(name string) {
+	populateDocPreview(deactivatedColorString + "page 1 • thumbnail[white]\n\nrendering ...")
+
+	go func() {
+		img, err := core.GetDocThumbnail(name)
+		app.QueueUpdateDraw(func() {
+			current, _ := fileList.GetItemText(fileList.GetCurrentItem())
+			if current != name || !previewImage {
+				return
+			}
+			if err != nil {
+				populateDocPreview(fmt.Sprintf("[red]could not show first page: %s", err))
+				return
+			}
+			_, _, width, _ := documentView.GetInnerRect()
+			lines := termimg.HalfBlocks(img, width, func(top, bottom color.RGBA) string {
+				return fmt.Sprintf("[#%02x%02x%02x:#%02x%02x%02x]▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			})
+			populateDocPreview(deactivatedColorString + "page 1 • thumbnail[-:-]\n\n" + strings.Join(lines, "[-:-]\n") + "[-:-]")
+		})
+	}()
+}
+
+// showGraphic shows the first page of the given inbound file full screen with the terminals graphics protocol
+// tview can not place the image into the document view, it would draw over it; so the ui is suspended meanwhile
+func showGraphic(name string) {
+	protocol := termimg.Protocol()
+	if protocol == termimg.PROTOCOL_NONE {
+		statusLine.SetText("[red]your terminal does not seem to support kitty or sixel graphics; set DING_GRAPHICS to override")
+		return
+	}
+	img, err := core.GetDocThumbnail(name)
+	if err != nil {
+		statusLine.SetText(fmt.Sprintf("[red]could not show first page: %s", err))
+		return
+	}
+
+	encoded, err := termimg.Encode(img, protocol)
+	if err != nil {
+		statusLine.SetText(fmt.Sprintf("[red]could not show first page: %s", err))
+		return
+	}
+
+	app.Suspend(func() {
+		fmt.Print("\x1b[2J\x1b[H" + encoded + "\n\n  press enter to return ")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+		if protocol == termimg.PROTOCOL_KITTY {
+			fmt.Print(termimg.KITTY_CLEAR)
+		}
+	})
+}
+
+// previewHeader shows which page of the document is previewed and how
+func previewHeader(name string) string {
+	mode := "raw"
+	if previewLayout {
+		mode = "layout"
+	}
+	pages := "?"
+	if count, err := core.GetCachedDocPageCount(name); err == nil {
+		pages = fmt.Sprint(count)
+	}
+	due := ""
+	if date, ok := core.GetCachedDueDate(name); ok {
+		due = " • [red]due " + date.Format("2006-01-02")
 	}
+	return fmt.Sprintf(deactivatedColorString+"page %v/%s • %s%s[white]\n\n", previewPage, pages, mode, due)
 }
 
 func populateDocPreview(text string) {
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zmnpl/ding/core"
+	"golang.org/x/term"
+)
+
+func runUnlock(args []string) error {
+	flags := flag.NewFlagSet("unlock", flag.ExitOnError)
+	duration := flags.Duration("for", core.DEFAULT_UNLOCK_DURATION, "How long encrypted documents stay unlocked")
+	status := flags.Bool("status", false, "Only tell whether encrypted documents are unlocked")
+	flags.Parse(args)
+
+	if *status {
+		fmt.Println(unlockTimeLeft())
+		return nil
+	}
+
+	first := !core.HasEncryptionKey()
+	if first {
+		fmt.Fprintf(os.Stderr, "There is no passphrase for %s yet, the one you enter now will be used for all encrypted directories.\n", core.Dest)
+	}
+	passphrase, err := readPassphrase("Passphrase: ")
+	if err != nil {
+		return err
+	}
+	if first {
+		repeated, err := readPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if repeated != passphrase {
+			return fmt.Errorf("the passphrases do not match")
+		}
+	}
+
+	if err := core.Unlock(passphrase, *duration); err != nil {
+		return err
+	}
+	if !core.KEY_CACHE_SHARED {
+		fmt.Fprintln(os.Stderr, "The key is only kept in memory by this process, other ding processes stay locked on this platform.")
+		return nil
+	}
+	until, _ := core.UnlockedUntil()
+	fmt.Fprintf(os.Stderr, "Unlocked until %s.\n", until.Format("15:04"))
+	return nil
+}
+
+func runLock(args []string) error {
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	flags.Parse(args)
+
+	return core.Lock()
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase reads a passphrase without echoing it; without terminal it reads a line from stdin
+func readPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("could not read passphrase: %s", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("could not read passphrase: %s", err)
+	}
+	return string(passphrase), nil
+}
+
+// unlockTimeLeft describes how long encrypted documents stay unlocked
+func unlockTimeLeft() string {
+	until, ok := core.UnlockedUntil()
+	if !ok {
+		return "locked"
+	}
+	return fmt.Sprintf("unlocked for %v", time.Until(until).Round(time.Minute))
+}
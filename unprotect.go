@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zmnpl/ding/core"
+)
+
+func runUnprotect(args []string) error {
+	flags := flag.NewFlagSet("unprotect", flag.ExitOnError)
+	remember := flags.String("remember", "", "Store the typed password in the keyring under this sender")
+	match := flags.String("match", "", "File name pattern the stored password is tried on first, e.g. 'Kontoauszug*'")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: ding unprotect [flags] file...\n\nWrites unprotected copies of password protected inbound pdfs. The passwords of the keyring\n(%s) are tried first, then the password is asked for.\n\nFlags:\n", core.KeyringPath())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	for _, name := range flags.Args() {
+		name = filepath.Base(name)
+		if !core.IsPasswordProtected(name) {
+			fmt.Fprintf(os.Stderr, "%s is not password protected\n", name)
+			continue
+		}
+
+		newName, sender, err := core.RemovePdfPasswordFromKeyring(name)
+		if err == nil {
+			fmt.Printf("%s -> %s (password of %s)\n", name, newName, sender)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		password, err := readPassphrase(fmt.Sprintf("Password of %s: ", name))
+		if err != nil {
+			return err
+		}
+		newName, err = core.RemovePdfPassword(name, password)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		fmt.Printf("%s -> %s\n", name, newName)
+
+		if *remember != "" {
+			if err := core.AddToKeyring(core.KeyringEntry{Sender: *remember, Match: *match, Password: password}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}